@@ -0,0 +1,343 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mlange-42/ark-tools/app"
+	"github.com/mlange-42/ark/ecs"
+	"github.com/mlange-42/tiny-world/game/res"
+	"github.com/mlange-42/tiny-world/game/resource"
+	"github.com/mlange-42/tiny-world/game/sim"
+	"github.com/mlange-42/tiny-world/game/terr"
+	"github.com/spf13/cobra"
+)
+
+const tps = 60
+
+func main() {
+	if err := command().Execute(); err != nil {
+		fmt.Printf("ERROR: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+type options struct {
+	Data     dataFiles
+	Compare  dataFiles
+	Coverage float64
+	Values   map[string]string
+}
+
+// dataFiles are the data files to analyse.
+type dataFiles struct {
+	Terrain   string
+	Resources string
+	Rules     string
+}
+
+// table is a table of the report, with one row per terrain.
+type table struct {
+	Title   string
+	Columns []string
+	Rows    []row
+}
+
+type row struct {
+	Name   string
+	Values []string
+}
+
+func run(opt *options) error {
+	report, err := analyse(&opt.Data, opt)
+	if err != nil {
+		return err
+	}
+	if opt.Compare == (dataFiles{}) {
+		printReport(report)
+		return nil
+	}
+
+	if opt.Compare.Terrain == "" {
+		opt.Compare.Terrain = opt.Data.Terrain
+	}
+	if opt.Compare.Resources == "" {
+		opt.Compare.Resources = opt.Data.Resources
+	}
+	if opt.Compare.Rules == "" {
+		opt.Compare.Rules = opt.Data.Rules
+	}
+	old, err := analyse(&opt.Compare, opt)
+	if err != nil {
+		return err
+	}
+	printDiff(old, report)
+	return nil
+}
+
+// analyse reads the given data files and calculates the balance tables.
+//
+// Rates are per minute of game time, for the best case with all free neighbors covered by production terrain,
+// and for the typical case with the fraction given by the coverage option.
+// Values, costs and payback times sum over resources, weighted by their value (1 by default).
+// Population is paid for with the cheapest housing, i.e. building with population support.
+func analyse(files *dataFiles, opt *options) ([]table, error) {
+	resource.Prepare(dirFS(files.Resources))
+	terr.Prepare(dirFS(files.Terrain))
+
+	values, err := resourceValues(opt.Values)
+	if err != nil {
+		return nil, err
+	}
+
+	a := app.New()
+	rulesFS, rulesFile := dirFS(files.Rules)
+	sim.AddResources(&a.World, rulesFS, rulesFile, tps, 0, false)
+	update := ecs.GetResource[res.UpdateInterval](&a.World)
+	// Resource units per minute, per unit of production or consumption amount.
+	rate := float64(60*tps) / float64(update.Interval) / float64(update.Countdown)
+
+	value := func(amounts []terr.ResourceAmount) float64 {
+		v := 0.0
+		for _, a := range amounts {
+			v += float64(a.Amount) * values[a.Resource]
+		}
+		return v
+	}
+	upkeep := func(props *terr.TerrainProps) float64 {
+		v := 0.0
+		for r, c := range props.Consumption {
+			v += float64(c) * values[r] * rate
+		}
+		return v
+	}
+
+	housingCost, housingUpkeep, housing := 0.0, 0.0, ""
+	for i := range terr.Properties {
+		props := &terr.Properties[i]
+		typical, _ := support(props, opt.Coverage)
+		if !props.TerrainBits.Contains(terr.CanBuy) || typical <= 0 {
+			continue
+		}
+		cost := value(props.BuildCost) / typical
+		if housing == "" || cost < housingCost {
+			housingCost, housingUpkeep, housing = cost, upkeep(props)/typical, props.Name
+		}
+	}
+
+	producers := table{
+		Title: "Production",
+		Columns: []string{"resource", "best/min", "typical/min", "upkeep/min", "inputs/min",
+			"population", "net best/min", "net typical/min", "cost", "payback (min)", "flags"},
+	}
+	others := table{
+		Title:   "Other",
+		Columns: []string{"upkeep/min", "population", "support typical", "support best", "cost"},
+	}
+
+	for i := range terr.Properties {
+		props := &terr.Properties[i]
+		if !props.TerrainBits.Contains(terr.CanBuy) {
+			continue
+		}
+		prod := &props.Production
+		popCost := float64(props.Population)
+		cost := value(props.BuildCost) + popCost*housingCost
+		upk := upkeep(props) + popCost*housingUpkeep
+
+		if prod.MaxProduction == 0 {
+			typical, best := support(props, opt.Coverage)
+			others.Rows = append(others.Rows, row{
+				Name: props.Name,
+				Values: []string{
+					formatFloat(upkeep(props)), strconv.Itoa(int(props.Population)),
+					formatFloat(typical), formatFloat(best), formatFloat(value(props.BuildCost)),
+				},
+			})
+			continue
+		}
+
+		best, typical := 0.0, 0.0
+		if prod.ProductionTerrain != 0 {
+			free := float64(freeNeighbors(props.Footprint, prod.RequiredTerrain))
+			best = min(float64(prod.MaxProduction), free)
+			typical = min(float64(prod.MaxProduction), opt.Coverage*free)
+		} else if prod.HasInputs() {
+			best, typical = float64(prod.MaxProduction), float64(prod.MaxProduction)
+		}
+		best, typical = best*rate, typical*rate
+
+		inputs := value(prod.Inputs)
+		netBest := best*(values[prod.Resource]-inputs) - upk
+		netTypical := typical*(values[prod.Resource]-inputs) - upk
+
+		payback := "-"
+		if netTypical > 0 {
+			payback = formatFloat(cost / netTypical)
+		}
+		flags := ""
+		if netBest <= 0 {
+			flags = "never pays back"
+		}
+
+		producers.Rows = append(producers.Rows, row{
+			Name: props.Name,
+			Values: []string{
+				resource.Properties[prod.Resource].Name, formatFloat(best), formatFloat(typical),
+				formatFloat(upkeep(props)), formatFloat(typical * inputs), strconv.Itoa(int(props.Population)),
+				formatFloat(netBest), formatFloat(netTypical), formatFloat(cost), payback, flags,
+			},
+		})
+	}
+
+	housingTable := table{
+		Title:   "Housing",
+		Columns: []string{"cost/population", "upkeep/min/population"},
+	}
+	if housing != "" {
+		housingTable.Rows = append(housingTable.Rows, row{
+			Name:   housing,
+			Values: []string{formatFloat(housingCost), formatFloat(housingUpkeep)},
+		})
+	}
+
+	return []table{producers, others, housingTable}, nil
+}
+
+// dirFS splits a path into a file system of its directory, and the file name.
+// Allows for files outside the working directory, e.g. exported from another version.
+func dirFS(file string) (fs.FS, string) {
+	return os.DirFS(filepath.Dir(file)), filepath.Base(file)
+}
+
+// support returns the typical and best case population support of a terrain.
+func support(props *terr.TerrainProps, coverage float64) (float64, float64) {
+	supp := &props.PopulationSupport
+	if supp.MaxPopulation == 0 {
+		return 0, 0
+	}
+	typical, best := float64(supp.BasePopulation), float64(supp.BasePopulation)
+	if supp.BonusTerrain != 0 {
+		free := float64(freeNeighbors(props.Footprint, supp.RequiredTerrain))
+		typical += coverage * free
+		best += free
+	}
+	return min(typical, float64(supp.MaxPopulation)), min(best, float64(supp.MaxPopulation))
+}
+
+// freeNeighbors returns the number of neighbors of a footprint,
+// excluding one for the required terrain.
+func freeNeighbors(footprint image.Point, required terr.Terrain) int {
+	count := 2*(footprint.X+footprint.Y) + 4
+	if required != terr.Air {
+		count--
+	}
+	return count
+}
+
+// resourceValues parses resource values by name, defaulting to 1.
+func resourceValues(values map[string]string) ([]float64, error) {
+	result := make([]float64, len(resource.Properties))
+	for i := range result {
+		result[i] = 1
+	}
+	for name, v := range values {
+		id, ok := resource.ResourceID(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown resource %s", name)
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %s", name, err.Error())
+		}
+		result[id] = f
+	}
+	return result, nil
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', 1, 64)
+}
+
+func printReport(tables []table) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, t := range tables {
+		fmt.Fprintf(w, "%s\n", t.Title)
+		fmt.Fprintf(w, "name\t%s\n", strings.Join(t.Columns, "\t"))
+		for _, r := range t.Rows {
+			fmt.Fprintf(w, "%s\t%s\n", r.Name, strings.Join(r.Values, "\t"))
+		}
+		fmt.Fprintln(w)
+	}
+	w.Flush()
+}
+
+// printDiff prints added and removed rows, and changed values.
+func printDiff(old, cur []table) {
+	changes := 0
+	for i, t := range cur {
+		oldRows := map[string]row{}
+		for _, r := range old[i].Rows {
+			oldRows[r.Name] = r
+		}
+		newRows := map[string]bool{}
+		for _, r := range t.Rows {
+			newRows[r.Name] = true
+			o, ok := oldRows[r.Name]
+			if !ok {
+				fmt.Printf("%s: + %s\n", t.Title, r.Name)
+				changes++
+				continue
+			}
+			for j, col := range t.Columns {
+				if o.Values[j] != r.Values[j] {
+					fmt.Printf("%s: %s %s: %q -> %q\n", t.Title, r.Name, col, o.Values[j], r.Values[j])
+					changes++
+				}
+			}
+		}
+		for _, r := range old[i].Rows {
+			if !newRows[r.Name] {
+				fmt.Printf("%s: - %s\n", t.Title, r.Name)
+				changes++
+			}
+		}
+	}
+	if changes == 0 {
+		fmt.Println("No changes.")
+	}
+}
+
+func command() *cobra.Command {
+	opt := options{}
+	root := &cobra.Command{
+		Use:   "go run ./cmd/balance",
+		Short: "Analyse the economy balance of buildings in the game data",
+		Long: `Analyse the economy balance of buildings in the game data.
+
+Prints production and upkeep rates per minute, population, build cost and payback time of all buildings.
+Costs and rates of different resources are summed, weighted by their value (see --value).
+With --compare-*, prints the changes from the compared data files to the current ones instead.`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return run(&opt)
+		},
+	}
+	root.Flags().StringVarP(&opt.Data.Terrain, "terrain", "t", "data/json/terrain.json", "Terrain file.")
+	root.Flags().StringVar(&opt.Data.Resources, "resources", "data/json/resources.json", "Resources file.")
+	root.Flags().StringVarP(&opt.Data.Rules, "rules", "r", "data/json/rules.json", "Rules file.")
+	root.Flags().StringVar(&opt.Compare.Terrain, "compare-terrain", "", "Terrain file to compare to, e.g. of the last release.")
+	root.Flags().StringVar(&opt.Compare.Resources, "compare-resources", "", "Resources file to compare to.")
+	root.Flags().StringVar(&opt.Compare.Rules, "compare-rules", "", "Rules file to compare to.")
+	root.Flags().Float64Var(&opt.Coverage, "coverage", 0.5, "Fraction of free neighbors covered by production or bonus terrain, in the typical case.")
+	root.Flags().StringToStringVar(&opt.Values, "value", map[string]string{}, "Value of resources, like --value stones=2. Default 1.")
+
+	return root
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mlange-42/tiny-world/game/resource"
+	"github.com/mlange-42/tiny-world/game/save"
+	"github.com/mlange-42/tiny-world/game/terr"
+	"github.com/spf13/cobra"
+)
+
+func main() {
+	if err := command().Execute(); err != nil {
+		fmt.Printf("ERROR: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+type options struct {
+	SaveFolder string
+	Encoding   string
+	All        bool
+}
+
+func run(opt *options, names []string) error {
+	enc, err := save.ParseEncoding(opt.Encoding)
+	if err != nil {
+		return err
+	}
+
+	// Required for migrating terrain and resource IDs.
+	data := os.DirFS(".")
+	resource.Prepare(data, "data/json/resources.json")
+	terr.Prepare(data, "data/json/terrain.json")
+
+	if opt.All {
+		games, err := save.ListSaveGames(opt.SaveFolder)
+		if err != nil {
+			return err
+		}
+		for _, g := range games {
+			names = append(names, g.Name)
+		}
+	}
+
+	for _, name := range names {
+		oldEnc, err := save.ConvertGame(opt.SaveFolder, name, enc)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s: %s -> %s\n", name, oldEnc, enc)
+	}
+	return nil
+}
+
+func command() *cobra.Command {
+	opt := options{}
+	root := &cobra.Command{
+		Use:   "go run ./cmd/convert [NAMES...]",
+		Short: "Convert save games to another encoding",
+		Long: `Convert save games to another encoding.
+Arguments are names of save games. Save games are also migrated to the current version of the game data.
+Replays of migrated save games are deleted, as they refer to the old game data.`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opt.All == (len(args) > 0) {
+				_ = cmd.Help()
+				return fmt.Errorf("either save game names or --all are required")
+			}
+			return run(&opt, args)
+		},
+	}
+	root.Flags().StringVar(&opt.SaveFolder, "save-folder", "save", "Folder of the save games.")
+	root.Flags().StringVarP(&opt.Encoding, "encoding", "e", "gzip", "Encoding to convert to. One of json, gzip.")
+	root.Flags().BoolVarP(&opt.All, "all", "a", false, "Convert all save games in the folder.")
+
+	return root
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mlange-42/tiny-world/game/maps"
+	"github.com/mlange-42/tiny-world/game/res"
+	"github.com/mlange-42/tiny-world/game/resource"
+	"github.com/mlange-42/tiny-world/game/save"
+	"github.com/mlange-42/tiny-world/game/terr"
+	"github.com/spf13/cobra"
+)
+
+const (
+	severityError   = "error"
+	severityWarning = "warning"
+)
+
+func main() {
+	if err := command().Execute(); err != nil {
+		fmt.Printf("ERROR: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+type options struct {
+	Rules        string
+	Achievements string
+	Out          string
+	Strict       bool
+}
+
+type problem struct {
+	Severity string `json:"severity"`
+	Check    string `json:"check"`
+	Message  string `json:"message"`
+	// Position in the file, for problems found while parsing.
+	Line   int `json:"line,omitempty"`
+	Column int `json:"column,omitempty"`
+	// Cell of the map, for problems found in the parsed map.
+	Cell *image.Point `json:"cell,omitempty"`
+}
+
+type mapResult struct {
+	File     string    `json:"file"`
+	Version  int       `json:"version"`
+	Problems []problem `json:"problems"`
+}
+
+type result struct {
+	Maps     []mapResult `json:"maps"`
+	Errors   int         `json:"errors"`
+	Warnings int         `json:"warnings"`
+}
+
+type achievementJs struct {
+	ID string `json:"id"`
+}
+
+func run(opt *options, paths []string) error {
+	data := os.DirFS(".")
+	resource.Prepare(data, "data/json/resources.json")
+	terr.Prepare(data, "data/json/terrain.json")
+	rules := res.NewRules(data, opt.Rules)
+
+	achData, err := fs.ReadFile(data, opt.Achievements)
+	if err != nil {
+		return err
+	}
+	// Only IDs are needed, so other fields are not decoded.
+	ach := []achievementJs{}
+	if err := json.Unmarshal(achData, &ach); err != nil {
+		return err
+	}
+	achievements := map[string]bool{}
+	for _, a := range ach {
+		achievements[a.ID] = true
+	}
+
+	files, err := collectFiles(paths)
+	if err != nil {
+		return err
+	}
+
+	result := result{Maps: []mapResult{}}
+	for _, file := range files {
+		mapStr, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		mapRes := checkMap(string(mapStr), rules, achievements)
+		mapRes.File = filepath.ToSlash(file)
+		for _, p := range mapRes.Problems {
+			if p.Severity == severityError {
+				result.Errors++
+			} else {
+				result.Warnings++
+			}
+		}
+		result.Maps = append(result.Maps, mapRes)
+	}
+
+	js, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	if opt.Out == "" {
+		fmt.Println(string(js))
+	} else if err := os.WriteFile(opt.Out, js, 0666); err != nil {
+		return err
+	}
+
+	if result.Errors > 0 || (opt.Strict && result.Warnings > 0) {
+		return fmt.Errorf("found %d errors and %d warnings in %d maps", result.Errors, result.Warnings, len(files))
+	}
+	return nil
+}
+
+// collectFiles returns the given map files, and the JSON files in the given directories.
+func collectFiles(paths []string) ([]string, error) {
+	files := []string{}
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		entries, err := os.ReadDir(p)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if !e.IsDir() && strings.HasSuffix(e.Name(), ".json") {
+				files = append(files, filepath.Join(p, e.Name()))
+			}
+		}
+	}
+	return files, nil
+}
+
+// checkMap parses a map and lints the result.
+func checkMap(mapStr string, rules res.Rules, achievements map[string]bool) mapResult {
+	result := mapResult{Version: 1, Problems: []problem{}}
+
+	version := struct {
+		Version int `json:"version"`
+	}{}
+	if err := json.Unmarshal([]byte(mapStr), &version); err == nil && version.Version > 0 {
+		result.Version = version.Version
+	}
+
+	mapData, err := save.ParseMap(mapStr)
+	if err != nil {
+		p := problem{Severity: severityError, Check: "parse", Message: err.Error()}
+		var mapErr *save.MapError
+		if errors.As(err, &mapErr) {
+			p.Line, p.Column, p.Message = mapErr.Line, mapErr.Column, mapErr.Err.Error()
+		}
+		result.Problems = append(result.Problems, p)
+		return result
+	}
+
+	if mapData.Rules != nil {
+		mapData.Rules.Apply(&rules)
+	}
+
+	result.Problems = append(result.Problems, checkShape(&mapData)...)
+	result.Problems = append(result.Problems, checkCenter(&mapData, rules.InitialBuildRadius)...)
+	result.Problems = append(result.Problems, checkLandUse(&mapData)...)
+	result.Problems = append(result.Problems, checkFootprints(&mapData)...)
+	result.Problems = append(result.Problems, checkAchievements(&mapData, achievements)...)
+	return result
+}
+
+// checkShape reports rows that differ in length from the first row.
+func checkShape(m *maps.Map) []problem {
+	problems := []problem{}
+	if len(m.Terrain) == 0 {
+		return append(problems, problem{Severity: severityError, Check: "shape", Message: "map is empty"})
+	}
+	width := len(m.Terrain[0])
+	for y, row := range m.Terrain {
+		if len(row) != width {
+			problems = append(problems, problem{
+				Severity: severityError, Check: "shape",
+				Message: fmt.Sprintf("row %d has %d cells, but the first row has %d", y, len(row), width),
+				Cell:    &image.Point{X: 0, Y: y},
+			})
+		}
+	}
+	return problems
+}
+
+// checkCenter reports a center outside the map,
+// and warns if there are no buildable plains in the initial build radius.
+func checkCenter(m *maps.Map, radius int) []problem {
+	c := m.Center
+	if c.Y < 0 || c.Y >= len(m.Terrain) || c.X < 0 || c.X >= len(m.Terrain[c.Y]) {
+		return []problem{{
+			Severity: severityError, Check: "center",
+			Message: fmt.Sprintf("center %v is outside of the map", c),
+			Cell:    &c,
+		}}
+	}
+
+	plains := terr.ToTerrain("plains")
+	r2 := radius * radius
+	for y := max(c.Y-radius, 0); y <= c.Y+radius && y < len(m.Terrain); y++ {
+		row := m.Terrain[y]
+		for x := max(c.X-radius, 0); x <= c.X+radius && x < len(row); x++ {
+			dx, dy := x-c.X, y-c.Y
+			if dx*dx+dy*dy <= r2 && row[x] == plains && m.LandUse[y][x] == terr.Air {
+				return []problem{}
+			}
+		}
+	}
+	return []problem{{
+		Severity: severityWarning, Check: "build-radius",
+		Message: fmt.Sprintf("no buildable plains in the initial build radius of %d around the center", radius),
+		Cell:    &c,
+	}}
+}
+
+// checkLandUse reports land use that can't be built on the terrain below.
+func checkLandUse(m *maps.Map) []problem {
+	problems := []problem{}
+	for y, row := range m.LandUse {
+		for x, lu := range row {
+			if lu == terr.Air {
+				continue
+			}
+			ter := m.Terrain[y][x]
+			if terr.Properties[lu].BuildOn.Contains(ter) {
+				continue
+			}
+			problems = append(problems, problem{
+				Severity: severityError, Check: "land-use",
+				Message: fmt.Sprintf("%s can't be built on %s", terr.Properties[lu].Name, terr.Properties[ter].Name),
+				Cell:    &image.Point{X: x, Y: y},
+			})
+		}
+	}
+	return problems
+}
+
+// checkFootprints reports multi-tile buildings that don't repeat on all cells of their footprint.
+// Like in the game, a building is placed at the first cell in reading order that is not covered yet.
+func checkFootprints(m *maps.Map) []problem {
+	problems := []problem{}
+	covered := map[image.Point]bool{}
+	for y, row := range m.LandUse {
+		for x, lu := range row {
+			size := terr.Properties[lu].Footprint
+			if lu == terr.Air || size == image.Pt(1, 1) || covered[image.Pt(x, y)] {
+				continue
+			}
+			complete := true
+			for dy := 0; dy < size.Y; dy++ {
+				for dx := 0; dx < size.X; dx++ {
+					xx, yy := x+dx, y+dy
+					if yy >= len(m.LandUse) || xx >= len(m.LandUse[yy]) ||
+						m.LandUse[yy][xx] != lu || covered[image.Pt(xx, yy)] {
+						complete = false
+						continue
+					}
+					covered[image.Pt(xx, yy)] = true
+				}
+			}
+			if !complete {
+				problems = append(problems, problem{
+					Severity: severityError, Check: "footprint",
+					Message: fmt.Sprintf("%s must cover %dx%d cells, starting here", terr.Properties[lu].Name, size.X, size.Y),
+					Cell:    &image.Point{X: x, Y: y},
+				})
+			}
+		}
+	}
+	return problems
+}
+
+// checkAchievements reports required achievements that don't exist.
+func checkAchievements(m *maps.Map, achievements map[string]bool) []problem {
+	problems := []problem{}
+	for _, a := range m.Achievements {
+		if !achievements[a] {
+			problems = append(problems, problem{
+				Severity: severityError, Check: "achievements",
+				Message: fmt.Sprintf("unknown achievement '%s'", a),
+			})
+		}
+	}
+	return problems
+}
+
+func command() *cobra.Command {
+	opt := options{}
+	root := &cobra.Command{
+		Use:           "go run ./cmd/mapcheck [MAPS...]",
+		Short:         "Validate and lint scenario maps, and report problems as JSON",
+		Long:          "Validate and lint scenario maps, and report problems as JSON.\nArguments are map files or folders with maps. Checks data/maps if none are given.",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				args = []string{"data/maps"}
+			}
+			return run(&opt, args)
+		},
+	}
+	root.Flags().StringVarP(&opt.Rules, "rules", "r", "data/json/rules.json", "Rules file.")
+	root.Flags().StringVarP(&opt.Achievements, "achievements", "a", "data/json/achievements.json", "Achievements file.")
+	root.Flags().StringVarP(&opt.Out, "out", "o", "", "File to write the report to. Prints to stdout if empty.")
+	root.Flags().BoolVar(&opt.Strict, "strict", false, "Fail on warnings, not only on errors.")
+
+	return root
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mlange-42/ark-tools/app"
+	"github.com/mlange-42/ark/ecs"
+	"github.com/mlange-42/tiny-world/game/res"
+	"github.com/mlange-42/tiny-world/game/resource"
+	"github.com/mlange-42/tiny-world/game/save"
+	"github.com/mlange-42/tiny-world/game/sim"
+	"github.com/mlange-42/tiny-world/game/sys"
+	"github.com/mlange-42/tiny-world/game/terr"
+	"github.com/spf13/cobra"
+)
+
+const tps = 60
+
+func main() {
+	if err := command().Execute(); err != nil {
+		fmt.Printf("ERROR: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+type options struct {
+	Ticks      int64
+	Seed       uint64
+	Rules      string
+	Map        string
+	Load       string
+	Replay     string
+	SaveFolder string
+	Save       string
+	Encoding   string
+	Out        string
+	SaveMap    string
+	HashEvery  int64
+	Verbose    bool
+	Generate   res.WorldGen
+}
+
+type resourceResult struct {
+	Stock       int `json:"stock"`
+	Capacity    int `json:"capacity"`
+	Total       int `json:"total"`
+	Production  int `json:"production"`
+	Consumption int `json:"consumption"`
+}
+
+type replayResult struct {
+	Verified    bool     `json:"verified"`
+	Differences []string `json:"differences"`
+}
+
+type result struct {
+	Seed          uint64                    `json:"seed"`
+	Ticks         int64                     `json:"ticks"`
+	Resources     map[string]resourceResult `json:"resources"`
+	Population    int                       `json:"population"`
+	MaxPopulation int                       `json:"max_population"`
+	Hash          string                    `json:"hash"`
+	Replay        *replayResult             `json:"replay,omitempty"`
+}
+
+func run(opt *options) error {
+	enc, err := save.ParseEncoding(opt.Encoding)
+	if err != nil {
+		return err
+	}
+
+	data := os.DirFS(".")
+	resource.Prepare(data, "data/json/resources.json")
+	terr.Prepare(data, "data/json/terrain.json")
+
+	a := app.New()
+	world := &a.World
+
+	var replay res.Replay
+	seed, isEditor := opt.Seed, false
+	if opt.Replay != "" {
+		if replay, err = save.LoadReplay(opt.SaveFolder, opt.Replay); err != nil {
+			return err
+		}
+		if replay.Final == nil {
+			return fmt.Errorf("replay %s has no final state", opt.Replay)
+		}
+		seed, isEditor = replay.Seed, replay.IsEditor
+	}
+
+	sim.AddResources(world, data, opt.Rules, tps, seed, isEditor)
+
+	hud := res.Feedback{HUD: &res.HeadlessHUD{LogStatus: opt.Verbose}}
+	ecs.AddResource(world, &hud)
+
+	load := save.LoadTypeNone
+	mapLoc := save.MapLocation{}
+	mapFolder := ""
+	if opt.Replay != "" {
+		load, mapFolder, mapLoc = sim.PlayReplay(world, &replay)
+	} else if opt.Load != "" {
+		load = save.LoadTypeGame
+	} else if opt.Map != "" {
+		load = save.LoadTypeMap
+		mapFolder = filepath.Dir(opt.Map)
+		mapLoc = save.MapLocation{Name: strings.TrimSuffix(filepath.Base(opt.Map), ".json")}
+	}
+	if opt.Replay == "" && load == save.LoadTypeNone {
+		gen := opt.Generate
+		gen.Seed = seed
+		*ecs.GetResource[res.WorldGen](world) = gen
+	}
+	if opt.Replay == "" && load != save.LoadTypeGame {
+		sim.RecordReplay(world, seed, load, mapFolder, mapLoc, isEditor)
+	}
+
+	sim.AddInitSystem(a, load, data, mapFolder, mapLoc)
+	sim.AddSystems(a, tps)
+	if opt.HashEvery > 0 {
+		a.AddSystem(&sys.LogHash{Interval: opt.HashEvery})
+	}
+
+	if load == save.LoadTypeGame {
+		if err := save.LoadWorld(world, opt.SaveFolder, opt.Load); err != nil {
+			return err
+		}
+		if err := sim.ContinueReplay(world, opt.SaveFolder, opt.Load); err != nil {
+			return err
+		}
+	}
+
+	a.Initialize()
+
+	start := time.Now()
+	gameTick := ecs.GetResource[res.GameTick](world)
+	if opt.Replay != "" {
+		for gameTick.RenderTick < replay.Final.RenderTick {
+			a.Update()
+		}
+	} else {
+		for i := int64(0); i < opt.Ticks; i++ {
+			a.Update()
+		}
+	}
+	if opt.Verbose {
+		log.Printf("Simulated %d ticks in %s", gameTick.Tick, time.Since(start))
+	}
+
+	if opt.Save != "" {
+		ecs.GetResource[res.SaveTime](world).Time = time.Now()
+		if err := save.SaveWorld(opt.SaveFolder, opt.Save, world, sys.SkipOnSave(), enc); err != nil {
+			return err
+		}
+		if rec := ecs.GetResource[res.Replay](world); rec.Mode == res.ReplayRecord {
+			rec.Finish(gameTick, ecs.GetResource[res.Stock](world))
+			if err := save.SaveReplay(opt.SaveFolder, opt.Save, rec); err != nil {
+				return err
+			}
+		}
+	}
+
+	if opt.SaveMap != "" {
+		name := strings.TrimSuffix(filepath.Base(opt.SaveMap), ".json")
+		if err := save.SaveMap(filepath.Dir(opt.SaveMap), name, world); err != nil {
+			return err
+		}
+	}
+
+	result := collectResult(world)
+	if opt.Replay != "" {
+		diff := replay.Verify(ecs.GetResource[res.Stock](world))
+		result.Replay = &replayResult{
+			Verified:    len(diff) == 0,
+			Differences: diff,
+		}
+	}
+
+	js, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	if opt.Out == "" {
+		fmt.Println(string(js))
+	} else if err := os.WriteFile(opt.Out, js, 0666); err != nil {
+		return err
+	}
+
+	if result.Replay != nil && !result.Replay.Verified {
+		return fmt.Errorf("replay %s does not match the recorded final stock", opt.Replay)
+	}
+	return nil
+}
+
+func collectResult(world *ecs.World) result {
+	stock := ecs.GetResource[res.Stock](world)
+	production := ecs.GetResource[res.Production](world)
+
+	resources := map[string]resourceResult{}
+	for i, r := range resource.Properties {
+		resources[r.Name] = resourceResult{
+			Stock:       stock.Res[i],
+			Capacity:    stock.Cap[i],
+			Total:       stock.Total[i],
+			Production:  production.Prod[i],
+			Consumption: production.Cons[i],
+		}
+	}
+
+	return result{
+		Seed:          ecs.GetResource[res.Rand](world).Seed(),
+		Ticks:         ecs.GetResource[res.GameTick](world).Tick,
+		Resources:     resources,
+		Population:    stock.Population,
+		MaxPopulation: stock.MaxPopulation,
+		Hash:          fmt.Sprintf("%016x", sys.StateHash(world)),
+	}
+}
+
+func command() *cobra.Command {
+	opt := options{}
+	root := &cobra.Command{
+		Use:           "go run ./cmd/sim",
+		Short:         "Run the game simulation headless, as fast as possible",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cnt := 0
+			for _, s := range []string{opt.Map, opt.Load, opt.Replay} {
+				if s != "" {
+					cnt++
+				}
+			}
+			if cnt > 1 {
+				_ = cmd.Help()
+				return fmt.Errorf("only one of --map, --load and --replay can be used")
+			}
+			if cnt > 0 && opt.Generate.IsEnabled() {
+				_ = cmd.Help()
+				return fmt.Errorf("--generate can't be used with --map, --load or --replay")
+			}
+			if !cmd.Flags().Changed("seed") {
+				opt.Seed = res.RandomSeed()
+			}
+			return run(&opt)
+		},
+	}
+	root.Flags().Int64VarP(&opt.Ticks, "ticks", "n", 3600, "Number of ticks to simulate.")
+	root.Flags().Uint64Var(&opt.Seed, "seed", 0, "Random seed. Uses a random seed if not given. Ignored with --load.")
+	root.Flags().StringVarP(&opt.Rules, "rules", "r", "data/json/rules.json", "Rules file.")
+	root.Flags().StringVarP(&opt.Map, "map", "m", "", "Map file to start from.")
+	root.Flags().StringVarP(&opt.Load, "load", "l", "", "Name of a save game to start from.")
+	root.Flags().StringVar(&opt.Replay, "replay", "", "Name of a save game to play back the replay of, and verify the final stock.\nIgnores --ticks and --seed.")
+	root.Flags().StringVar(&opt.SaveFolder, "save-folder", "save", "Folder for loading and saving games.")
+	root.Flags().StringVarP(&opt.Save, "save", "s", "", "Name for saving the game after the run.")
+	root.Flags().StringVar(&opt.Encoding, "encoding", "json", "Encoding of the save game, with --save. One of json, gzip.")
+	root.Flags().StringVarP(&opt.Out, "out", "o", "", "File to write the resulting stock and production to. Prints to stdout if empty.")
+	root.Flags().StringVar(&opt.SaveMap, "save-map", "", "Map file to write the world to after the run, e.g. to use a generated world as scenario.")
+	gen := res.NewWorldGen(0, 0)
+	opt.Generate = gen
+	root.Flags().IntVarP(&opt.Generate.Size, "generate", "g", 0, "Side length of a procedurally generated start area. Disabled if 0.")
+	root.Flags().Float64Var(&opt.Generate.Water, "water", gen.Water, "Fraction of water tiles, with --generate.")
+	root.Flags().Float64Var(&opt.Generate.Hills, "hills", gen.Hills, "Fraction of hills tiles, with --generate.")
+	root.Flags().Float64Var(&opt.Generate.Desert, "desert", gen.Desert, "Fraction of desert tiles, with --generate.")
+	root.Flags().Float64Var(&opt.Generate.Forest, "forest", gen.Forest, "Fraction of plains and hills covered by trees, with --generate.")
+	root.Flags().Float64Var(&opt.Generate.Rocks, "rocks", gen.Rocks, "Fraction of land covered by rocks, with --generate.")
+	root.Flags().Int64Var(&opt.HashEvery, "hash-every", 0, "Log the state hash every N ticks. Disabled if 0.")
+	root.Flags().BoolVarP(&opt.Verbose, "verbose", "v", false, "Log status messages and timing.")
+
+	return root
+}
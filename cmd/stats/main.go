@@ -25,40 +25,35 @@ func main() {
 		log.Fatalf("Error reading map '%s': %s", file, err.Error())
 	}
 
+	resource.Prepare(os.DirFS("."), "data/json/resources.json")
+	terr.Prepare(os.DirFS("."), "data/json/terrain.json")
+
 	mapData, err := save.ParseMap(string(mapStr))
 	if err != nil {
 		log.Fatalf("Error parsing map '%s': %s", file, err.Error())
 	}
 
-	resource.Prepare(os.DirFS("."), "data/json/resources.json")
-	terr.Prepare(os.DirFS("."), "data/json/terrain.json")
-
 	frequencies := map[terr.Terrain]int{}
 	total := 0
 
-	for y := 0; y < len(mapData.Data); y++ {
-		line := mapData.Data[y]
-		for x := 0; x < len(line); x++ {
-			rn := line[x]
-			ter, ok := terr.SymbolToTerrain[rn]
-			if !ok {
-				panic(fmt.Sprintf("unknown map symbol '%s'", string(rn)))
-			}
-			tBits := terr.Properties[ter.Terrain].TerrainBits
-			luBits := terr.Properties[ter.LandUse].TerrainBits
-			if ter.Terrain != terr.Air && tBits.Contains(terr.CanBuild) && !tBits.Contains(terr.CanBuy) {
-				if cnt, ok := frequencies[ter.Terrain]; ok {
-					frequencies[ter.Terrain] = cnt + 1
+	for y, row := range mapData.Terrain {
+		for x, ter := range row {
+			lu := mapData.LandUse[y][x]
+			tBits := terr.Properties[ter].TerrainBits
+			luBits := terr.Properties[lu].TerrainBits
+			if ter != terr.Air && tBits.Contains(terr.CanBuild) && !tBits.Contains(terr.CanBuy) {
+				if cnt, ok := frequencies[ter]; ok {
+					frequencies[ter] = cnt + 1
 				} else {
-					frequencies[ter.Terrain] = 1
+					frequencies[ter] = 1
 				}
 				total++
 			}
-			if ter.LandUse != terr.Air && luBits.Contains(terr.CanBuild) && !luBits.Contains(terr.CanBuy) {
-				if cnt, ok := frequencies[ter.LandUse]; ok {
-					frequencies[ter.LandUse] = cnt + 1
+			if lu != terr.Air && luBits.Contains(terr.CanBuild) && !luBits.Contains(terr.CanBuy) {
+				if cnt, ok := frequencies[lu]; ok {
+					frequencies[lu] = cnt + 1
 				} else {
-					frequencies[ter.LandUse] = 1
+					frequencies[lu] = 1
 				}
 				total++
 			}
@@ -38,12 +38,21 @@ type Production struct {
 	HasRequired bool
 }
 
+type InputBuffer struct {
+	Stock []uint8
+}
+
 type Consumption struct {
 	Amount      []uint8
 	Countdown   []int16
 	IsSatisfied bool
 }
 
+type Supply struct {
+	Stock     []uint8
+	Requested []bool
+}
+
 type Population struct {
 	Pop uint8
 }
@@ -53,7 +62,9 @@ type PopulationSupport struct {
 	HasRequired bool
 }
 
-type Warehouse struct{}
+type Warehouse struct {
+	Stock []int
+}
 
 type UnlocksTerrain struct{}
 
@@ -68,6 +79,12 @@ type Hauler struct {
 	Path         []Tile
 	Index        int
 	PathFraction uint8
+	// Movement accumulated towards the next increment of PathFraction, scaled by the move cost.
+	Progress uint16
+}
+
+type Delivery struct {
+	Amount uint8
 }
 
 type HaulerSprite struct {
@@ -89,6 +106,14 @@ type BuildRadius struct {
 	Radius uint8
 }
 
+type FireProtection struct {
+	Radius uint8
+}
+
+type Burning struct {
+	Countdown int
+}
+
 type CardAnimation struct {
 	image.Point
 	Target     image.Point
@@ -1,9 +1,13 @@
 package game
 
 import (
+	"log"
+
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/mlange-42/ark-tools/app"
 	"github.com/mlange-42/tiny-world/game/res"
+	"github.com/mlange-42/tiny-world/game/save"
+	"github.com/mlange-42/tiny-world/game/sys"
 )
 
 // Game container
@@ -13,6 +17,8 @@ type Game struct {
 	Mouse  res.Mouse
 
 	canvasHelper *canvasHelper
+	// Save game system of the running game, for emergency saves. Nil in the menu.
+	saveGame *sys.SaveGame
 }
 
 // NewGame returns a new game
@@ -47,6 +53,7 @@ func (g *Game) Layout(outsideWidth, outsideHeight int) (int, int) {
 
 // Update the game.
 func (g *Game) Update() error {
+	defer g.recoverPanic()
 	g.updateMouse()
 	g.App.Update()
 	return nil
@@ -54,12 +61,34 @@ func (g *Game) Update() error {
 
 // Draw the game.
 func (g *Game) Draw(screen *ebiten.Image) {
+	defer g.recoverPanic()
 	g.Screen.Image = screen
 	g.Screen.Width = screen.Bounds().Dx()
 	g.Screen.Height = screen.Bounds().Dy()
 	g.App.UpdateUI()
 }
 
+// recoverPanic attempts an emergency save of the running game when a system panics,
+// and re-panics afterwards.
+// Only the first panic is saved. The emergency save is disabled for good afterwards, also if it failed.
+func (g *Game) recoverPanic() {
+	r := recover()
+	if r == nil {
+		return
+	}
+	if g.saveGame != nil {
+		log.Printf("Game crashed, attempting emergency save: %v", r)
+		if err := g.saveGame.EmergencySave(&g.App.World); err != nil {
+			log.Printf("Emergency save failed: %s", err.Error())
+		} else {
+			log.Printf("Emergency save written to '%s'", save.EmergencyName(g.saveGame.Name))
+		}
+		// Never try again, as the world may be broken by now.
+		g.saveGame = nil
+	}
+	panic(r)
+}
+
 func (g *Game) updateMouse() {
 	g.Mouse.IsInside = g.canvasHelper.isMouseInside(g.Screen.Width, g.Screen.Height)
 }
@@ -1,12 +1,27 @@
 package maps
 
-import "image"
+import (
+	"image"
+
+	"github.com/mlange-42/tiny-world/game/res"
+	"github.com/mlange-42/tiny-world/game/terr"
+)
 
 type Map struct {
-	Terrains              []rune
-	Data                  [][]rune
+	// Terrain layer, by row.
+	Terrain [][]terr.Terrain
+	// Land use layer, by row. Has the same shape as the terrain layer.
+	// Multi-tile buildings cover several cells, and are placed at the first one.
+	LandUse [][]terr.Terrain
+	// Terrains to draw random cards from.
+	Terrains              []terr.Terrain
 	Achievements          []string
+	Title                 string
+	Author                string
+	Difficulty            string
 	Description           string
 	Center                image.Point
 	InitialRandomTerrains int
+	// Rules changed by the map. Nil if the map uses the default rules.
+	Rules *res.RulesOverride
 }
@@ -0,0 +1,58 @@
+package math
+
+import stdmath "math"
+
+// Noise is a seeded, two-dimensional value noise, for procedural generation.
+type Noise struct {
+	seed uint64
+}
+
+// NewNoise creates a new Noise from a seed.
+func NewNoise(seed uint64) Noise {
+	return Noise{seed: seed}
+}
+
+// Value returns the noise at a position, in the range [0, 1).
+// Values are random at integer coordinates, and smoothly interpolated in between.
+func (n *Noise) Value(x, y float64) float64 {
+	x0, y0 := stdmath.Floor(x), stdmath.Floor(y)
+	ix, iy := int64(x0), int64(y0)
+	fx, fy := smoothStep(x-x0), smoothStep(y-y0)
+
+	v00 := n.hash(ix, iy)
+	v10 := n.hash(ix+1, iy)
+	v01 := n.hash(ix, iy+1)
+	v11 := n.hash(ix+1, iy+1)
+
+	top := v00 + (v10-v00)*fx
+	bottom := v01 + (v11-v01)*fx
+	return top + (bottom-top)*fy
+}
+
+// Fractal returns the weighted sum of octaves of noise, in the range [0, 1).
+// Each octave has double the frequency and half the weight of the previous one.
+func (n *Noise) Fractal(x, y float64, octaves int) float64 {
+	sum, weight, total := 0.0, 1.0, 0.0
+	for i := 0; i < octaves; i++ {
+		sum += n.Value(x, y) * weight
+		total += weight
+		x, y = x*2, y*2
+		weight /= 2
+	}
+	return sum / total
+}
+
+// hash returns a random value in the range [0, 1) for integer coordinates.
+func (n *Noise) hash(x, y int64) float64 {
+	h := n.seed ^ uint64(x)*0x9E3779B97F4A7C15 ^ uint64(y)*0xC2B2AE3D27D4EB4F
+	h ^= h >> 30
+	h *= 0xBF58476D1CE4E5B9
+	h ^= h >> 27
+	h *= 0x94D049BB133111EB
+	h ^= h >> 31
+	return float64(h>>11) / float64(1<<53)
+}
+
+func smoothStep(t float64) float64 {
+	return t * t * (3 - 2*t)
+}
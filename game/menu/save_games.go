@@ -0,0 +1,144 @@
+package menu
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	stdimage "image"
+	"image/png"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ebitenui/ebitenui/widget"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/mlange-42/tiny-world/game/res"
+	"github.com/mlange-42/tiny-world/game/resource"
+	"github.com/mlange-42/tiny-world/game/save"
+)
+
+// Minimum size of save game thumbnails in tooltips. Small thumbnails are scaled up.
+const thumbnailSize = 128
+
+// saveGameOrder is a sort order for save games in the load panel.
+type saveGameOrder struct {
+	Name    string
+	Compare func(a, b *save.SaveGame) int
+}
+
+// saveGameOrders are the sort orders of the load panel, cycled by the sort button.
+// Orders other than by name put the largest values first.
+var saveGameOrders = []saveGameOrder{
+	{"date", func(a, b *save.SaveGame) int { return b.Time.Compare(a.Time) }},
+	{"name", func(a, b *save.SaveGame) int {
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	}},
+	{"play time", func(a, b *save.SaveGame) int { return cmp.Compare(b.Info.PlayTime, a.Info.PlayTime) }},
+	{"population", func(a, b *save.SaveGame) int { return cmp.Compare(b.Info.Population, a.Info.Population) }},
+	{"buildings", func(a, b *save.SaveGame) int { return cmp.Compare(b.Info.Buildings, a.Info.Buildings) }},
+}
+
+// filterSaveGames returns the worlds with the filter text in their name or scenario, sorted in the given order.
+func filterSaveGames(games []save.SaveGame, filter string, order *saveGameOrder) []save.SaveGame {
+	filter = strings.ToLower(strings.TrimSpace(filter))
+	result := []save.SaveGame{}
+	for _, g := range games {
+		if strings.Contains(strings.ToLower(g.Name), filter) || strings.Contains(strings.ToLower(g.Info.Scenario), filter) {
+			result = append(result, g)
+		}
+	}
+	slices.SortStableFunc(result, func(a, b save.SaveGame) int {
+		return order.Compare(&a, &b)
+	})
+	return result
+}
+
+func (ui *UI) createSaveGameTooltip(game *save.SaveGame, fonts *res.Fonts) *widget.ToolTip {
+	container := widget.NewContainer(
+		widget.ContainerOpts.Layout(widget.NewRowLayout(
+			widget.RowLayoutOpts.Direction(widget.DirectionVertical),
+			widget.RowLayoutOpts.Padding(&widget.Insets{Top: 6, Bottom: 6, Left: 12, Right: 12}),
+			widget.RowLayoutOpts.Spacing(6),
+		)),
+		widget.ContainerOpts.AutoDisableChildren(),
+		widget.ContainerOpts.BackgroundImage(ui.background),
+	)
+
+	if img := thumbnailImage(game.Info.Thumbnail); img != nil {
+		container.AddChild(widget.NewGraphic(
+			widget.GraphicOpts.Image(img),
+			widget.GraphicOpts.WidgetOpts(
+				widget.WidgetOpts.LayoutData(widget.RowLayoutData{
+					Position: widget.RowLayoutPositionCenter,
+				}),
+			),
+		))
+	}
+
+	container.AddChild(widget.NewText(
+		widget.TextOpts.Text(saveGameSummary(game), &fonts.Default, ui.sprites.TextColor),
+		widget.TextOpts.Position(widget.TextPositionStart, widget.TextPositionCenter),
+		widget.TextOpts.MaxWidth(360),
+	))
+
+	return widget.NewToolTip(
+		widget.ToolTipOpts.Content(container),
+		widget.ToolTipOpts.Offset(stdimage.Point{-5, 5}),
+		widget.ToolTipOpts.Position(widget.TOOLTIP_POS_WIDGET),
+		widget.ToolTipOpts.Delay(time.Millisecond*300),
+	)
+}
+
+func saveGameSummary(game *save.SaveGame) string {
+	info := &game.Info
+	text := game.Name
+	if game.Slot != "" {
+		text = fmt.Sprintf("%s, %s", save.WorldName(game.Name), save.SlotLabel(game.Slot))
+	}
+	text += fmt.Sprintf("\nSaved: %s", game.Time.Local().Format("2006-01-02 15:04"))
+	if info.Resources == nil {
+		// Saved by an older version, without summary.
+		return text
+	}
+	if info.Scenario != "" {
+		text += fmt.Sprintf("\nScenario: %s", info.Scenario)
+	}
+	text += fmt.Sprintf("\nPlay time: %s", formatPlayTime(info.PlayTime))
+	text += fmt.Sprintf("\nPopulation: %d", info.Population)
+	text += fmt.Sprintf("\nBuildings: %d", info.Buildings)
+
+	resources := []string{}
+	for i := range resource.Properties {
+		name := resource.Properties[i].Name
+		if amount, ok := info.Resources[name]; ok {
+			resources = append(resources, fmt.Sprintf("%s %d", name, amount))
+		}
+	}
+	text += fmt.Sprintf("\nStock: %s", strings.Join(resources, ", "))
+	return text
+}
+
+func formatPlayTime(d time.Duration) string {
+	d = d.Round(time.Second)
+	return fmt.Sprintf("%d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+}
+
+// thumbnailImage decodes a save game thumbnail, and scales it up to at least [thumbnailSize].
+// Returns nil if there is no valid thumbnail.
+func thumbnailImage(data []byte) *ebiten.Image {
+	if len(data) == 0 {
+		return nil
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+	size := img.Bounds().Size()
+	scale := max(1, thumbnailSize/max(size.X, size.Y))
+
+	result := ebiten.NewImage(size.X*scale, size.Y*scale)
+	op := ebiten.DrawImageOptions{}
+	op.GeoM.Scale(float64(scale), float64(scale))
+	result.DrawImage(ebiten.NewImageFromImage(img), &op)
+	return result
+}
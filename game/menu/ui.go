@@ -7,10 +7,10 @@ import (
 	"io/fs"
 	"log"
 	"math"
-	"math/rand"
 	"os"
 	"runtime"
 	"slices"
+	"strconv"
 	"time"
 
 	"github.com/ebitenui/ebitenui"
@@ -28,11 +28,14 @@ import (
 const panelWidth = 500
 const panelHeight = 400
 
-type startFunction = func(name string, mapLoc save.MapLocation, loadType save.LoadType, isEditor bool)
+type startFunction = func(name string, mapLoc save.MapLocation, loadType save.LoadType, seed uint64, gen res.WorldGen, isEditor bool)
 type menuFunction = func(tab int)
 
 const editorModeText = "Shift+click for scenario editor mode."
 
+// Side lengths of generated worlds selectable in the menu. Zero for a single start tile.
+var worldSizes = []int{0, 32, 48, 64, 96, 128, 192, 256}
+
 type UI struct {
 	fs         fs.FS
 	saveFolder string
@@ -184,7 +187,8 @@ func NewUI(f fs.FS, folder, mapsFolder string, selectedTab int, sprts *res.Sprit
 
 	rootContainer.AddChild(menuContainer)
 
-	t1, t2 := ui.drawRandomSprites()
+	rng := res.NewRand(res.RandomSeed())
+	t1, t2 := ui.drawRandomSprites(&rng)
 
 	rootGrid.AddChild(ui.createIconContainer(t1))
 	rootGrid.AddChild(rootContainer)
@@ -238,7 +242,7 @@ func (ui *UI) createMainMenuPanel(games []save.SaveGame, fonts *res.Fonts, start
 	continueButton := ui.createMainMenuButton(text, fonts,
 		func(args *widget.ButtonClickedEventArgs) {
 			if enabled {
-				start(games[0].Name, save.MapLocation{}, save.LoadTypeGame, false)
+				start(games[0].Name, save.MapLocation{}, save.LoadTypeGame, 0, res.WorldGen{}, false)
 			}
 		})
 	continueButton.GetWidget().Disabled = !enabled
@@ -381,31 +385,32 @@ func (ui *UI) createNewWorldPanel(games []save.SaveGame, fonts *res.Fonts, start
 	newLabel := ui.createMainMenuLabel("New World", fonts)
 	menuContainer.AddChild(newLabel)
 
-	newName := widget.NewTextInput(
-		widget.TextInputOpts.WidgetOpts(
-			widget.WidgetOpts.LayoutData(widget.RowLayoutData{
-				Position: widget.RowLayoutPositionCenter,
-				Stretch:  true,
-			}),
-		),
-		widget.TextInputOpts.Placeholder("World name"),
-		widget.TextInputOpts.Face(&fonts.Default),
-		widget.TextInputOpts.Image(&widget.TextInputImage{
-			Idle:     ui.background,
-			Disabled: ui.backgroundHover,
-		}),
-		widget.TextInputOpts.Color(&widget.TextInputColor{
-			Idle:          ui.sprites.TextColor,
-			Disabled:      ui.sprites.TextColor,
-			Caret:         ui.sprites.TextColor,
-			DisabledCaret: ui.sprites.TextColor,
-		}),
-		widget.TextInputOpts.Padding(widget.NewInsetsSimple(5)),
-		widget.TextInputOpts.CaretWidth(2),
-	)
-
+	newName := ui.createTextInput("World name", fonts)
 	menuContainer.AddChild(newName)
 
+	seed := ui.createTextInput("Seed (empty for random)", fonts)
+	seed.SetText(strconv.FormatUint(res.RandomSeed(), 10))
+	menuContainer.AddChild(seed)
+
+	defaults := res.NewWorldGen(0, 0)
+	sizeRow, size := ui.createParameterSlider(fonts, 0, len(worldSizes)-1, 0, func(v int) string {
+		if worldSizes[v] == 0 {
+			return "Size: single tile"
+		}
+		return fmt.Sprintf("Size: %dx%d", worldSizes[v], worldSizes[v])
+	})
+	menuContainer.AddChild(sizeRow)
+	waterRow, water := ui.createPercentSlider(fonts, "Water", 60, defaults.Water)
+	menuContainer.AddChild(waterRow)
+	hillsRow, hills := ui.createPercentSlider(fonts, "Hills", 50, defaults.Hills)
+	menuContainer.AddChild(hillsRow)
+	desertRow, desert := ui.createPercentSlider(fonts, "Desert", 50, defaults.Desert)
+	menuContainer.AddChild(desertRow)
+	forestRow, forest := ui.createPercentSlider(fonts, "Forest", 80, defaults.Forest)
+	menuContainer.AddChild(forestRow)
+	rocksRow, rocks := ui.createPercentSlider(fonts, "Rocks", 20, defaults.Rocks)
+	menuContainer.AddChild(rocksRow)
+
 	click := func(args *widget.ButtonClickedEventArgs) {
 		name := newName.GetText()
 		if len(name) == 0 {
@@ -413,7 +418,7 @@ func (ui *UI) createNewWorldPanel(games []save.SaveGame, fonts *res.Fonts, start
 			return
 		}
 		for _, g := range games {
-			if g.Name == name {
+			if save.WorldName(g.Name) == name {
 				ui.infoLabel.Label = "World already exists!"
 				return
 			}
@@ -422,8 +427,28 @@ func (ui *UI) createNewWorldPanel(games []save.SaveGame, fonts *res.Fonts, start
 			ui.infoLabel.Label = "Use only letters, numbers,\nspaces, '-' and '_'!"
 			return
 		}
+		seedValue := res.RandomSeed()
+		if seedText := seed.GetText(); len(seedText) > 0 {
+			var err error
+			if seedValue, err = strconv.ParseUint(seedText, 10, 64); err != nil {
+				ui.infoLabel.Label = "Seed must be a positive number!"
+				return
+			}
+		}
+		gen := res.WorldGen{}
+		if worldSizes[size.Current] > 0 {
+			gen = res.WorldGen{
+				Size:   worldSizes[size.Current],
+				Seed:   seedValue,
+				Water:  float64(water.Current) / 100,
+				Hills:  float64(hills.Current) / 100,
+				Desert: float64(desert.Current) / 100,
+				Forest: float64(forest.Current) / 100,
+				Rocks:  float64(rocks.Current) / 100,
+			}
+		}
 		isEditor := ebiten.IsKeyPressed(ebiten.KeyShift)
-		start(name, save.MapLocation{}, save.LoadTypeNone, isEditor)
+		start(name, save.MapLocation{}, save.LoadTypeNone, seedValue, gen, isEditor)
 	}
 
 	buttons, _ := ui.createBackStartButtons("New World", fonts, click)
@@ -444,6 +469,91 @@ func (ui *UI) createNewWorldPanel(games []save.SaveGame, fonts *res.Fonts, start
 	return menuContainer
 }
 
+// createPercentSlider creates a labeled slider for a fraction, in percent.
+func (ui *UI) createPercentSlider(fonts *res.Fonts, name string, maxPercent int, value float64) (*widget.Container, *widget.Slider) {
+	return ui.createParameterSlider(fonts, 0, maxPercent, int(math.Round(value*100)), func(v int) string {
+		return fmt.Sprintf("%s: %d%%", name, v)
+	})
+}
+
+// createParameterSlider creates a slider with a label that shows the formatted value.
+func (ui *UI) createParameterSlider(fonts *res.Fonts, minValue, maxValue, value int, format func(v int) string) (*widget.Container, *widget.Slider) {
+	container := widget.NewContainer(
+		widget.ContainerOpts.Layout(widget.NewGridLayout(
+			widget.GridLayoutOpts.Columns(2),
+			widget.GridLayoutOpts.Stretch([]bool{false, true}, []bool{true}),
+			widget.GridLayoutOpts.Spacing(12, 0),
+		)),
+		widget.ContainerOpts.WidgetOpts(
+			widget.WidgetOpts.LayoutData(widget.RowLayoutData{
+				Position: widget.RowLayoutPositionCenter,
+				Stretch:  true,
+			}),
+		),
+	)
+
+	label := widget.NewText(
+		widget.TextOpts.Text(format(value), &fonts.Default, ui.sprites.TextColor),
+		widget.TextOpts.Position(widget.TextPositionStart, widget.TextPositionCenter),
+		widget.TextOpts.WidgetOpts(
+			widget.WidgetOpts.MinSize(180, 0),
+		),
+	)
+	container.AddChild(label)
+
+	slider := widget.NewSlider(
+		widget.SliderOpts.Direction(widget.DirectionHorizontal),
+		widget.SliderOpts.MinMax(minValue, maxValue),
+		widget.SliderOpts.InitialCurrent(value),
+		widget.SliderOpts.FixedHandleSize(16),
+		widget.SliderOpts.WidgetOpts(
+			widget.WidgetOpts.MinSize(0, 24),
+		),
+		widget.SliderOpts.ChangedHandler(func(args *widget.SliderChangedEventArgs) {
+			label.Label = format(args.Current)
+		}),
+		widget.SliderOpts.Images(
+			&widget.SliderTrackImage{
+				Idle:  ui.background,
+				Hover: ui.background,
+			},
+			&widget.ButtonImage{
+				Idle:    ui.backgroundPressed,
+				Hover:   ui.backgroundHover,
+				Pressed: ui.backgroundPressed,
+			},
+		),
+	)
+	container.AddChild(slider)
+
+	return container, slider
+}
+
+func (ui *UI) createTextInput(placeholder string, fonts *res.Fonts) *widget.TextInput {
+	return widget.NewTextInput(
+		widget.TextInputOpts.WidgetOpts(
+			widget.WidgetOpts.LayoutData(widget.RowLayoutData{
+				Position: widget.RowLayoutPositionCenter,
+				Stretch:  true,
+			}),
+		),
+		widget.TextInputOpts.Placeholder(placeholder),
+		widget.TextInputOpts.Face(&fonts.Default),
+		widget.TextInputOpts.Image(&widget.TextInputImage{
+			Idle:     ui.background,
+			Disabled: ui.backgroundHover,
+		}),
+		widget.TextInputOpts.Color(&widget.TextInputColor{
+			Idle:          ui.sprites.TextColor,
+			Disabled:      ui.sprites.TextColor,
+			Caret:         ui.sprites.TextColor,
+			DisabledCaret: ui.sprites.TextColor,
+		}),
+		widget.TextInputOpts.Padding(widget.NewInsetsSimple(5)),
+		widget.TextInputOpts.CaretWidth(2),
+	)
+}
+
 func (ui *UI) createLoadPanel(games []save.SaveGame, fonts *res.Fonts,
 	start startFunction,
 	restart menuFunction) *widget.Container {
@@ -454,10 +564,39 @@ func (ui *UI) createLoadPanel(games []save.SaveGame, fonts *res.Fonts,
 
 	img := ui.defaultButtonImage()
 
-	scroll, content := ui.createScrollPanel(panelHeight - 76)
+	filter := ui.createTextInput("Filter by name or scenario", fonts)
+	order := 0
+	sortButton := widget.NewButton(
+		widget.ButtonOpts.Image(img),
+		widget.ButtonOpts.Text("Sort: "+saveGameOrders[order].Name, &fonts.Default, &widget.ButtonTextColor{
+			Idle:     ui.sprites.TextColor,
+			Disabled: ui.sprites.TextColor,
+		}),
+		widget.ButtonOpts.TextPadding(widget.NewInsetsSimple(5)),
+	)
+	filterContainer := widget.NewContainer(
+		widget.ContainerOpts.Layout(widget.NewGridLayout(
+			widget.GridLayoutOpts.Columns(2),
+			widget.GridLayoutOpts.Stretch([]bool{true, false}, []bool{false}),
+			widget.GridLayoutOpts.Spacing(6, 0),
+		)),
+		widget.ContainerOpts.WidgetOpts(
+			widget.WidgetOpts.LayoutData(widget.RowLayoutData{
+				Position: widget.RowLayoutPositionCenter,
+				Stretch:  true,
+			}),
+		),
+	)
+	filterContainer.AddChild(filter)
+	filterContainer.AddChild(sortButton)
+	menuContainer.AddChild(filterContainer)
 
-	buttons := make([]widget.RadioGroupElement, len(games))
-	for i, game := range games {
+	scroll, content := ui.createScrollPanel(panelHeight - 113)
+
+	// Worlds are followed by their autosave and emergency slots.
+	buttons := []widget.RadioGroupElement{}
+	entries := []save.SaveGame{}
+	addButton := func(game save.SaveGame, text string, toDelete []string) {
 		contextMenu := widget.NewContainer(
 			widget.ContainerOpts.Layout(widget.NewRowLayout(
 				widget.RowLayoutOpts.Direction(widget.DirectionVertical),
@@ -473,9 +612,10 @@ func (ui *UI) createLoadPanel(games []save.SaveGame, fonts *res.Fonts,
 					Stretch:  true,
 				}),
 				widget.WidgetOpts.ContextMenu(contextMenu),
+				widget.WidgetOpts.ToolTip(ui.createSaveGameTooltip(&game, fonts)),
 			),
 			widget.ButtonOpts.Image(img),
-			widget.ButtonOpts.Text(game.Name, &fonts.Default, &widget.ButtonTextColor{
+			widget.ButtonOpts.Text(text, &fonts.Default, &widget.ButtonTextColor{
 				Idle:     ui.sprites.TextColor,
 				Disabled: ui.sprites.TextColor,
 			}),
@@ -483,7 +623,8 @@ func (ui *UI) createLoadPanel(games []save.SaveGame, fonts *res.Fonts,
 			widget.ButtonOpts.ToggleMode(),
 		)
 		content.AddChild(gameButton)
-		buttons[i] = gameButton
+		buttons = append(buttons, gameButton)
+		entries = append(entries, game)
 
 		deleteButton := widget.NewButton(
 			widget.ButtonOpts.WidgetOpts(
@@ -494,15 +635,17 @@ func (ui *UI) createLoadPanel(games []save.SaveGame, fonts *res.Fonts,
 				widget.WidgetOpts.ContextMenu(contextMenu),
 			),
 			widget.ButtonOpts.Image(img),
-			widget.ButtonOpts.Text(fmt.Sprintf("Delete '%s'", game), &fonts.Default, &widget.ButtonTextColor{
+			widget.ButtonOpts.Text(fmt.Sprintf("Delete '%s'", text), &fonts.Default, &widget.ButtonTextColor{
 				Idle:     ui.sprites.TextColor,
 				Disabled: ui.sprites.TextColor,
 			}),
 			widget.ButtonOpts.TextPadding(widget.NewInsetsSimple(5)),
 			widget.ButtonOpts.ClickedHandler(func(args *widget.ButtonClickedEventArgs) {
-				if err := deleteGame(ui.saveFolder, game.Name); err != nil {
-					ui.infoLabel.Label = err.Error()
-					return
+				for _, name := range toDelete {
+					if err := deleteGame(ui.saveFolder, name); err != nil {
+						ui.infoLabel.Label = err.Error()
+						return
+					}
 				}
 				menuContainer.RemoveChild(gameButton)
 				restart(ui.selectedTab)
@@ -511,16 +654,44 @@ func (ui *UI) createLoadPanel(games []save.SaveGame, fonts *res.Fonts,
 		contextMenu.AddChild(deleteButton)
 	}
 
-	ui.loadButtonsGroup = widget.NewRadioGroup(
-		widget.RadioGroupOpts.Elements(buttons...),
-	)
+	updateList := func() {
+		content.RemoveChildren()
+		buttons, entries = []widget.RadioGroupElement{}, []save.SaveGame{}
+		for _, game := range filterSaveGames(games, filter.GetText(), &saveGameOrders[order]) {
+			// Deleting a world also deletes its slots.
+			toDelete := []string{game.Name}
+			for _, slot := range game.Slots {
+				toDelete = append(toDelete, slot.Name)
+			}
+			addButton(game, game.Name, toDelete)
+			for _, slot := range game.Slots {
+				text := fmt.Sprintf("  %s, %s", save.SlotLabel(slot.Slot), slot.Time.Local().Format("2006-01-02 15:04"))
+				addButton(slot, text, []string{slot.Name})
+			}
+		}
+
+		ui.loadButtonsGroup = widget.NewRadioGroup(
+			widget.RadioGroupOpts.Elements(buttons...),
+		)
+	}
+	updateList()
+
+	filter.ChangedEvent.AddHandler(func(args any) { updateList() })
+	sortButton.ClickedEvent.AddHandler(func(args any) {
+		order = (order + 1) % len(saveGameOrders)
+		sortButton.Text().Label = "Sort: " + saveGameOrders[order].Name
+		updateList()
+	})
 
 	menuContainer.AddChild(scroll)
 
 	btn, _ := ui.createBackStartButtons("Load World", fonts,
 		func(args *widget.ButtonClickedEventArgs) {
 			idx := slices.Index(buttons, ui.loadButtonsGroup.Active())
-			start(games[idx].Name, save.MapLocation{}, save.LoadTypeGame, false)
+			if idx < 0 {
+				return
+			}
+			start(entries[idx].Name, save.MapLocation{}, save.LoadTypeGame, 0, res.WorldGen{}, false)
 		},
 	)
 	menuContainer.AddChild(btn)
@@ -576,7 +747,8 @@ func (ui *UI) createScenariosPanel(games []save.SaveGame, achievements *achievem
 	for _, m := range maps {
 		ach, err := save.LoadMapData(ui.fs, ui.mapsFolder, m)
 		if err != nil {
-			log.Fatalf("error loading achievements for map %s: %s", m.Name, err.Error())
+			log.Printf("WARNING: Skipping map '%s': %s", m.Name, err.Error())
+			continue
 		}
 
 		enabled := true
@@ -637,6 +809,17 @@ func (ui *UI) createScenariosPanel(games []save.SaveGame, achievements *achievem
 			localText = " (*local)"
 			localMarker = " (*)"
 		}
+		title := m.Name
+		if len(ach.Title) > 0 {
+			title = ach.Title
+		}
+		info := ""
+		if len(ach.Author) > 0 {
+			info += fmt.Sprintf("\nby %s", ach.Author)
+		}
+		if len(ach.Difficulty) > 0 {
+			info += fmt.Sprintf("\nDifficulty: %s", ach.Difficulty)
+		}
 		description := ""
 		if len(ach.Description) > 0 {
 			description = ach.Description + "\n\n"
@@ -644,7 +827,7 @@ func (ui *UI) createScenariosPanel(games []save.SaveGame, achievements *achievem
 
 		label := widget.NewText(
 			widget.TextOpts.ProcessBBCode(true),
-			widget.TextOpts.Text(fmt.Sprintf("%s%s\n\n%sRequired achievements:\n%s", m.Name, localText, description, achieve),
+			widget.TextOpts.Text(fmt.Sprintf("%s%s%s\n\n%sRequired achievements:\n%s", title, localText, info, description, achieve),
 				&fonts.Default, ui.sprites.TextColor),
 			widget.TextOpts.Position(widget.TextPositionStart, widget.TextPositionCenter),
 			widget.TextOpts.MaxWidth(360),
@@ -696,7 +879,7 @@ func (ui *UI) createScenariosPanel(games []save.SaveGame, achievements *achievem
 				return
 			}
 			for _, g := range games {
-				if g.Name == name {
+				if save.WorldName(g.Name) == name {
 					ui.infoLabel.Label = "World already exists!"
 					return
 				}
@@ -706,7 +889,7 @@ func (ui *UI) createScenariosPanel(games []save.SaveGame, achievements *achievem
 				return
 			}
 			isEditor := ebiten.IsKeyPressed(ebiten.KeyShift)
-			start(name, mapsUnlocked[idx], save.LoadTypeMap, isEditor)
+			start(name, mapsUnlocked[idx], save.LoadTypeMap, res.RandomSeed(), res.WorldGen{}, isEditor)
 		},
 	)
 	if cntEnabled == 0 {
@@ -887,15 +1070,17 @@ func (ui *UI) createTerrainImage(t terr.Terrain, tileIdx int, scale int) *ebiten
 	idx := ui.sprites.GetTerrainIndex(t)
 	subIdx := ui.sprites.GetMultiTileIndex(idx, terr.Directions(tileIdx), 0, 0)
 	sp1 := ui.sprites.GetSprite(subIdx)
+	iconScale := ui.sprites.IconScale(sp1)
 	op := ebiten.DrawImageOptions{}
-	op.GeoM.Translate(0, float64(ui.sprites.TileWidth-sp1.Bounds().Dy()-height))
+	op.GeoM.Scale(iconScale, iconScale)
+	op.GeoM.Translate(0, float64(ui.sprites.TileWidth-height)-float64(sp1.Bounds().Dy())*iconScale)
 	op.GeoM.Scale(float64(scale), float64(scale))
 	img.DrawImage(sp1, &op)
 
 	return img
 }
 
-func (ui *UI) drawRandomSprites() (terr.Terrain, terr.Terrain) {
+func (ui *UI) drawRandomSprites(rng *res.Rand) (terr.Terrain, terr.Terrain) {
 	candidates := []terr.Terrain{}
 
 	for i := range terr.Properties {
@@ -904,7 +1089,7 @@ func (ui *UI) drawRandomSprites() (terr.Terrain, terr.Terrain) {
 			candidates = append(candidates, terr.Terrain(i))
 		}
 	}
-	return candidates[rand.Intn(len(candidates))], candidates[rand.Intn(len(candidates))]
+	return candidates[rng.IntN(len(candidates))], candidates[rng.IntN(len(candidates))]
 }
 
 func (ui *UI) createScrollPanel(height int) (*widget.Container, *widget.Container) {
@@ -5,7 +5,6 @@ import (
 	"image"
 
 	"github.com/mlange-42/tiny-world/game/comp"
-	"github.com/mlange-42/tiny-world/game/math"
 	"github.com/mlange-42/tiny-world/game/res"
 	"github.com/mlange-42/tiny-world/game/terr"
 )
@@ -17,32 +16,62 @@ type Score struct {
 
 type AStar struct {
 	landUse *res.LandUse
+	load    *res.Grid[uint8]
+
+	// Search state, reused between searches.
+	open        PriorityQueue
+	cameFrom    map[comp.Tile]comp.Tile
+	gScore      map[comp.Tile]int
+	targetRects []image.Rectangle
 }
 
-func NewAStar(landUse *res.LandUse) AStar {
+// NewAStar creates a new AStar for the given land use.
+// The load is the number of haulers per tile, for congestion. It may be nil.
+func NewAStar(landUse *res.LandUse, load *res.Grid[uint8]) AStar {
 	return AStar{
-		landUse: landUse,
+		landUse:  landUse,
+		load:     load,
+		open:     NewPriorityQueue(),
+		cameFrom: map[comp.Tile]comp.Tile{},
+		gScore:   map[comp.Tile]int{},
 	}
 }
 
-func (a *AStar) FindPath(start, target comp.Tile) ([]comp.Tile, bool) {
-	initialDist := math.AbsInt(start.X-target.X) + math.AbsInt(start.Y-target.Y)
+// reset clears the search state.
+func (a *AStar) reset() {
+	a.open.Reset()
+	clear(a.cameFrom)
+	clear(a.gScore)
+}
 
-	open := NewPriorityQueue()
-	heap.Init(&open)
-	heap.Push(&open, Score{start, initialDist})
+// FindPath finds the path with the shortest travel time from start to target.
+// The returned path starts at the target and ends at the start.
+// For multi-tile buildings, the path may start and end at any of their cells.
+//
+// The path is appended to the given buffer, which may be nil.
+func (a *AStar) FindPath(start, target comp.Tile, path []comp.Tile) ([]comp.Tile, bool) {
+	// Lower bound of the cost of a step, for an admissible heuristic.
+	minStep := 2 * int(terr.MinMoveCost)
+	startRect := a.landUse.Footprint(start.X, start.Y)
+	targetRect := a.landUse.Footprint(target.X, target.Y)
 
-	cameFrom := map[comp.Tile]comp.Tile{}
-	gScore := map[comp.Tile]int{}
-	gScore[start] = 0
+	a.reset()
+	open, cameFrom, gScore := &a.open, a.cameFrom, a.gScore
+	for x := startRect.Min.X; x < startRect.Max.X; x++ {
+		for y := startRect.Min.Y; y < startRect.Max.Y; y++ {
+			tile := comp.Tile{Point: image.Pt(x, y)}
+			heap.Push(open, Score{tile, distance(tile.Point, targetRect) * minStep})
+			gScore[tile] = 0
+		}
+	}
 
 	for open.Len() > 0 {
-		current := heap.Pop(&open).(Score)
-		if current.Tile == target {
-			return reconstruct(cameFrom, current.Tile), true
+		current := heap.Pop(open).(Score)
+		if current.Tile.In(targetRect) {
+			return reconstruct(cameFrom, current.Tile, path), true
 		}
 		luOld := a.landUse.Get(current.Tile.X, current.Tile.Y)
-		if current.Tile != start {
+		if !current.Tile.In(startRect) {
 			if !terr.Properties[luOld].TerrainBits.Contains(terr.IsPath) {
 				gScore[current.Tile] = 0
 				continue
@@ -55,20 +84,12 @@ func (a *AStar) FindPath(start, target comp.Tile) ([]comp.Tile, bool) {
 			if !a.landUse.Contains(xx, yy) {
 				continue
 			}
-			lu := a.landUse.Get(xx, yy)
-			if !(terr.Properties[lu].TerrainBits.Contains(terr.IsPath) ||
-				(terr.Properties[luOld].TerrainBits.Contains(terr.IsPath) && terr.Buildings.Contains(lu))) {
-				// Don's walk between buildings
-				continue
-			}
-			if terr.Properties[lu].TerrainBits.Contains(terr.IsBridge) &&
-				terr.Properties[luOld].TerrainBits.Contains(terr.IsBridge) {
-				// Don's walk between bridges
+			if !canStep(luOld, a.landUse.Get(xx, yy)) {
 				continue
 			}
 
 			other := comp.Tile{Point: image.Pt(xx, yy)}
-			heur := gScore[current.Tile] + math.AbsInt(dx) + math.AbsInt(dy)
+			heur := gScore[current.Tile] + StepCost(a.landUse, a.load, current.Tile, other)
 
 			otherScore := 999999999
 			if sc, ok := gScore[other]; ok {
@@ -77,22 +98,142 @@ func (a *AStar) FindPath(start, target comp.Tile) ([]comp.Tile, bool) {
 			if heur < otherScore {
 				cameFrom[other] = current.Tile
 
-				fSc := heur + math.AbsInt(other.X-target.X) + math.AbsInt(other.Y-target.Y)
+				fSc := heur + distance(other.Point, targetRect)*minStep
 				gScore[other] = heur
 				if open.Contains(other) {
 					open.Update(other, fSc)
 				} else {
-					heap.Push(&open, Score{other, fSc})
+					heap.Push(open, Score{other, fSc})
 				}
 			}
 		}
 	}
 
-	return nil, false
+	return path, false
+}
+
+// FindCosts finds the travel costs from start to each of the targets, in a single search.
+// The costs are the same as [AStar.PathCost] of the paths found by [AStar.FindPath].
+// Costs of targets that can't be reached are -1.
+//
+// The search only ends when all targets are found, or when all reachable tiles are visited.
+// Targets should therefore be checked for reachability before, see [Network.Reachable].
+func (a *AStar) FindCosts(start comp.Tile, targets []comp.Tile, costs []int) []int {
+	startRect := a.landUse.Footprint(start.X, start.Y)
+
+	costs = costs[:0]
+	a.targetRects = a.targetRects[:0]
+	for _, t := range targets {
+		costs = append(costs, -1)
+		a.targetRects = append(a.targetRects, a.landUse.Footprint(t.X, t.Y))
+	}
+	targetRects := a.targetRects
+	remaining := len(targets)
+
+	a.reset()
+	open, gScore := &a.open, a.gScore
+	for x := startRect.Min.X; x < startRect.Max.X; x++ {
+		for y := startRect.Min.Y; y < startRect.Max.Y; y++ {
+			tile := comp.Tile{Point: image.Pt(x, y)}
+			heap.Push(open, Score{tile, 0})
+			gScore[tile] = 0
+		}
+	}
+
+	for open.Len() > 0 && remaining > 0 {
+		current := heap.Pop(open).(Score)
+		for i, rect := range targetRects {
+			if costs[i] < 0 && current.Tile.In(rect) {
+				costs[i] = current.Score
+				remaining--
+			}
+		}
+		luOld := a.landUse.Get(current.Tile.X, current.Tile.Y)
+		if !current.Tile.In(startRect) && !terr.Properties[luOld].TerrainBits.Contains(terr.IsPath) {
+			continue
+		}
+
+		for dir := terr.Direction(0); dir < terr.EndDirection; dir++ {
+			dx, dy := dir.Deltas()
+			xx, yy := current.Tile.X+dx, current.Tile.Y+dy
+			if !a.landUse.Contains(xx, yy) {
+				continue
+			}
+			if !canStep(luOld, a.landUse.Get(xx, yy)) {
+				continue
+			}
+
+			other := comp.Tile{Point: image.Pt(xx, yy)}
+			score := current.Score + StepCost(a.landUse, a.load, current.Tile, other)
+			if sc, ok := gScore[other]; ok && sc <= score {
+				continue
+			}
+			gScore[other] = score
+			if open.Contains(other) {
+				open.Update(other, score)
+			} else {
+				heap.Push(open, Score{other, score})
+			}
+		}
+	}
+
+	return costs
+}
+
+// canStep checks whether haulers can step from a tile with the given land use to a neighbor.
+func canStep(from, to terr.Terrain) bool {
+	if !(terr.Properties[to].TerrainBits.Contains(terr.IsPath) ||
+		(terr.Properties[from].TerrainBits.Contains(terr.IsPath) && terr.Buildings.Contains(to))) {
+		// Don't walk between buildings
+		return false
+	}
+	if terr.Properties[to].TerrainBits.Contains(terr.IsBridge) &&
+		terr.Properties[from].TerrainBits.Contains(terr.IsBridge) {
+		// Don't walk between bridges
+		return false
+	}
+	return true
+}
+
+// PathCost returns the travel cost of a path, as minimized by [AStar.FindPath].
+func (a *AStar) PathCost(path []comp.Tile) int {
+	cost := 0
+	for i := 1; i < len(path); i++ {
+		cost += StepCost(a.landUse, a.load, path[i], path[i-1])
+	}
+	return cost
 }
 
-func reconstruct(cameFrom map[comp.Tile]comp.Tile, current comp.Tile) []comp.Tile {
-	path := []comp.Tile{current}
+// StepCost returns the cost of a step between two neighboring tiles.
+// Half of the step is on each of the tiles, so it is the sum of their move costs.
+// The load is the number of haulers per tile, for congestion. It may be nil.
+func StepCost(landUse *res.LandUse, load *res.Grid[uint8], from, to comp.Tile) int {
+	return moveCost(landUse, load, from) + moveCost(landUse, load, to)
+}
+
+// moveCost returns the move cost of a tile.
+// With congestion, tiles over capacity are slower in proportion to their load.
+func moveCost(landUse *res.LandUse, load *res.Grid[uint8], tile comp.Tile) int {
+	props := &terr.Properties[landUse.Get(tile.X, tile.Y)]
+	cost := int(props.MoveCost)
+	if load == nil || props.Capacity == 0 {
+		return cost
+	}
+	if l := load.Get(tile.X, tile.Y); l > props.Capacity {
+		return cost * int(l) / int(props.Capacity)
+	}
+	return cost
+}
+
+// distance returns the Manhattan distance from a tile to the nearest cell of a rectangle.
+func distance(p image.Point, rect image.Rectangle) int {
+	dx := max(rect.Min.X-p.X, 0, p.X-(rect.Max.X-1))
+	dy := max(rect.Min.Y-p.Y, 0, p.Y-(rect.Max.Y-1))
+	return dx + dy
+}
+
+func reconstruct(cameFrom map[comp.Tile]comp.Tile, current comp.Tile, path []comp.Tile) []comp.Tile {
+	path = append(path, current)
 	for {
 		if v, ok := cameFrom[current]; ok {
 			current = v
@@ -182,6 +323,12 @@ func (pq *PriorityQueue) Pop() any {
 	return item
 }
 
+// Reset removes all items from the queue.
+func (pq *PriorityQueue) Reset() {
+	pq.score = pq.score[:0]
+	clear(pq.tiles)
+}
+
 // Update modifies the priority and value of an Item in the queue.
 func (pq *PriorityQueue) Update(tile comp.Tile, priority int) {
 	idx := pq.tiles[tile]
@@ -0,0 +1,194 @@
+package nav
+
+import (
+	"image"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/mlange-42/tiny-world/game/comp"
+	"github.com/mlange-42/tiny-world/game/res"
+	"github.com/mlange-42/tiny-world/game/resource"
+	"github.com/mlange-42/tiny-world/game/terr"
+)
+
+func TestMain(m *testing.M) {
+	data := os.DirFS("../..")
+	resource.Prepare(data, "data/json/resources.json")
+	terr.Prepare(data, "data/json/terrain.json")
+	os.Exit(m.Run())
+}
+
+// newLandUse creates land use from rows of symbols, with '.' for empty cells.
+// Multi-tile buildings repeat their symbol on all covered cells, like in maps.
+func newLandUse(rows ...string) res.LandUse {
+	symbols := map[rune]string{
+		'-': "path", '=': "road", 'b': "bridge", 'W': "warehouse", 'F': "farm", 'C': "castle",
+	}
+	landUse := res.NewLandUse(len(rows[0]), len(rows))
+	for y, row := range rows {
+		for x, sym := range row {
+			if sym != '.' && landUse.Get(x, y) == terr.Air {
+				landUse.SetFootprint(x, y, terr.ToTerrain(symbols[sym]))
+			}
+		}
+	}
+	return landUse
+}
+
+func tile(x, y int) comp.Tile {
+	return comp.Tile{Point: image.Pt(x, y)}
+}
+
+func TestAStar(t *testing.T) {
+	tests := []struct {
+		name          string
+		landUse       []string
+		moveCosts     map[string]uint8
+		load          map[image.Point]uint8
+		start, target comp.Tile
+		// Expected travel cost. -1 if the target can't be reached.
+		cost int
+		// Expected path length, including start and target.
+		length int
+	}{
+		{
+			name:    "straight path",
+			landUse: []string{"W---F"},
+			start:   tile(0, 0), target: tile(4, 0),
+			cost: 80, length: 5,
+		},
+		{
+			name:    "road detour",
+			landUse: []string{"W-----F", "======="},
+			start:   tile(0, 0), target: tile(6, 0),
+			cost: 90, length: 9,
+		},
+		{
+			name:    "path shorter than road detour",
+			landUse: []string{"W-F", "==="},
+			start:   tile(0, 0), target: tile(2, 0),
+			cost: 40, length: 3,
+		},
+		{
+			name:    "bridge",
+			landUse: []string{"W-b-F", "-----"},
+			start:   tile(0, 0), target: tile(4, 0),
+			cost: 80, length: 5,
+		},
+		{
+			name:      "expensive bridge",
+			landUse:   []string{"W-b-F", "-----"},
+			moveCosts: map[string]uint8{"bridge": 40},
+			start:     tile(0, 0), target: tile(4, 0),
+			cost: 120, length: 7,
+		},
+		{
+			name:    "congestion below detour",
+			landUse: []string{"W---F", "-----"},
+			load:    map[image.Point]uint8{{2, 0}: 6},
+			start:   tile(0, 0), target: tile(4, 0),
+			cost: 100, length: 5,
+		},
+		{
+			name:    "congestion above detour",
+			landUse: []string{"W---F", "-----"},
+			load:    map[image.Point]uint8{{2, 0}: 12},
+			start:   tile(0, 0), target: tile(4, 0),
+			cost: 120, length: 7,
+		},
+		{
+			name:    "load within capacity",
+			landUse: []string{"W---F"},
+			load:    map[image.Point]uint8{{1, 0}: 3, {2, 0}: 3, {3, 0}: 3},
+			start:   tile(0, 0), target: tile(4, 0),
+			cost: 80, length: 5,
+		},
+		{
+			name:    "between bridges",
+			landUse: []string{"W-bb-F"},
+			start:   tile(0, 0), target: tile(5, 0),
+			cost: -1,
+		},
+		{
+			name:    "multi-tile target",
+			landUse: []string{"W--CC", "...CC"},
+			start:   tile(0, 0), target: tile(4, 1),
+			cost: 60, length: 4,
+		},
+		{
+			name:    "multi-tile start",
+			landUse: []string{"CC--W", "CC..."},
+			start:   tile(0, 1), target: tile(4, 0),
+			cost: 60, length: 4,
+		},
+		{
+			name:    "gap",
+			landUse: []string{"W-.-F"},
+			start:   tile(0, 0), target: tile(4, 0),
+			cost: -1,
+		},
+		{
+			name:    "between buildings",
+			landUse: []string{"WF"},
+			start:   tile(0, 0), target: tile(1, 0),
+			cost: -1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, cost := range tt.moveCosts {
+				props := &terr.Properties[terr.ToTerrain(name)]
+				defaultCost := props.MoveCost
+				props.MoveCost = cost
+				defer func() { props.MoveCost = defaultCost }()
+			}
+			landUse := newLandUse(tt.landUse...)
+			var load *res.Grid[uint8]
+			if tt.load != nil {
+				grid := res.NewGrid[uint8](landUse.Width(), landUse.Height())
+				for p, l := range tt.load {
+					grid.Set(p.X, p.Y, l)
+				}
+				load = &grid
+			}
+			aStar := NewAStar(&landUse, load)
+
+			// A single search for all targets finds the same costs as the paths.
+			costs := aStar.FindCosts(tt.start, []comp.Tile{tt.target, tt.start}, nil)
+			if costs[0] != tt.cost {
+				t.Errorf("FindCosts() = %d, want %d", costs[0], tt.cost)
+			}
+			if costs[1] != 0 {
+				t.Errorf("FindCosts() for the start = %d, want 0", costs[1])
+			}
+
+			path, ok := aStar.FindPath(tt.start, tt.target, nil)
+			if ok != (tt.cost >= 0) {
+				t.Fatalf("FindPath() found = %v, want %v", ok, tt.cost >= 0)
+			}
+			if !ok {
+				return
+			}
+			if got := aStar.PathCost(path); got != tt.cost {
+				t.Errorf("PathCost() = %d, want %d, path %v", got, tt.cost, path)
+			}
+			if len(path) != tt.length {
+				t.Errorf("FindPath() has length %d, want %d, path %v", len(path), tt.length, path)
+			}
+			// Paths start and end at the closest tiles of multi-tile buildings.
+			if !path[0].In(landUse.Footprint(tt.target.X, tt.target.Y)) ||
+				!path[len(path)-1].In(landUse.Footprint(tt.start.X, tt.start.Y)) {
+				t.Errorf("FindPath() = %v, want path from %v to %v", path, tt.target, tt.start)
+			}
+
+			// Repeated searches reuse the buffers, and the given path.
+			want := slices.Clone(path)
+			again, _ := aStar.FindPath(tt.start, tt.target, path[:0])
+			if !slices.Equal(again, want) || &again[0] != &path[0] {
+				t.Errorf("repeated FindPath() = %v, want %v in the same buffer", again, want)
+			}
+		})
+	}
+}
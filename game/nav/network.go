@@ -0,0 +1,279 @@
+package nav
+
+import (
+	"image"
+
+	"github.com/mlange-42/tiny-world/game/comp"
+	"github.com/mlange-42/tiny-world/game/res"
+	"github.com/mlange-42/tiny-world/game/terr"
+)
+
+// Network resource.
+// Caches navigation over the path network, for use by haulers.
+//
+// Path tiles are labelled by connected component, so that unreachable targets are rejected without a search.
+// Routes found by [AStar.FindPath] and costs found by [AStar.FindCosts] are cached.
+// All data is rebuilt lazily when the [res.PathVersion] changed.
+// With congestion, routes are also discarded when the [res.Traffic] used for routing changed.
+type Network struct {
+	landUse *res.LandUse
+	version *res.PathVersion
+	traffic *res.Traffic
+	aStar   AStar
+
+	built      bool
+	builtFor   uint64
+	trafficFor uint64
+	labels     res.Grid[int32]
+	routes     map[routeKey]route
+	costs      map[routeKey]int
+	stack      []comp.Tile
+	path       []comp.Tile
+	arena      []comp.Tile
+	missing    []comp.Tile
+	found      []int
+	result     []int
+	tmpLabels  []int32
+	numLabels  int32
+}
+
+// arenaSize is the minimum number of tiles allocated at once for cached routes.
+const arenaSize = 1024
+
+type routeKey struct {
+	Start  comp.Tile
+	Target comp.Tile
+}
+
+type route struct {
+	Path []comp.Tile
+	Cost int
+	OK   bool
+}
+
+// NewNetwork creates a new Network for the given land use.
+// Traffic is only given for congestion, and nil otherwise.
+func NewNetwork(landUse *res.LandUse, version *res.PathVersion, traffic *res.Traffic) Network {
+	var load *res.Grid[uint8]
+	if traffic != nil {
+		load = &traffic.Routing
+	}
+	return Network{
+		landUse: landUse,
+		version: version,
+		traffic: traffic,
+		aStar:   NewAStar(landUse, load),
+		labels:  res.NewGrid[int32](landUse.Width(), landUse.Height()),
+		routes:  map[routeKey]route{},
+		costs:   map[routeKey]int{},
+	}
+}
+
+// FindPath returns the path with the shortest travel time from start to target.
+// The returned path starts at the target and ends at the start, see [AStar.FindPath].
+//
+// The returned path is shared by all callers and must not be modified.
+func (n *Network) FindPath(start, target comp.Tile) ([]comp.Tile, bool) {
+	path, _, ok := n.Route(start, target)
+	return path, ok
+}
+
+// Route returns the path with the shortest travel time from start to target, and its travel cost.
+// See [Network.FindPath] and [AStar.PathCost].
+func (n *Network) Route(start, target comp.Tile) ([]comp.Tile, int, bool) {
+	n.update()
+
+	key := routeKey{Start: start, Target: target}
+	if r, ok := n.routes[key]; ok {
+		return r.Path, r.Cost, r.OK
+	}
+
+	r := route{}
+	if n.reachable(start, target) {
+		n.path, r.OK = n.aStar.FindPath(start, target, n.path[:0])
+		if r.OK {
+			r.Path = n.store(n.path)
+			r.Cost = n.aStar.PathCost(r.Path)
+		}
+	}
+	n.routes[key] = r
+	return r.Path, r.Cost, r.OK
+}
+
+// store copies a path found into the arena, and returns the copy.
+// Haulers keep their paths, so the arena is never overwritten, but replaced when full.
+func (n *Network) store(path []comp.Tile) []comp.Tile {
+	if cap(n.arena)-len(n.arena) < len(path) {
+		n.arena = make([]comp.Tile, 0, max(arenaSize, len(path)))
+	}
+	start := len(n.arena)
+	n.arena = append(n.arena, path...)
+	return n.arena[start:len(n.arena):len(n.arena)]
+}
+
+// Costs returns the travel costs from start to each of the targets, as of [Network.Route].
+// Costs of unreachable targets are -1.
+// Costs that are not cached yet are found in a single search, instead of a route per target.
+//
+// The returned slice is reused by the next call.
+func (n *Network) Costs(start comp.Tile, targets []comp.Tile) []int {
+	n.update()
+
+	n.result = n.result[:0]
+	n.missing = n.missing[:0]
+	for _, target := range targets {
+		key := routeKey{Start: start, Target: target}
+		cost, ok := n.costs[key]
+		if !ok {
+			if r, found := n.routes[key]; found {
+				cost, ok = -1, true
+				if r.OK {
+					cost = r.Cost
+				}
+			} else if !n.reachable(start, target) {
+				cost, ok = -1, true
+				n.costs[key] = cost
+			}
+		}
+		if !ok {
+			// Marks the cost as missing, to be found by the search.
+			cost = -2
+			n.missing = append(n.missing, target)
+		}
+		n.result = append(n.result, cost)
+	}
+	if len(n.missing) == 0 {
+		return n.result
+	}
+
+	n.found = n.aStar.FindCosts(start, n.missing, n.found)
+	for i, target := range n.missing {
+		n.costs[routeKey{Start: start, Target: target}] = n.found[i]
+	}
+	for i, target := range targets {
+		if n.result[i] < -1 {
+			n.result[i] = n.costs[routeKey{Start: start, Target: target}]
+		}
+	}
+	return n.result
+}
+
+// Reachable checks whether the target can be reached from the start over the path network.
+func (n *Network) Reachable(start, target comp.Tile) bool {
+	n.update()
+	return n.reachable(start, target)
+}
+
+// reachable checks reachability based on component labels.
+// The rules are the same as in [AStar.FindPath]:
+// haulers start on any tile, walk over path tiles, and end on a path or building tile.
+func (n *Network) reachable(start, target comp.Tile) bool {
+	if start == target {
+		return true
+	}
+	n.tmpLabels = n.tmpLabels[:0]
+	if l := n.labels.Get(start.X, start.Y); l > 0 {
+		n.tmpLabels = append(n.tmpLabels, l)
+	} else {
+		n.tmpLabels = n.appendNeighborLabels(start, n.tmpLabels)
+	}
+	if len(n.tmpLabels) == 0 {
+		return false
+	}
+	numStart := len(n.tmpLabels)
+
+	if l := n.labels.Get(target.X, target.Y); l > 0 {
+		n.tmpLabels = append(n.tmpLabels, l)
+	} else if terr.Buildings.Contains(n.landUse.Get(target.X, target.Y)) {
+		n.tmpLabels = n.appendNeighborLabels(target, n.tmpLabels)
+	}
+
+	for _, s := range n.tmpLabels[:numStart] {
+		for _, t := range n.tmpLabels[numStart:] {
+			if s == t {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// appendNeighborLabels appends the labels of the path tiles around a tile.
+// For multi-tile buildings, these are the path tiles around the entire building.
+func (n *Network) appendNeighborLabels(tile comp.Tile, labels []int32) []int32 {
+	rect := n.landUse.Footprint(tile.X, tile.Y)
+	for x := rect.Min.X; x < rect.Max.X; x++ {
+		for y := rect.Min.Y; y < rect.Max.Y; y++ {
+			for dir := terr.Direction(0); dir < terr.EndDirection; dir++ {
+				dx, dy := dir.Deltas()
+				xx, yy := x+dx, y+dy
+				if !n.labels.Contains(xx, yy) {
+					continue
+				}
+				if l := n.labels.Get(xx, yy); l > 0 {
+					labels = append(labels, l)
+				}
+			}
+		}
+	}
+	return labels
+}
+
+// update rebuilds the network if the path network changed since the last build.
+func (n *Network) update() {
+	if n.traffic != nil && n.trafficFor != n.traffic.Version {
+		n.trafficFor = n.traffic.Version
+		clear(n.routes)
+		clear(n.costs)
+	}
+	if n.built && n.builtFor == n.version.Version {
+		return
+	}
+	n.built = true
+	n.builtFor = n.version.Version
+	clear(n.routes)
+	clear(n.costs)
+
+	n.labels.Fill(0)
+	n.numLabels = 0
+
+	width, height := n.landUse.Width(), n.landUse.Height()
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			if n.labels.Get(x, y) > 0 || !terr.Paths.Contains(n.landUse.Get(x, y)) {
+				continue
+			}
+			n.numLabels++
+			n.fill(comp.Tile{Point: image.Pt(x, y)}, n.numLabels)
+		}
+	}
+}
+
+// fill labels all path tiles connected to the given tile.
+func (n *Network) fill(tile comp.Tile, label int32) {
+	n.labels.Set(tile.X, tile.Y, label)
+	n.stack = append(n.stack[:0], tile)
+	for len(n.stack) > 0 {
+		current := n.stack[len(n.stack)-1]
+		n.stack = n.stack[:len(n.stack)-1]
+		isBridge := terr.Properties[n.landUse.Get(current.X, current.Y)].TerrainBits.Contains(terr.IsBridge)
+
+		for dir := terr.Direction(0); dir < terr.EndDirection; dir++ {
+			dx, dy := dir.Deltas()
+			xx, yy := current.X+dx, current.Y+dy
+			if !n.landUse.Contains(xx, yy) || n.labels.Get(xx, yy) > 0 {
+				continue
+			}
+			lu := n.landUse.Get(xx, yy)
+			if !terr.Paths.Contains(lu) {
+				continue
+			}
+			if isBridge && terr.Properties[lu].TerrainBits.Contains(terr.IsBridge) {
+				// Don't walk between bridges
+				continue
+			}
+			n.labels.Set(xx, yy, label)
+			n.stack = append(n.stack, comp.Tile{Point: image.Pt(xx, yy)})
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package nav
+
+import (
+	"testing"
+
+	"github.com/mlange-42/tiny-world/game/comp"
+	"github.com/mlange-42/tiny-world/game/res"
+	"github.com/mlange-42/tiny-world/game/terr"
+)
+
+func TestNetworkReachable(t *testing.T) {
+	tests := []struct {
+		name          string
+		landUse       []string
+		start, target comp.Tile
+		want          bool
+	}{
+		{
+			name:    "over bridge",
+			landUse: []string{"W-b-F"},
+			start:   tile(0, 0), target: tile(4, 0),
+			want: true,
+		},
+		{
+			name:    "between bridges",
+			landUse: []string{"W-bb-F"},
+			start:   tile(0, 0), target: tile(5, 0),
+			want: false,
+		},
+		{
+			name:    "start next to path",
+			landUse: []string{"W-F", "..."},
+			start:   tile(1, 1), target: tile(2, 0),
+			want: true,
+		},
+		{
+			name:    "empty target next to path",
+			landUse: []string{"W-."},
+			start:   tile(0, 0), target: tile(2, 0),
+			want: false,
+		},
+		{
+			name:    "multi-tile building",
+			landUse: []string{"W..CC", "---CC"},
+			start:   tile(0, 0), target: tile(3, 0),
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			landUse := newLandUse(tt.landUse...)
+			network := NewNetwork(&landUse, &res.PathVersion{}, nil)
+			if got := network.Reachable(tt.start, tt.target); got != tt.want {
+				t.Errorf("Reachable() = %v, want %v", got, tt.want)
+			}
+
+			// Reachability must agree with the search.
+			aStar := NewAStar(&landUse, nil)
+			if _, found := aStar.FindPath(tt.start, tt.target, nil); found != tt.want {
+				t.Errorf("FindPath() found = %v, want %v", found, tt.want)
+			}
+		})
+	}
+}
+
+func TestNetworkCache(t *testing.T) {
+	landUse := newLandUse("W-.-F", "-----")
+	version := res.PathVersion{}
+	traffic := res.NewTraffic(landUse.Width(), landUse.Height())
+	network := NewNetwork(&landUse, &version, &traffic)
+	path := terr.ToTerrain("path")
+	start, target := tile(0, 0), tile(4, 0)
+
+	// Steps are applied in order, to the same network.
+	steps := []struct {
+		name string
+		edit func()
+		// Expected travel cost. -1 if the target can't be reached.
+		cost int
+	}{
+		{
+			name: "initial",
+			edit: func() {},
+			cost: 120,
+		},
+		{
+			name: "path built without version update",
+			edit: func() { landUse.Set(2, 0, path) },
+			cost: 120,
+		},
+		{
+			name: "tree planted",
+			edit: func() { version.Update(terr.Air, terr.ToTerrain("tree")) },
+			cost: 120,
+		},
+		{
+			name: "path version updated",
+			edit: func() { version.Update(terr.Air, path) },
+			cost: 80,
+		},
+		{
+			name: "traffic changed without version update",
+			edit: func() { traffic.Routing.Set(2, 0, 12) },
+			cost: 80,
+		},
+		{
+			name: "traffic version updated",
+			edit: func() { traffic.Version++ },
+			cost: 120,
+		},
+		{
+			name: "paths removed",
+			edit: func() {
+				landUse.Set(0, 1, terr.Air)
+				landUse.Set(1, 1, terr.Air)
+				landUse.Set(2, 0, terr.Air)
+				version.Update(path, terr.Air)
+			},
+			cost: -1,
+		},
+	}
+
+	for _, st := range steps {
+		t.Run(st.name, func(t *testing.T) {
+			st.edit()
+
+			// Costs first, as they are looked up from cached routes otherwise.
+			if got := network.Costs(start, []comp.Tile{target})[0]; got != st.cost {
+				t.Errorf("Costs() = %d, want %d", got, st.cost)
+			}
+			p, cost, ok := network.Route(start, target)
+			if ok != (st.cost >= 0) {
+				t.Fatalf("Route() found = %v, want %v", ok, st.cost >= 0)
+			}
+			if reachable := network.Reachable(start, target); reachable != ok {
+				t.Errorf("Reachable() = %v, want %v", reachable, ok)
+			}
+			if !ok {
+				return
+			}
+			if cost != st.cost {
+				t.Errorf("Route() cost = %d, want %d", cost, st.cost)
+			}
+			if again, _, _ := network.Route(start, target); &again[0] != &p[0] {
+				t.Errorf("Route() did not return the cached path")
+			}
+		})
+	}
+}
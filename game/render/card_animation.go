@@ -59,6 +59,11 @@ func (s *CardAnimation) UpdateUI(world *ecs.World) {
 
 		idx := sprites.GetTerrainIndex(t)
 		sp := sprites.GetRand(idx, 0, rnd)
+		if scale := sprites.IconScale(sp); scale != 1 {
+			op.GeoM.Reset()
+			op.GeoM.Scale(scale, scale)
+			op.GeoM.Translate(float64(point.X), float64(point.Y+sprites.TileWidth)-float64(sp.Bounds().Dy())*scale)
+		}
 		img.DrawImage(sp, &op)
 	}
 
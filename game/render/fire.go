@@ -0,0 +1,75 @@
+package render
+
+import (
+	"image/color"
+	stdmath "math"
+
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"github.com/mlange-42/ark/ecs"
+	"github.com/mlange-42/tiny-world/game/comp"
+	"github.com/mlange-42/tiny-world/game/res"
+)
+
+// Fire is a system to render flames on burning land use.
+type Fire struct {
+	screen  ecs.Resource[res.Screen]
+	view    ecs.Resource[res.View]
+	time    ecs.Resource[res.GameTick]
+	landUse ecs.Resource[res.LandUse]
+
+	filter *ecs.Filter1[comp.Tile]
+}
+
+// InitializeUI the system
+func (s *Fire) InitializeUI(world *ecs.World) {
+	s.screen = s.screen.New(world)
+	s.view = s.view.New(world)
+	s.time = s.time.New(world)
+	s.landUse = s.landUse.New(world)
+
+	s.filter = s.filter.New(world).With(ecs.C[comp.Burning]())
+}
+
+// UpdateUI the system
+func (s *Fire) UpdateUI(world *ecs.World) {
+	view := s.view.Get()
+	landUse := s.landUse.Get()
+	canvas := s.screen.Get()
+	img := canvas.Image
+	tick := s.time.Get().RenderTick
+
+	off := view.Offset()
+	bounds := view.Bounds(canvas.Width, canvas.Height)
+	h := view.TileHeight / 2
+	z := float32(view.Zoom)
+
+	colOuter := color.RGBA{230, 90, 20, 200}
+	colInner := color.RGBA{255, 200, 40, 220}
+
+	query := s.filter.Query()
+	for query.Next() {
+		tile := query.Get()
+		rect := landUse.Footprint(tile.X, tile.Y)
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			for y := rect.Min.Y; y < rect.Max.Y; y++ {
+				point := view.TileToGlobal(x, y)
+				if !point.In(bounds) {
+					continue
+				}
+				// Flicker, with a different phase per cell.
+				flicker := float32(stdmath.Sin(float64(tick)*0.3 + float64(x*7+y*13)))
+
+				px := float32(point.X)*z - float32(off.X)
+				py := float32(point.Y-h)*z - float32(off.Y)
+				vector.DrawFilledCircle(img, px, py-2*z*flicker, (7+flicker)*z, colOuter, true)
+				vector.DrawFilledCircle(img, px, py+2*z-z*flicker, (4+flicker/2)*z, colInner, true)
+			}
+		}
+	}
+}
+
+// PostUpdateUI the system
+func (s *Fire) PostUpdateUI(world *ecs.World) {}
+
+// FinalizeUI the system
+func (s *Fire) FinalizeUI(world *ecs.World) {}
@@ -132,6 +132,15 @@ func (s *Terrain) UpdateUI(world *ecs.World) {
 		(s.landUse.Contains(cursor.X, cursor.Y) && terr.Properties[s.landUse.Get(cursor.X, cursor.Y)].BuildRadius > 0)
 	buildRadius := terr.Properties[sel.BuildType].BuildRadius
 
+	// The cursor of multi-tile buildings is drawn at the front-most cell, over all other cells.
+	cursorAt := cursor
+	if size := terr.Properties[sel.BuildType].Footprint; size != image.Pt(1, 1) {
+		origin, _ := s.footprintOrigin(cursor, sel.BuildType)
+		if front := origin.Add(size).Sub(image.Pt(1, 1)); front.In(mapBounds) {
+			cursorAt = front
+		}
+	}
+
 	for i := mapBounds.Min.X; i < mapBounds.Max.X; i++ {
 		for j := mapBounds.Min.Y; j < mapBounds.Max.Y; j++ {
 			point := s.view.TileToGlobal(i, j)
@@ -166,18 +175,18 @@ func (s *Terrain) UpdateUI(world *ecs.World) {
 			}
 
 			lu := s.landUse.Get(i, j)
-			if lu != terr.Air {
+			if luPoint, ok := s.landUseAnchor(i, j, lu, &point); lu != terr.Air && ok {
 				luE := s.landUseE.Get(i, j)
 				prod, cons, pop, randTile := s.landUseMapper.Get(luE)
-				_ = s.drawSprite(img, s.terrain, s.landUse, i, j, lu, &point, height, &off,
+				_ = s.drawSprite(img, s.terrain, s.landUse, i, j, lu, &luPoint, height, &off,
 					randTile, terr.Properties[lu].TerrainBelow, cursor.X, cursor.Y, sel.BuildType)
 
 				noProd := prod != nil && prod.Amount == 0
 				noStorage := prod != nil && prod.Stock >= terr.Properties[lu].Storage[prod.Resource]
 				noPop := pop != nil && pop.Pop == 0
 				if noProd || noStorage || noPop {
-					_ = s.drawSimpleSprite(img, s.warningMarker, &point, height, &off)
-					if sel.BuildType == terr.Air && cursor.X == i && cursor.Y == j {
+					_ = s.drawSimpleSprite(img, s.warningMarker, &luPoint, height, &off)
+					if sel.BuildType == terr.Air && cursor.In(s.landUse.Footprint(i, j)) {
 						if noProd {
 							if !prod.HasRequired {
 								req := terr.Properties[lu].Production.RequiredTerrain
@@ -210,13 +219,33 @@ func (s *Terrain) UpdateUI(world *ecs.World) {
 				}
 			}
 
-			if useMouse && cursor.X == i && cursor.Y == j {
-				s.drawCursor(img, i, j, height, &point, &off, sel)
+			if useMouse && cursorAt.X == i && cursorAt.Y == j {
+				s.drawCursor(img, cursor.X, cursor.Y, height, &point, &off, sel)
 			}
 		}
 	}
 }
 
+// landUseAnchor returns the point to draw the land use covering a cell at,
+// and whether it is drawn at this cell.
+// Multi-tile buildings are drawn once, at their front-most cell, and centered on their footprint.
+func (s *Terrain) landUseAnchor(x, y int, lu terr.Terrain, point *image.Point) (image.Point, bool) {
+	size := terr.Properties[lu].Footprint
+	if size == image.Pt(1, 1) {
+		return *point, true
+	}
+	front := s.landUse.Origin(x, y).Add(size).Sub(image.Pt(1, 1))
+	if front.X != x || front.Y != y {
+		return *point, false
+	}
+	return s.footprintCenter(point, size), true
+}
+
+// footprintCenter returns the center of a footprint, from the point of its front-most cell.
+func (s *Terrain) footprintCenter(point *image.Point, size image.Point) image.Point {
+	return image.Pt(point.X+(size.Y-size.X)*s.view.TileWidth/4, point.Y)
+}
+
 func (s *Terrain) inRadius(x1, y1, x2, y2, rad int) bool {
 	dx, dy := x1-x2, y1-y2
 	return dx*dx+dy*dy <= rad*rad
@@ -313,7 +342,9 @@ func (s *Terrain) drawCursor(img *ebiten.Image,
 	lu := s.landUse.Get(x, y)
 	luEntity := s.landUseE.Get(x, y)
 	prop := terr.Properties[sel.BuildType]
-	if prop.TerrainBits.Contains(terr.CanBuild) {
+	if prop.TerrainBits.Contains(terr.CanBuild) && prop.Footprint != image.Pt(1, 1) {
+		s.drawFootprintCursor(img, x, y, height, camOffset, sel)
+	} else if prop.TerrainBits.Contains(terr.CanBuild) {
 		canBuy := prop.TerrainBits.Contains(terr.CanBuy)
 
 		canBuildHere := (prop.BuildOn.Contains(ter) || (sel.AllowRemove && ter != terr.Air && ter != sel.BuildType))
@@ -358,6 +389,57 @@ func (s *Terrain) drawCursor(img *ebiten.Image,
 	}
 }
 
+// footprintOrigin returns the origin for placing a multi-tile building at the given cell,
+// and whether it upgrades the building there. Upgrades grow from the origin of the upgraded building.
+func (s *Terrain) footprintOrigin(cell image.Point, build terr.Terrain) (image.Point, bool) {
+	if !s.landUse.Contains(cell.X, cell.Y) {
+		return cell, false
+	}
+	lu := s.landUse.Get(cell.X, cell.Y)
+	if lu == terr.Air || terr.Properties[lu].UpgradesTo != build {
+		return cell, false
+	}
+	return s.landUse.Origin(cell.X, cell.Y), true
+}
+
+// drawFootprintCursor draws the cursor for placing a multi-tile building at the given cell.
+// When upgrading a building, the cells it covers count as free.
+func (s *Terrain) drawFootprintCursor(img *ebiten.Image,
+	x, y, height int, camOffset *image.Point, sel *res.Selection) {
+
+	prop := &terr.Properties[sel.BuildType]
+	size := prop.Footprint
+
+	origin, isUpgrade := s.footprintOrigin(image.Pt(x, y), sel.BuildType)
+	x, y = origin.X, origin.Y
+
+	cursor := s.cursorOk
+	for dx := 0; dx < size.X; dx++ {
+		for dy := 0; dy < size.Y; dy++ {
+			xx, yy := x+dx, y+dy
+			if !s.terrain.Contains(xx, yy) ||
+				!prop.BuildOn.Contains(s.terrain.Get(xx, yy)) ||
+				(s.landUse.Get(xx, yy) != terr.Air && !(isUpgrade && s.landUse.Origin(xx, yy) == origin)) ||
+				(prop.TerrainBits.Contains(terr.RequiresRange) && s.buildable.Get(xx, yy) == 0) {
+				cursor = s.cursorDenied
+			}
+		}
+	}
+
+	front := image.Pt(x+size.X-1, y+size.Y-1)
+	frontPoint := s.view.TileToGlobal(front.X, front.Y)
+	point := s.footprintCenter(&frontPoint, size)
+	s.drawSprite(img, s.terrain, s.landUse, front.X, front.Y, sel.BuildType, &point, height, camOffset,
+		&comp.RandomSprite{Rand: sel.RandSprite}, prop.TerrainBelow, x, y, terr.Air)
+
+	for dx := 0; dx < size.X; dx++ {
+		for dy := 0; dy < size.Y; dy++ {
+			cellPoint := s.view.TileToGlobal(x+dx, y+dy)
+			s.drawCursorSprite(img, &cellPoint, camOffset, cursor)
+		}
+	}
+}
+
 func (s *Terrain) drawBuildingMarker(img *ebiten.Image, lu terr.Terrain, e ecs.Entity, point, camOffset *image.Point) {
 	if e.IsZero() {
 		return
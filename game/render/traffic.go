@@ -0,0 +1,85 @@
+package render
+
+import (
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"github.com/mlange-42/ark/ecs"
+	"github.com/mlange-42/tiny-world/game/comp"
+	"github.com/mlange-42/tiny-world/game/res"
+	"github.com/mlange-42/tiny-world/game/terr"
+)
+
+// Traffic is a system to render the traffic overlay, for congestion.
+type Traffic struct {
+	screen  ecs.Resource[res.Screen]
+	view    ecs.Resource[res.View]
+	rules   ecs.Resource[res.Rules]
+	traffic ecs.Resource[res.Traffic]
+	landUse ecs.Resource[res.LandUse]
+
+	filter *ecs.Filter1[comp.Tile]
+}
+
+// InitializeUI the system
+func (s *Traffic) InitializeUI(world *ecs.World) {
+	s.screen = s.screen.New(world)
+	s.view = s.view.New(world)
+	s.rules = s.rules.New(world)
+	s.traffic = s.traffic.New(world)
+	s.landUse = s.landUse.New(world)
+
+	s.filter = s.filter.New(world).With(ecs.C[comp.Path]())
+}
+
+// UpdateUI the system
+func (s *Traffic) UpdateUI(world *ecs.World) {
+	view := s.view.Get()
+	if !view.ShowTraffic || !s.rules.Get().Congestion {
+		return
+	}
+	traffic := s.traffic.Get()
+	landUse := s.landUse.Get()
+	canvas := s.screen.Get()
+	img := canvas.Image
+
+	off := view.Offset()
+	bounds := view.Bounds(canvas.Width, canvas.Height)
+	h := view.TileHeight / 2
+	z := float32(view.Zoom)
+
+	colFree := color.RGBA{60, 180, 60, 160}
+	colFull := color.RGBA{230, 180, 40, 180}
+	colCongested := color.RGBA{220, 40, 40, 200}
+
+	query := s.filter.Query()
+	for query.Next() {
+		tile := query.Get()
+		load := traffic.Load.Get(tile.X, tile.Y)
+		capacity := terr.Properties[landUse.Get(tile.X, tile.Y)].Capacity
+		if load == 0 || capacity == 0 {
+			continue
+		}
+		point := view.TileToGlobal(tile.X, tile.Y)
+		if !point.In(bounds) {
+			continue
+		}
+
+		col := colFree
+		if load > capacity {
+			col = colCongested
+		} else if load == capacity {
+			col = colFull
+		}
+
+		x := float32(point.X)*z - float32(off.X)
+		y := float32(point.Y-h)*z - float32(off.Y)
+		vector.DrawFilledCircle(img, x, y, 6*z, col, true)
+	}
+}
+
+// PostUpdateUI the system
+func (s *Traffic) PostUpdateUI(world *ecs.World) {}
+
+// FinalizeUI the system
+func (s *Traffic) FinalizeUI(world *ecs.World) {}
@@ -0,0 +1,166 @@
+package res
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/mlange-42/tiny-world/game/terr"
+)
+
+// CommandType is the type of a player action.
+type CommandType uint8
+
+const (
+	// PlaceTerrain places a terrain or natural land use freely. Editor mode only.
+	PlaceTerrain CommandType = iota
+	// PlaceBuilding places a terrain that can be bought, like paths and buildings.
+	PlaceBuilding
+	// Bulldoze removes the land use at the command's tile.
+	Bulldoze
+	// PlaceRandomCard places the random terrain card at the command's card index.
+	PlaceRandomCard
+	// Undo reverts the last step in the [History].
+	Undo
+	// Redo re-applies the last undone step in the [History].
+	Redo
+	// Upgrade replaces the building at the command's tile by its upgrade, given by the command's terrain.
+	Upgrade
+)
+
+// Rejection is the reason why a command was not applied.
+type Rejection uint8
+
+const (
+	// NotRejected means that the command was applied.
+	NotRejected Rejection = iota
+	// RejectInvalid means that the command is not valid in the current game mode.
+	RejectInvalid
+	// RejectOutsideWorld means that the command's tile is outside of the world.
+	RejectOutsideWorld
+	// RejectOutsideArea means that the command's tile is outside of the controlled area.
+	RejectOutsideArea
+	// RejectResources means that there are not enough resources.
+	RejectResources
+	// RejectRandomTerrains means that all available random terrains are placed already.
+	RejectRandomTerrains
+	// RejectLastWarehouse means that the last warehouse can't be removed.
+	RejectLastWarehouse
+	// RejectNothingToRemove means that there is nothing to bulldoze.
+	RejectNothingToRemove
+	// RejectPopulation means that the population limit is reached.
+	RejectPopulation
+	// RejectNoNeighbor means that terrain can only be placed next to existing terrain.
+	RejectNoNeighbor
+	// RejectOccupied means that the tile is already occupied.
+	RejectOccupied
+	// RejectNoTerrain means that there is no terrain to build on.
+	RejectNoTerrain
+	// RejectBuildOn means that the building can't be built on the terrain.
+	RejectBuildOn
+	// RejectNothingToUndo means that the history has no step to undo.
+	RejectNothingToUndo
+	// RejectNothingToRedo means that the history has no step to redo.
+	RejectNothingToRedo
+	// RejectWorldChanged means that the world was changed otherwise, since the step to undo or redo.
+	RejectWorldChanged
+	// RejectNoUpgrade means that the building at the tile can't be upgraded to the terrain.
+	RejectNoUpgrade
+	// RejectUnreachable means that no reachable warehouse holds enough resources, in local stock mode.
+	RejectUnreachable
+	// RejectUndoExpired means that the step to undo is too old, in game mode.
+	RejectUndoExpired
+)
+
+// Command is a player action, to be applied by the ApplyCommands system.
+type Command struct {
+	// Type of the command.
+	Type CommandType
+	// Target tile of the command.
+	Tile image.Point
+	// Terrain to place, for PlaceTerrain, PlaceBuilding and Upgrade.
+	Terrain terr.Terrain
+	// Index of the card in [RandomTerrains], for PlaceRandomCard.
+	Card int
+	// Random sprite index for the placed terrain.
+	RandSprite uint16
+	// Whether a new random sprite index should be drawn on placement.
+	Randomize bool
+	// Whether existing terrain may be replaced, for PlaceTerrain.
+	AllowRemove bool
+	// Whether the command continues the history step of the previous command, like in a drag stroke.
+	Continue bool `json:",omitempty"`
+}
+
+// CommandResult is the outcome of an applied command.
+type CommandResult struct {
+	Command
+	// Reason for rejection. NotRejected if the command was applied.
+	Reason Rejection
+	// Terrain found at the command's tile.
+	Found terr.Terrain
+	// Random card slots changed by Undo and Redo.
+	Cards []int
+}
+
+// Accepted returns whether the command was applied.
+func (r *CommandResult) Accepted() bool {
+	return r.Reason == NotRejected
+}
+
+// Message returns a human-readable description of the rejection reason.
+func (r *CommandResult) Message() string {
+	switch r.Reason {
+	case NotRejected:
+		return ""
+	case RejectInvalid:
+		return "Action not available."
+	case RejectOutsideWorld:
+		return "Outside of the world."
+	case RejectOutsideArea:
+		return "Outside of controlled area."
+	case RejectResources:
+		return "Not enough resources."
+	case RejectRandomTerrains:
+		return "No more random terrains available."
+	case RejectLastWarehouse:
+		return "Can't destroy last warehouse."
+	case RejectNothingToRemove:
+		return "Nothing to remove here."
+	case RejectPopulation:
+		return "Population limit reached."
+	case RejectNoNeighbor:
+		return "Can only add next to existing terrain."
+	case RejectOccupied:
+		return "Terrain already occupied."
+	case RejectNoTerrain:
+		return "No terrain here."
+	case RejectBuildOn:
+		return fmt.Sprintf("Can't build this on %s", terr.Properties[r.Found].Name)
+	case RejectNothingToUndo:
+		return "Nothing to undo."
+	case RejectNothingToRedo:
+		return "Nothing to redo."
+	case RejectWorldChanged:
+		return "The world has changed since."
+	case RejectNoUpgrade:
+		return fmt.Sprintf("Can't upgrade %s to %s.", terr.Properties[r.Found].Name, terr.Properties[r.Terrain].Name)
+	case RejectUnreachable:
+		return "Not enough resources in reachable warehouses."
+	case RejectUndoExpired:
+		return "Too late to undo."
+	}
+	return fmt.Sprintf("Unknown rejection reason %d.", r.Reason)
+}
+
+// Commands resource, a queue of player actions.
+type Commands struct {
+	// Commands to be applied in the next update.
+	Queue []Command
+	// Results of the commands applied in the last update.
+	Results []CommandResult
+}
+
+// Push a command to the queue.
+func (c *Commands) Push(cmd Command) {
+	c.Queue = append(c.Queue, cmd)
+}
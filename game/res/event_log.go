@@ -0,0 +1,26 @@
+package res
+
+// EventLog resource, holding the most recent game events for the player.
+// It is stored in save games.
+type EventLog struct {
+	// Logged events, oldest first.
+	Entries []EventLogEntry
+	// Maximum number of entries kept.
+	Limit int
+}
+
+// EventLogEntry is an entry in the [EventLog].
+type EventLogEntry struct {
+	// Game time of the event, in seconds.
+	Time int64
+	// Description of the event.
+	Message string
+}
+
+// Add appends an event to the log, dropping the oldest entries beyond the limit.
+func (l *EventLog) Add(time int64, message string) {
+	l.Entries = append(l.Entries, EventLogEntry{Time: time, Message: message})
+	if l.Limit > 0 && len(l.Entries) > l.Limit {
+		l.Entries = append(l.Entries[:0], l.Entries[len(l.Entries)-l.Limit:]...)
+	}
+}
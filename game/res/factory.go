@@ -3,10 +3,10 @@ package res
 import (
 	"image"
 	"math"
-	"math/rand"
 
 	"github.com/mlange-42/ark/ecs"
 	"github.com/mlange-42/tiny-world/game/comp"
+	"github.com/mlange-42/tiny-world/game/resource"
 	"github.com/mlange-42/tiny-world/game/terr"
 )
 
@@ -16,8 +16,15 @@ type EntityFactory struct {
 	productionBuilder *ecs.Map5[comp.Tile, comp.Terrain, comp.UpdateTick, comp.Production, comp.RandomSprite]
 	pathBuilder       *ecs.Map4[comp.Tile, comp.Terrain, comp.Path, comp.RandomSprite]
 
+	terrainMapper           *ecs.Map1[comp.Terrain]
+	updateTickMapper        *ecs.Map1[comp.UpdateTick]
+	productionMapper        *ecs.Map1[comp.Production]
+	inputMapper             *ecs.Map1[comp.InputBuffer]
+	spriteMapper            *ecs.Map1[comp.RandomSprite]
 	radiusMapper            *ecs.Map1[comp.BuildRadius]
+	fireProtectionMapper    *ecs.Map1[comp.FireProtection]
 	consumptionMapper       *ecs.Map1[comp.Consumption]
+	supplyMapper            *ecs.Map1[comp.Supply]
 	populationMapper        *ecs.Map1[comp.Population]
 	populationSupportMapper *ecs.Map1[comp.PopulationSupport]
 	unlockMapper            *ecs.Map1[comp.UnlocksTerrain]
@@ -27,10 +34,13 @@ type EntityFactory struct {
 	terrainEntities ecs.Resource[TerrainEntities]
 	landUse         ecs.Resource[LandUse]
 	landUseEntities ecs.Resource[LandUseEntities]
+	pathVersion     ecs.Resource[PathVersion]
 	buildable       ecs.Resource[Buildable]
 	bounds          ecs.Resource[WorldBounds]
 
+	rules  ecs.Resource[Rules]
 	update ecs.Resource[UpdateInterval]
+	rand   ecs.Resource[Rand]
 }
 
 // NewEntityFactory creates a new EntityFactory for a given world.
@@ -40,8 +50,15 @@ func NewEntityFactory(world *ecs.World) EntityFactory {
 		productionBuilder: ecs.NewMap5[comp.Tile, comp.Terrain, comp.UpdateTick, comp.Production, comp.RandomSprite](world),
 		pathBuilder:       ecs.NewMap4[comp.Tile, comp.Terrain, comp.Path, comp.RandomSprite](world),
 
+		terrainMapper:           ecs.NewMap1[comp.Terrain](world),
+		updateTickMapper:        ecs.NewMap1[comp.UpdateTick](world),
+		productionMapper:        ecs.NewMap1[comp.Production](world),
+		inputMapper:             ecs.NewMap1[comp.InputBuffer](world),
+		spriteMapper:            ecs.NewMap1[comp.RandomSprite](world),
 		radiusMapper:            ecs.NewMap1[comp.BuildRadius](world),
+		fireProtectionMapper:    ecs.NewMap1[comp.FireProtection](world),
 		consumptionMapper:       ecs.NewMap1[comp.Consumption](world),
+		supplyMapper:            ecs.NewMap1[comp.Supply](world),
 		populationMapper:        ecs.NewMap1[comp.Population](world),
 		populationSupportMapper: ecs.NewMap1[comp.PopulationSupport](world),
 		warehouseMapper:         ecs.NewMap1[comp.Warehouse](world),
@@ -51,10 +68,13 @@ func NewEntityFactory(world *ecs.World) EntityFactory {
 		terrainEntities: ecs.NewResource[TerrainEntities](world),
 		landUse:         ecs.NewResource[LandUse](world),
 		landUseEntities: ecs.NewResource[LandUseEntities](world),
+		pathVersion:     ecs.NewResource[PathVersion](world),
 		buildable:       ecs.NewResource[Buildable](world),
 		bounds:          ecs.NewResource[WorldBounds](world),
 
+		rules:  ecs.NewResource[Rules](world),
 		update: ecs.NewResource[UpdateInterval](world),
+		rand:   ecs.NewResource[Rand](world),
 	}
 }
 
@@ -62,7 +82,7 @@ func (f *EntityFactory) createLandUse(pos image.Point, t terr.Terrain, randSprit
 	e := f.landUseBuilder.NewEntity(
 		&comp.Tile{Point: pos},
 		&comp.Terrain{Terrain: t},
-		&comp.UpdateTick{Tick: rand.Int63n(f.update.Get().Interval)},
+		&comp.UpdateTick{Tick: f.rand.Get().Int64N(f.update.Get().Interval)},
 		&comp.RandomSprite{Rand: randSprite},
 	)
 	return e
@@ -83,7 +103,7 @@ func (f *EntityFactory) createProduction(pos image.Point, t terr.Terrain, prod *
 	e := f.productionBuilder.NewEntity(
 		&comp.Tile{Point: pos},
 		&comp.Terrain{Terrain: t},
-		&comp.UpdateTick{Tick: rand.Int63n(update.Interval)},
+		&comp.UpdateTick{Tick: f.rand.Get().Int64N(update.Interval)},
 		&comp.Production{Resource: prod.Resource, Amount: 0, Countdown: update.Countdown},
 		&comp.RandomSprite{Rand: randSprite},
 	)
@@ -107,21 +127,23 @@ func (f *EntityFactory) create(pos image.Point, t terr.Terrain, randSprite uint1
 	if props.BuildRadius > 0 {
 		f.radiusMapper.Add(e, &comp.BuildRadius{Radius: props.BuildRadius})
 	}
-
-	hasConsumption := false
-	for _, c := range props.Consumption {
-		if c > 0 {
-			hasConsumption = true
-			break
-		}
+	if props.FireProtection > 0 {
+		f.fireProtectionMapper.Add(e, &comp.FireProtection{Radius: props.FireProtection})
 	}
-	if hasConsumption {
+	if props.Production.HasInputs() {
+		f.inputMapper.Add(e, &comp.InputBuffer{Stock: make([]uint8, len(props.Production.InputStorage))})
+	}
+
+	if hasConsumption(props) {
 		cons := make([]uint8, len(props.Consumption))
 		copy(cons, props.Consumption)
 		f.consumptionMapper.Add(e, &comp.Consumption{
 			Amount:    cons,
 			Countdown: make([]int16, len(props.Consumption)),
 		})
+		if f.rules.Get().Delivery {
+			f.supplyMapper.Add(e, NewSupply())
+		}
 	}
 	if props.Population > 0 {
 		f.populationMapper.Add(e, &comp.Population{Pop: props.Population})
@@ -133,7 +155,7 @@ func (f *EntityFactory) create(pos image.Point, t terr.Terrain, randSprite uint1
 		f.unlockMapper.AddFn(e, nil)
 	}
 	if props.TerrainBits.Contains(terr.IsWarehouse) {
-		f.warehouseMapper.AddFn(e, nil)
+		f.warehouseMapper.Add(e, &comp.Warehouse{Stock: make([]int, len(props.Storage))})
 	}
 
 	return e
@@ -142,12 +164,14 @@ func (f *EntityFactory) create(pos image.Point, t terr.Terrain, randSprite uint1
 // Set creates an entity of the given terrain type, placing it in the world and updating the game grids.
 func (f *EntityFactory) Set(world *ecs.World, x, y int, value terr.Terrain, randSprite uint16, randomize bool) ecs.Entity {
 	if randomize {
-		randSprite = uint16(rand.Int31n(math.MaxUint16))
+		randSprite = uint16(f.rand.Get().Int32N(math.MaxUint16))
 	}
 	if !terr.Properties[value].TerrainBits.Contains(terr.IsTerrain) {
-		f.landUse.Get().Set(x, y, value)
+		landUse := f.landUse.Get()
+		f.pathVersion.Get().Update(landUse.Get(x, y), value)
+		landUse.SetFootprint(x, y, value)
 		e := f.create(image.Pt(x, y), value, randSprite)
-		f.landUseEntities.Get().Set(x, y, e)
+		f.landUseEntities.Get().SetFootprint(landUse.Footprint(x, y), e)
 
 		rad := terr.Properties[value].BuildRadius
 		if rad > 0 {
@@ -189,6 +213,7 @@ func (f *EntityFactory) setNeighbor(t *Terrain, tE *TerrainEntities, x, y int) {
 }
 
 // RemoveLandUse removes land use from a given position, and updates the game grids.
+// For multi-tile buildings, the position can be any covered cell, and the entire building is removed.
 func (f *EntityFactory) RemoveLandUse(world *ecs.World, x, y int) {
 	landUse := f.landUse.Get()
 	luHere := landUse.Get(x, y)
@@ -196,15 +221,272 @@ func (f *EntityFactory) RemoveLandUse(world *ecs.World, x, y int) {
 		return
 	}
 
+	origin := landUse.Origin(x, y)
 	rad := terr.Properties[luHere].BuildRadius
 	if rad > 0 {
-		f.SetBuildable(x, y, int(rad), false)
+		f.SetBuildable(origin.X, origin.Y, int(rad), false)
 	}
 
 	luE := f.landUseEntities.Get()
 	world.RemoveEntity(luE.Get(x, y))
-	luE.Set(x, y, ecs.Entity{})
-	landUse.Set(x, y, terr.Air)
+	luE.SetFootprint(landUse.ClearFootprint(x, y), ecs.Entity{})
+	f.pathVersion.Get().Update(luHere, terr.Air)
+}
+
+// Upgrade replaces the building at a given position by the given building type.
+// The entity is kept, and its components are updated to the new type.
+// Production countdown and stock are kept.
+// The position must be the building's origin.
+// Cells covered by a larger footprint of the new type must be free, see [LandUse.FootprintFree].
+func (f *EntityFactory) Upgrade(world *ecs.World, x, y int, to terr.Terrain) ecs.Entity {
+	landUse := f.landUse.Get()
+	from := landUse.Get(x, y)
+	e := f.landUseEntities.Get().Get(x, y)
+
+	fromProps := &terr.Properties[from]
+	props := &terr.Properties[to]
+
+	if fromProps.BuildRadius > 0 {
+		f.SetBuildable(x, y, int(fromProps.BuildRadius), false)
+	}
+	if props.BuildRadius > 0 {
+		f.SetBuildable(x, y, int(props.BuildRadius), true)
+	}
+	landUse.SetFootprint(x, y, to)
+	f.landUseEntities.Get().SetFootprint(landUse.Footprint(x, y), e)
+	f.pathVersion.Get().Update(from, to)
+	f.terrainMapper.Get(e).Terrain = to
+
+	if props.Production.MaxProduction > 0 {
+		if f.productionMapper.HasAll(e) {
+			prod := f.productionMapper.Get(e)
+			if prod.Resource != props.Production.Resource {
+				// Stock of a different resource can't be kept.
+				prod.Resource = props.Production.Resource
+				prod.Stock = 0
+			}
+		} else {
+			f.productionMapper.Add(e, &comp.Production{Resource: props.Production.Resource, Countdown: f.update.Get().Countdown})
+		}
+	} else if f.productionMapper.HasAll(e) {
+		f.productionMapper.Remove(e)
+	}
+
+	if props.Production.HasInputs() {
+		if !f.inputMapper.HasAll(e) {
+			f.inputMapper.Add(e, &comp.InputBuffer{Stock: make([]uint8, len(props.Production.InputStorage))})
+		}
+	} else if f.inputMapper.HasAll(e) {
+		f.inputMapper.Remove(e)
+	}
+
+	if hasConsumption(props) {
+		if f.consumptionMapper.HasAll(e) {
+			copy(f.consumptionMapper.Get(e).Amount, props.Consumption)
+		} else {
+			f.consumptionMapper.Add(e, &comp.Consumption{
+				Amount:    append([]uint8{}, props.Consumption...),
+				Countdown: make([]int16, len(props.Consumption)),
+			})
+		}
+	} else if f.consumptionMapper.HasAll(e) {
+		f.consumptionMapper.Remove(e)
+	}
+
+	if hasConsumption(props) && f.rules.Get().Delivery {
+		if !f.supplyMapper.HasAll(e) {
+			f.supplyMapper.Add(e, NewSupply())
+		}
+	} else if f.supplyMapper.HasAll(e) {
+		f.supplyMapper.Remove(e)
+	}
+
+	if props.PopulationSupport.MaxPopulation > 0 {
+		if !f.populationSupportMapper.HasAll(e) {
+			f.populationSupportMapper.AddFn(e, nil)
+		}
+	} else if f.populationSupportMapper.HasAll(e) {
+		f.populationSupportMapper.Remove(e)
+	}
+
+	var radius *comp.BuildRadius
+	if props.BuildRadius > 0 {
+		radius = &comp.BuildRadius{Radius: props.BuildRadius}
+	}
+	setComponent(f.radiusMapper, e, radius)
+
+	var fireProtection *comp.FireProtection
+	if props.FireProtection > 0 {
+		fireProtection = &comp.FireProtection{Radius: props.FireProtection}
+	}
+	setComponent(f.fireProtectionMapper, e, fireProtection)
+
+	var population *comp.Population
+	if props.Population > 0 {
+		population = &comp.Population{Pop: props.Population}
+	}
+	setComponent(f.populationMapper, e, population)
+
+	var unlock *comp.UnlocksTerrain
+	if props.UnlocksTerrains > 0 {
+		unlock = &comp.UnlocksTerrain{}
+	}
+	setComponent(f.unlockMapper, e, unlock)
+
+	if props.TerrainBits.Contains(terr.IsWarehouse) {
+		// The inventory of an upgraded warehouse is kept.
+		if !f.warehouseMapper.HasAll(e) {
+			f.warehouseMapper.Add(e, &comp.Warehouse{Stock: make([]int, len(props.Storage))})
+		}
+	} else if f.warehouseMapper.HasAll(e) {
+		f.warehouseMapper.Remove(e)
+	}
+
+	return e
+}
+
+// NewSupply creates an empty input buffer of a consumer, for delivery by haulers.
+func NewSupply() *comp.Supply {
+	return &comp.Supply{
+		Stock:     make([]uint8, len(resource.Properties)),
+		Requested: make([]bool, len(resource.Properties)),
+	}
+}
+
+// setComponent sets, adds or removes a component, depending on whether a value is given.
+func setComponent[T any](mapper *ecs.Map1[T], e ecs.Entity, value *T) {
+	has := mapper.HasAll(e)
+	if value == nil {
+		if has {
+			mapper.Remove(e)
+		}
+		return
+	}
+	if has {
+		*mapper.Get(e) = *value
+		return
+	}
+	mapper.Add(e, value)
+}
+
+func hasConsumption(props *terr.TerrainProps) bool {
+	for _, c := range props.Consumption {
+		if c > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// RemoveTerrain removes terrain from a given position, leaving air.
+// Does not remove land use, and does not update the world bounds.
+func (f *EntityFactory) RemoveTerrain(world *ecs.World, x, y int) {
+	tE := f.terrainEntities.Get()
+	if e := tE.Get(x, y); !e.IsZero() {
+		world.RemoveEntity(e)
+	}
+	tE.Set(x, y, ecs.Entity{})
+	f.terrain.Get().Set(x, y, terr.Air)
+}
+
+// TerrainState returns the state of the terrain at a given position.
+func (f *EntityFactory) TerrainState(x, y int) TileState {
+	return f.state(f.terrain.Get().Get(x, y), f.terrainEntities.Get().Get(x, y))
+}
+
+// LandUseState returns the state of the land use at a given position.
+func (f *EntityFactory) LandUseState(x, y int) TileState {
+	return f.state(f.landUse.Get().Get(x, y), f.landUseEntities.Get().Get(x, y))
+}
+
+func (f *EntityFactory) state(t terr.Terrain, e ecs.Entity) TileState {
+	state := TileState{Terrain: t}
+	if e.IsZero() {
+		return state
+	}
+	if f.spriteMapper.HasAll(e) {
+		state.RandSprite = f.spriteMapper.Get(e).Rand
+	}
+	if f.updateTickMapper.HasAll(e) {
+		state.UpdateTick = f.updateTickMapper.Get(e).Tick
+	}
+	if f.productionMapper.HasAll(e) {
+		prod := *f.productionMapper.Get(e)
+		state.Production = &prod
+	}
+	if f.inputMapper.HasAll(e) {
+		state.InputBuffer = &comp.InputBuffer{Stock: append([]uint8{}, f.inputMapper.Get(e).Stock...)}
+	}
+	if f.consumptionMapper.HasAll(e) {
+		cons := f.consumptionMapper.Get(e)
+		state.Consumption = &comp.Consumption{
+			Amount:      append([]uint8{}, cons.Amount...),
+			Countdown:   append([]int16{}, cons.Countdown...),
+			IsSatisfied: cons.IsSatisfied,
+		}
+	}
+	if f.supplyMapper.HasAll(e) {
+		state.Supply = &comp.Supply{Stock: append([]uint8{}, f.supplyMapper.Get(e).Stock...)}
+	}
+	if f.populationSupportMapper.HasAll(e) {
+		supp := *f.populationSupportMapper.Get(e)
+		state.PopulationSupport = &supp
+	}
+	if f.warehouseMapper.HasAll(e) {
+		state.Warehouse = &comp.Warehouse{Stock: append([]int{}, f.warehouseMapper.Get(e).Stock...)}
+	}
+	return state
+}
+
+// RestoreTerrain sets the terrain at a given position to a previously stored state.
+func (f *EntityFactory) RestoreTerrain(world *ecs.World, x, y int, state *TileState) {
+	if state.Terrain == terr.Air {
+		f.RemoveTerrain(world, x, y)
+		return
+	}
+	e := f.Set(world, x, y, state.Terrain, state.RandSprite, false)
+	f.restore(e, state)
+}
+
+// RestoreLandUse sets the land use at a given position to a previously stored state.
+func (f *EntityFactory) RestoreLandUse(world *ecs.World, x, y int, state *TileState) {
+	f.RemoveLandUse(world, x, y)
+	if state.Terrain == terr.Air {
+		return
+	}
+	e := f.Set(world, x, y, state.Terrain, state.RandSprite, false)
+	f.restore(e, state)
+}
+
+func (f *EntityFactory) restore(e ecs.Entity, state *TileState) {
+	if f.updateTickMapper.HasAll(e) {
+		f.updateTickMapper.Get(e).Tick = state.UpdateTick
+	}
+	if state.Production != nil && f.productionMapper.HasAll(e) {
+		prod := f.productionMapper.Get(e)
+		*prod = *state.Production
+		// Haulers of the removed entity are gone.
+		prod.IsHauling = false
+	}
+	if state.InputBuffer != nil && f.inputMapper.HasAll(e) {
+		copy(f.inputMapper.Get(e).Stock, state.InputBuffer.Stock)
+	}
+	if state.Consumption != nil && f.consumptionMapper.HasAll(e) {
+		cons := f.consumptionMapper.Get(e)
+		copy(cons.Amount, state.Consumption.Amount)
+		copy(cons.Countdown, state.Consumption.Countdown)
+		cons.IsSatisfied = state.Consumption.IsSatisfied
+	}
+	if state.Supply != nil && f.supplyMapper.HasAll(e) {
+		// Deliveries to the removed entity are gone.
+		copy(f.supplyMapper.Get(e).Stock, state.Supply.Stock)
+	}
+	if state.PopulationSupport != nil && f.populationSupportMapper.HasAll(e) {
+		*f.populationSupportMapper.Get(e) = *state.PopulationSupport
+	}
+	if state.Warehouse != nil && f.warehouseMapper.HasAll(e) {
+		copy(f.warehouseMapper.Get(e).Stock, state.Warehouse.Stock)
+	}
 }
 
 // SetBuildable updates the build-ability grid.
@@ -0,0 +1,145 @@
+package res
+
+import (
+	"image"
+
+	"github.com/mlange-42/tiny-world/game/comp"
+	"github.com/mlange-42/tiny-world/game/resource"
+	"github.com/mlange-42/tiny-world/game/terr"
+)
+
+// TileState is the state of the terrain or land use at a tile, for restoring it.
+type TileState struct {
+	Terrain           terr.Terrain
+	RandSprite        uint16
+	UpdateTick        int64
+	Production        *comp.Production
+	InputBuffer       *comp.InputBuffer
+	Consumption       *comp.Consumption
+	Supply            *comp.Supply
+	PopulationSupport *comp.PopulationSupport
+	Warehouse         *comp.Warehouse
+}
+
+// StockChange is a change of a warehouse inventory, in local stock mode.
+type StockChange struct {
+	// Tile of the warehouse.
+	Tile image.Point
+	// Changed resource.
+	Resource resource.Resource
+	// Added amount. Negative for withdrawals.
+	Amount int
+}
+
+// TileChange is the state of a tile before and after an edit.
+type TileChange struct {
+	Before TileState
+	After  TileState
+}
+
+// RandomCard is a card from the player's hand of random terrains.
+type RandomCard struct {
+	Terrain     terr.Terrain
+	AllowRemove bool
+	RandSprite  uint16
+}
+
+// Edit is a single change to the world, made by a command.
+type Edit struct {
+	// Tile of the edit.
+	Tile image.Point
+	// Change of the terrain. Nil if the terrain was not changed.
+	Terrain *TileChange
+	// Change of the land use. Nil if the land use was not changed.
+	LandUse *TileChange
+	// Neighboring tiles that were changed from air to buildable by placing terrain.
+	Opened []image.Point
+	// Paid build cost.
+	Cost []terr.ResourceAmount
+	// Resources recovered by bulldozing. Nil if the edit is not a bulldozing in game mode.
+	Refund []terr.ResourceAmount
+	// Changes of warehouse inventories for paying and refunding, in local stock mode.
+	Stock []StockChange
+	// Index of the consumed random card. -1 if no card was consumed.
+	Card int
+	// Consumed random card.
+	CardBefore RandomCard
+	// Random card drawn as a replacement.
+	CardAfter RandomCard
+	// Number of placed random terrains before the edit.
+	TotalPlaced int
+	// World bounds before the edit, for restoring them. Only for edits of the terrain.
+	Bounds WorldBounds
+}
+
+// HistoryStep is a list of edits that are undone and redone together.
+type HistoryStep struct {
+	Edits []Edit
+	// Game tick at which the step was applied, or last redone.
+	Tick int64
+}
+
+// History resource, a bounded history of world edits for undo and redo.
+type History struct {
+	// Maximum number of undo steps.
+	Limit int
+
+	undo []HistoryStep
+	redo []HistoryStep
+	open bool
+}
+
+// Record an edit, applied at the given game tick.
+// Adds it to the current step if one is open, or starts a new step.
+// Clears the redo history.
+func (h *History) Record(edit Edit, tick int64) {
+	h.redo = h.redo[:0]
+	if h.open && len(h.undo) > 0 {
+		step := &h.undo[len(h.undo)-1]
+		step.Edits = append(step.Edits, edit)
+		return
+	}
+	h.open = true
+	h.undo = append(h.undo, HistoryStep{Edits: []Edit{edit}, Tick: tick})
+	if h.Limit > 0 && len(h.undo) > h.Limit {
+		h.undo = h.undo[len(h.undo)-h.Limit:]
+	}
+}
+
+// EndStep closes the current step. The next recorded edit starts a new step.
+func (h *History) EndStep() {
+	h.open = false
+}
+
+// Undo returns the step to undo, or nil if there is none.
+func (h *History) Undo() *HistoryStep {
+	if len(h.undo) == 0 {
+		return nil
+	}
+	return &h.undo[len(h.undo)-1]
+}
+
+// Redo returns the step to redo, or nil if there is none.
+func (h *History) Redo() *HistoryStep {
+	if len(h.redo) == 0 {
+		return nil
+	}
+	return &h.redo[len(h.redo)-1]
+}
+
+// Undone moves the step returned by [History.Undo] to the redo history.
+func (h *History) Undone() {
+	h.open = false
+	last := len(h.undo) - 1
+	h.redo = append(h.redo, h.undo[last])
+	h.undo = h.undo[:last]
+}
+
+// Redone moves the step returned by [History.Redo] to the undo history, redone at the given game tick.
+func (h *History) Redone(tick int64) {
+	h.open = false
+	last := len(h.redo) - 1
+	h.redo[last].Tick = tick
+	h.undo = append(h.undo, h.redo[last])
+	h.redo = h.redo[:last]
+}
@@ -0,0 +1,59 @@
+package res
+
+import (
+	"log"
+
+	"github.com/mlange-42/tiny-world/game/resource"
+	"github.com/mlange-42/tiny-world/game/terr"
+)
+
+// HUD is the part of the user interface that simulation systems report to.
+// It is implemented by [UI], and by [HeadlessHUD] for running without a window.
+type HUD interface {
+	SetResourceLabel(id resource.Resource, text string, warning bool)
+	SetResourceDetails(id resource.Resource, text string)
+	SetPopulationLabel(text string, warning bool)
+	SetTimerLabel(text string)
+	SetSpeedLabel(text string)
+	SetRandomTilesLabel(text string)
+	SetStatusLabel(text string)
+	EnableButton(id terr.Terrain)
+	DisableButton(id terr.Terrain, message string)
+}
+
+// Feedback resource, holding the [HUD] used by simulation systems.
+type Feedback struct {
+	HUD
+}
+
+// HeadlessHUD is a [HUD] for running without a window.
+// It ignores all labels and buttons, and optionally logs status messages.
+type HeadlessHUD struct {
+	// Whether status messages should be logged.
+	LogStatus bool
+}
+
+func (h *HeadlessHUD) SetResourceLabel(id resource.Resource, text string, warning bool) {}
+
+func (h *HeadlessHUD) SetResourceDetails(id resource.Resource, text string) {}
+
+func (h *HeadlessHUD) SetPopulationLabel(text string, warning bool) {}
+
+func (h *HeadlessHUD) SetTimerLabel(text string) {}
+
+func (h *HeadlessHUD) SetSpeedLabel(text string) {}
+
+func (h *HeadlessHUD) SetRandomTilesLabel(text string) {}
+
+func (h *HeadlessHUD) SetStatusLabel(text string) {
+	if h.LogStatus {
+		log.Println(text)
+	}
+}
+
+func (h *HeadlessHUD) EnableButton(id terr.Terrain) {}
+
+func (h *HeadlessHUD) DisableButton(id terr.Terrain, message string) {}
+
+var _ HUD = &UI{}
+var _ HUD = &HeadlessHUD{}
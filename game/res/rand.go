@@ -0,0 +1,67 @@
+package res
+
+import (
+	"encoding/json"
+	"math/rand/v2"
+)
+
+// Rand resource, the seeded random number generator for all gameplay randomness.
+// Its state is stored in save games, so that loaded games continue deterministically.
+type Rand struct {
+	*rand.Rand
+	source *rand.PCG
+	seed   uint64
+}
+
+// NewRand creates a new Rand resource from a seed.
+func NewRand(seed uint64) Rand {
+	source := rand.NewPCG(0, seed)
+	return Rand{
+		Rand:   rand.New(source),
+		source: source,
+		seed:   seed,
+	}
+}
+
+// RandomSeed returns a new seed, drawn from a randomly seeded source.
+func RandomSeed() uint64 {
+	return rand.Uint64()
+}
+
+// Seed the generator was created with.
+func (r *Rand) Seed() uint64 {
+	return r.seed
+}
+
+func (r *Rand) MarshalJSON() ([]byte, error) {
+	state, err := r.source.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	helper := randHelper{
+		Seed:  r.seed,
+		State: state,
+	}
+	return json.Marshal(helper)
+}
+
+func (r *Rand) UnmarshalJSON(data []byte) error {
+	helper := randHelper{}
+	if err := json.Unmarshal(data, &helper); err != nil {
+		return err
+	}
+	source := rand.NewPCG(0, helper.Seed)
+	if err := source.UnmarshalBinary(helper.State); err != nil {
+		return err
+	}
+	r.Rand = rand.New(source)
+	r.source = source
+	r.seed = helper.Seed
+
+	return nil
+}
+
+type randHelper struct {
+	Seed  uint64
+	State []byte
+}
@@ -1,10 +1,57 @@
 package res
 
-import "github.com/mlange-42/tiny-world/game/terr"
+import (
+	"math"
 
+	"github.com/mlange-42/tiny-world/game/terr"
+)
+
+// RandomTerrains resource, holding the player's hand of random terrain cards.
+// Terrains, AllowRemove and Sprites are indexed by card slot.
 type RandomTerrains struct {
 	Terrains       []terr.Terrain
 	AllowRemove    []bool
+	Sprites        []uint16
 	TotalAvailable int
 	TotalPlaced    int
 }
+
+// Fill the hand with the given number of default terrain cards, if it is empty.
+// Draws sprites for cards without one, e.g. from older save games.
+func (r *RandomTerrains) Fill(count int, rng *Rand) {
+	if len(r.Terrains) == 0 {
+		for i := 0; i < count; i++ {
+			r.Terrains = append(r.Terrains, terr.Default)
+			r.AllowRemove = append(r.AllowRemove, false)
+		}
+	}
+	for len(r.Sprites) < len(r.Terrains) {
+		r.Sprites = append(r.Sprites, randomSprite(rng))
+	}
+}
+
+// Draw a new random card for the given slot.
+func (r *RandomTerrains) Draw(rules *Rules, rng *Rand, index int) {
+	t := rules.RandomTerrains[rng.IntN(len(rules.RandomTerrains))]
+	r.Terrains[index] = t
+	r.AllowRemove[index] = terr.Properties[t].TerrainBits.Contains(terr.IsTerrain) &&
+		rng.Float64() < rules.SpecialCardProbability
+	r.Sprites[index] = randomSprite(rng)
+}
+
+// Card returns the card in the given slot.
+func (r *RandomTerrains) Card(index int) RandomCard {
+	return RandomCard{Terrain: r.Terrains[index], AllowRemove: r.AllowRemove[index], RandSprite: r.Sprites[index]}
+}
+
+// SetCard puts a card into the given slot.
+func (r *RandomTerrains) SetCard(index int, card RandomCard) {
+	r.Terrains[index] = card.Terrain
+	r.AllowRemove[index] = card.AllowRemove
+	r.Sprites[index] = card.RandSprite
+}
+
+// randomSprite draws the random sprite index of a card or button.
+func randomSprite(rng *Rand) uint16 {
+	return uint16(rng.IntN(math.MaxUint16))
+}
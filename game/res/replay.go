@@ -0,0 +1,140 @@
+package res
+
+import (
+	"fmt"
+
+	"github.com/mlange-42/tiny-world/game/resource"
+)
+
+// ReplayMode determines whether a [Replay] is recorded or played back.
+type ReplayMode uint8
+
+const (
+	// ReplayOff disables recording and playback.
+	ReplayOff ReplayMode = iota
+	// ReplayRecord records accepted commands and game speed changes.
+	ReplayRecord
+	// ReplayPlay plays back recorded events.
+	ReplayPlay
+)
+
+// ReplayEvent is a recorded command or game speed change.
+//
+// Commands are applied in the update with the event's render tick.
+// Speed changes take effect after the update with the event's render tick.
+type ReplayEvent struct {
+	// Render tick of the update the event happened in. Also counts paused updates.
+	RenderTick int64
+	// Game tick of the update the event happened in, for information.
+	Tick int64
+	// Accepted command, or nil.
+	Command *Command `json:",omitempty"`
+	// Game speed after the change, or nil.
+	Speed *ReplaySpeed `json:",omitempty"`
+}
+
+// ReplaySpeed is the game speed after a recorded speed change.
+type ReplaySpeed struct {
+	Pause bool
+	Speed int8
+}
+
+// ReplayFinal is the state at the end of a recording, used to verify playback.
+type ReplayFinal struct {
+	Tick       int64
+	RenderTick int64
+	Stock      Stock
+}
+
+// Replay resource, for recording and playing back a game session.
+type Replay struct {
+	// Recording or playback. Not stored in replay files.
+	Mode ReplayMode `json:"-"`
+	// Index of the next event to play back. Not stored in replay files.
+	Next int `json:"-"`
+
+	// Seed the session was started with.
+	Seed uint64
+	// Name of the map the session was started from. Empty for a new, empty world.
+	Map string
+	// Folder of the map the session was started from.
+	MapFolder string
+	// Whether the map is embedded into the game.
+	MapEmbedded bool
+	// Whether the session is played in editor mode.
+	IsEditor bool
+	// Parameters of the generated world the session was started with. Nil if no world was generated.
+	Generator *WorldGen `json:",omitempty"`
+
+	// Recorded events, ordered by render tick.
+	Events []ReplayEvent
+	// State at the end of the recording.
+	Final *ReplayFinal `json:",omitempty"`
+}
+
+// RecordCommand records an accepted command.
+func (r *Replay) RecordCommand(time *GameTick, cmd Command) {
+	if r.Mode != ReplayRecord {
+		return
+	}
+	r.Events = append(r.Events, ReplayEvent{
+		RenderTick: time.RenderTick,
+		Tick:       time.Tick,
+		Command:    &cmd,
+	})
+}
+
+// RecordSpeed records the game speed after a change.
+func (r *Replay) RecordSpeed(time *GameTick, speed *GameSpeed) {
+	if r.Mode != ReplayRecord {
+		return
+	}
+	r.Events = append(r.Events, ReplayEvent{
+		RenderTick: time.RenderTick,
+		Tick:       time.Tick,
+		Speed:      &ReplaySpeed{Pause: speed.Pause, Speed: speed.Speed},
+	})
+}
+
+// Finish stores the current state as the end of the recording.
+func (r *Replay) Finish(time *GameTick, stock *Stock) {
+	r.Final = &ReplayFinal{
+		Tick:       time.Tick,
+		RenderTick: time.RenderTick,
+		Stock: Stock{
+			Cap:           append([]int{}, stock.Cap...),
+			Res:           append([]int{}, stock.Res...),
+			Total:         append([]int{}, stock.Total...),
+			Population:    stock.Population,
+			MaxPopulation: stock.MaxPopulation,
+		},
+	}
+}
+
+// Verify compares the given stock to the stock at the end of the recording.
+// Returns a description of each difference.
+func (r *Replay) Verify(stock *Stock) []string {
+	if r.Final == nil {
+		return []string{"replay has no final state"}
+	}
+	final := &r.Final.Stock
+	diff := []string{}
+	for i, p := range resource.Properties {
+		if stock.Res[i] != final.Res[i] {
+			diff = append(diff, fmt.Sprintf("%s stock: expected %d, got %d", p.Name, final.Res[i], stock.Res[i]))
+		}
+		if stock.Cap[i] != final.Cap[i] {
+			diff = append(diff, fmt.Sprintf("%s capacity: expected %d, got %d", p.Name, final.Cap[i], stock.Cap[i]))
+		}
+		if stock.Total[i] != final.Total[i] {
+			diff = append(diff, fmt.Sprintf("%s total: expected %d, got %d", p.Name, final.Total[i], stock.Total[i]))
+		}
+	}
+	if stock.Population != final.Population {
+		diff = append(diff, fmt.Sprintf("population: expected %d, got %d", final.Population, stock.Population))
+	}
+	if stock.MaxPopulation != final.MaxPopulation {
+		diff = append(diff, fmt.Sprintf("max population: expected %d, got %d", final.MaxPopulation, stock.MaxPopulation))
+	}
+	return diff
+}
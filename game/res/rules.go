@@ -27,6 +27,42 @@ type Rules struct {
 	InitialResources []int
 	// Probability of special cards/terrains that can be placed over existing terrain.
 	SpecialCardProbability float64
+	// Maximum number of steps that can be undone.
+	UndoSteps int
+	// Number of updates after which a step can no longer be undone, in game mode.
+	// Prevents getting back the full build cost instead of the salvage. Zero for no limit.
+	UndoTime int
+	// Whether each warehouse holds its own inventory, instead of a single global stock.
+	LocalStock bool
+	// Whether consumers are supplied by haulers from warehouses, instead of directly from the stock.
+	Delivery bool
+	// Capacity of the input buffers of consumers, per resource, for delivery by haulers.
+	DeliveryBuffer int
+	// Whether haulers slow down on path tiles over capacity, and avoid them.
+	Congestion bool
+	// Change of the number of haulers on a tile over capacity that is ignored for routing,
+	// so that small fluctuations don't invalidate all cached routes.
+	CongestionTolerance int
+	// Probability per update of a tree seeding onto a random free plains neighbor,
+	// when that neighbor is surrounded by trees. Scales with the number of trees around it.
+	TreeGrowth float64
+	// Probability that a production unit removes a natural land use neighbor the producer works on,
+	// like trees for lumberjacks and rocks for masons. Zero disables depletion.
+	Depletion float64
+	// Whether fires break out and spread.
+	Fire bool
+	// Probability per update of flammable land use catching fire.
+	FireProbability float64
+	// Probability per update of a fire spreading to each flammable neighbor.
+	FireSpread float64
+	// Number of updates until burning land use is destroyed.
+	FireDuration int
+	// Probability per update of a fully protected fire being contained.
+	FireContainment float64
+	// Protection against fire per neighboring water tile, between 0 and 1.
+	FireWaterProtection float64
+	// Protection against fire in range of fire protection buildings like wells, between 0 and 1.
+	FireStationProtection float64
 }
 
 // NewRules reads rules from the given file.
@@ -37,13 +73,9 @@ func NewRules(f fs.FS, file string) Rules {
 		panic(err)
 	}
 
-	storage := make([]int, len(resource.Properties))
-	for _, entry := range rulesHelper.InitialResources {
-		res, ok := resource.ResourceID(entry.Resource)
-		if !ok {
-			panic(fmt.Sprintf("unknown resource %s", entry.Resource))
-		}
-		storage[res] = entry.Amount
+	storage, err := toResourceAmounts(rulesHelper.InitialResources)
+	if err != nil {
+		panic(err)
 	}
 
 	randTerr := []terr.Terrain{}
@@ -60,19 +92,51 @@ func NewRules(f fs.FS, file string) Rules {
 		RandomTerrainsCount:    rulesHelper.RandomTerrainsCount,
 		RandomTerrains:         randTerr,
 		SpecialCardProbability: rulesHelper.SpecialCardProbability,
+		UndoSteps:              rulesHelper.UndoSteps,
+		UndoTime:               rulesHelper.UndoTime,
+		LocalStock:             rulesHelper.LocalStock,
+		Delivery:               rulesHelper.Delivery,
+		DeliveryBuffer:         rulesHelper.DeliveryBuffer,
+		Congestion:             rulesHelper.Congestion,
+		CongestionTolerance:    rulesHelper.CongestionTolerance,
+		TreeGrowth:             rulesHelper.TreeGrowth,
+		Depletion:              rulesHelper.Depletion,
+		Fire:                   rulesHelper.Fire,
+		FireProbability:        rulesHelper.FireProbability,
+		FireSpread:             rulesHelper.FireSpread,
+		FireDuration:           rulesHelper.FireDuration,
+		FireContainment:        rulesHelper.FireContainment,
+		FireWaterProtection:    rulesHelper.FireWaterProtection,
+		FireStationProtection:  rulesHelper.FireStationProtection,
 	}
 }
 
 type rulesJs struct {
-	WorldSize             int `json:"world_size"`
-	InitialBuildRadius    int `json:"initial_build_radius"`
-	InitialPopulation     int `json:"initial_population"`
-	InitialRandomTerrains int `json:"initial_random_terrains"`
-	RandomTerrainsCount   int `json:"random_terrains_count"`
+	WorldSize             int  `json:"world_size"`
+	InitialBuildRadius    int  `json:"initial_build_radius"`
+	InitialPopulation     int  `json:"initial_population"`
+	InitialRandomTerrains int  `json:"initial_random_terrains"`
+	RandomTerrainsCount   int  `json:"random_terrains_count"`
+	UndoSteps             int  `json:"undo_steps"`
+	UndoTime              int  `json:"undo_time"`
+	LocalStock            bool `json:"local_stock"`
+	Delivery              bool `json:"delivery"`
+	DeliveryBuffer        int  `json:"delivery_buffer"`
+	Congestion            bool `json:"congestion"`
+	CongestionTolerance   int  `json:"congestion_tolerance"`
+	Fire                  bool `json:"fire"`
+	FireDuration          int  `json:"fire_duration"`
 
 	RandomTerrains         []string           `json:"random_terrains"`
 	InitialResources       []resourceAmountJs `json:"initial_resources"`
 	SpecialCardProbability float64            `json:"special_card_probability"`
+	TreeGrowth             float64            `json:"tree_growth"`
+	Depletion              float64            `json:"depletion"`
+	FireProbability        float64            `json:"fire_probability"`
+	FireSpread             float64            `json:"fire_spread"`
+	FireContainment        float64            `json:"fire_containment"`
+	FireWaterProtection    float64            `json:"fire_water_protection"`
+	FireStationProtection  float64            `json:"fire_station_protection"`
 }
 
 type resourceAmountJs struct {
@@ -80,6 +144,100 @@ type resourceAmountJs struct {
 	Amount   int    `json:"amount"`
 }
 
+// RulesOverride holds rules changed by a map. Nil fields keep the default rules.
+// Only rules that can still be changed when the world is initialized are supported.
+type RulesOverride struct {
+	InitialBuildRadius     *int               `json:"initial_build_radius,omitempty"`
+	InitialPopulation      *int               `json:"initial_population,omitempty"`
+	InitialResources       []resourceAmountJs `json:"initial_resources,omitempty"`
+	RandomTerrainsCount    *int               `json:"random_terrains_count,omitempty"`
+	SpecialCardProbability *float64           `json:"special_card_probability,omitempty"`
+	LocalStock             *bool              `json:"local_stock,omitempty"`
+	Delivery               *bool              `json:"delivery,omitempty"`
+	DeliveryBuffer         *int               `json:"delivery_buffer,omitempty"`
+	TreeGrowth             *float64           `json:"tree_growth,omitempty"`
+	Depletion              *float64           `json:"depletion,omitempty"`
+	Fire                   *bool              `json:"fire,omitempty"`
+	FireProbability        *float64           `json:"fire_probability,omitempty"`
+}
+
+// Validate checks the override for unknown resources and invalid values.
+func (o *RulesOverride) Validate() error {
+	if _, err := toResourceAmounts(o.InitialResources); err != nil {
+		return err
+	}
+	counts := []struct {
+		Name  string
+		Value *int
+	}{
+		{"initial_build_radius", o.InitialBuildRadius},
+		{"initial_population", o.InitialPopulation},
+		{"random_terrains_count", o.RandomTerrainsCount},
+		{"delivery_buffer", o.DeliveryBuffer},
+	}
+	for _, c := range counts {
+		if c.Value != nil && *c.Value < 0 {
+			return fmt.Errorf("rule %s must not be negative, got %d", c.Name, *c.Value)
+		}
+	}
+	probabilities := []struct {
+		Name  string
+		Value *float64
+	}{
+		{"special_card_probability", o.SpecialCardProbability},
+		{"tree_growth", o.TreeGrowth},
+		{"depletion", o.Depletion},
+		{"fire_probability", o.FireProbability},
+	}
+	for _, p := range probabilities {
+		if p.Value != nil && (*p.Value < 0 || *p.Value > 1) {
+			return fmt.Errorf("rule %s must be in range [0, 1], got %g", p.Name, *p.Value)
+		}
+	}
+	return nil
+}
+
+// Apply sets the overridden rules. The override must be valid, see [RulesOverride.Validate].
+func (o *RulesOverride) Apply(rules *Rules) {
+	setIf(&rules.InitialBuildRadius, o.InitialBuildRadius)
+	setIf(&rules.InitialPopulation, o.InitialPopulation)
+	setIf(&rules.RandomTerrainsCount, o.RandomTerrainsCount)
+	setIf(&rules.SpecialCardProbability, o.SpecialCardProbability)
+	setIf(&rules.LocalStock, o.LocalStock)
+	setIf(&rules.Delivery, o.Delivery)
+	setIf(&rules.DeliveryBuffer, o.DeliveryBuffer)
+	setIf(&rules.TreeGrowth, o.TreeGrowth)
+	setIf(&rules.Depletion, o.Depletion)
+	setIf(&rules.Fire, o.Fire)
+	setIf(&rules.FireProbability, o.FireProbability)
+	if o.InitialResources != nil {
+		storage, err := toResourceAmounts(o.InitialResources)
+		if err != nil {
+			panic(err)
+		}
+		rules.InitialResources = storage
+	}
+}
+
+func setIf[T any](target *T, value *T) {
+	if value != nil {
+		*target = *value
+	}
+}
+
+// toResourceAmounts converts resource amounts by name to amounts indexed by [resource.Resource].
+func toResourceAmounts(entries []resourceAmountJs) ([]int, error) {
+	storage := make([]int, len(resource.Properties))
+	for _, entry := range entries {
+		res, ok := resource.ResourceID(entry.Resource)
+		if !ok {
+			return nil, fmt.Errorf("unknown resource %s", entry.Resource)
+		}
+		storage[res] = entry.Amount
+	}
+	return storage, nil
+}
+
 func toTerrain(t string) terr.Terrain {
 	id, ok := terr.TerrainID(t)
 	if !ok {
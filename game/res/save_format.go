@@ -0,0 +1,14 @@
+package res
+
+// SaveFormat resource, describing the format of a save game.
+// Written to save games, to migrate games saved by older versions on load.
+type SaveFormat struct {
+	// Version of the save game format.
+	Version int
+	// Terrain names, indexed by terrain ID at the time of saving.
+	Terrains []string
+	// Resource names, indexed by resource ID at the time of saving.
+	Resources []string
+	// Whether the game was migrated on load. Not stored in save games.
+	Migrated bool `json:"-"`
+}
@@ -0,0 +1,20 @@
+package res
+
+import "time"
+
+// SaveInfo resource, summarizing the game for the list of save games.
+// Updated when the game is saved.
+type SaveInfo struct {
+	// Name of the scenario map the game was started from. Empty for other worlds.
+	Scenario string
+	// Play time, from [GameTick].
+	PlayTime time.Duration
+	// Population at the time of saving.
+	Population int
+	// Resources in stock, by resource name.
+	Resources map[string]int
+	// Number of buildings.
+	Buildings int
+	// Minimap of the world bounds, PNG-encoded.
+	Thumbnail []byte
+}
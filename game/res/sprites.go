@@ -171,6 +171,15 @@ func (s *Sprites) GetSprite(idx int) *ebiten.Image {
 	return s.sprites[idx]
 }
 
+// IconScale returns the scale for drawing a sprite in the size of a single tile, like on buttons and cards.
+// It is below 1 for sprites of multi-tile buildings.
+func (s *Sprites) IconScale(sp *ebiten.Image) float64 {
+	if w := sp.Bounds().Dx(); w > s.TileWidth {
+		return float64(s.TileWidth) / float64(w)
+	}
+	return 1
+}
+
 // GetIndex returns the sprite index for a sprite or terrain ID.
 func (s *Sprites) GetIndex(name string) int {
 	if idx, ok := s.indices[name]; ok {
@@ -1,11 +1,15 @@
 package res
 
 import (
+	"github.com/mlange-42/tiny-world/game/comp"
 	"github.com/mlange-42/tiny-world/game/resource"
 	"github.com/mlange-42/tiny-world/game/terr"
 )
 
 // Stock resource, holding global stock information.
+//
+// In local stock mode, resources are held by the individual warehouses, see [comp.Warehouse].
+// Res is then the sum over all warehouses, and must only be changed together with a warehouse's inventory.
 type Stock struct {
 	// Total storage capacity, indexed by [resource.Resource].
 	Cap []int
@@ -37,6 +41,25 @@ func (s *Stock) AddResources(res resource.Resource, amount int) {
 	s.Total[res] += amount
 }
 
+// AddResourcesTo adds produced resources to the stock and to the inventory of the receiving warehouse, in local stock mode.
+func (s *Stock) AddResourcesTo(w *comp.Warehouse, res resource.Resource, amount int) {
+	s.AddResources(res, amount)
+	w.Stock[res] += amount
+}
+
+// Deposit adds resources to the inventory of a warehouse, in local stock mode.
+// Unlike [Stock.AddResourcesTo], it does not count as production.
+func (s *Stock) Deposit(w *comp.Warehouse, res resource.Resource, amount int) {
+	s.Res[res] += amount
+	w.Stock[res] += amount
+}
+
+// Withdraw takes resources from the inventory of a warehouse, in local stock mode.
+func (s *Stock) Withdraw(w *comp.Warehouse, res resource.Resource, amount int) {
+	s.Res[res] -= amount
+	w.Stock[res] -= amount
+}
+
 // CanPay checks whether there are sufficient resources in the stock to pay the given amounts.
 func (s *Stock) CanPay(cost []terr.ResourceAmount) bool {
 	for _, c := range cost {
@@ -53,3 +76,30 @@ func (s *Stock) Pay(cost []terr.ResourceAmount) {
 		s.Res[c.Resource] -= int(c.Amount)
 	}
 }
+
+// Salvage adds the given amounts to the stock, limited by the storage capacity.
+// Capacity is reduced by the given storage of a warehouse that is removed. Use nil for other buildings.
+// Returns the amounts that were actually added.
+func (s *Stock) Salvage(amounts []terr.ResourceAmount, removedStorage []uint8) []terr.ResourceAmount {
+	added := []terr.ResourceAmount{}
+	for _, a := range amounts {
+		capacity := s.Cap[a.Resource]
+		if removedStorage != nil {
+			capacity -= int(removedStorage[a.Resource])
+		}
+		amount := min(int(a.Amount), capacity-s.Res[a.Resource])
+		if amount <= 0 {
+			continue
+		}
+		s.Res[a.Resource] += amount
+		added = append(added, terr.ResourceAmount{Resource: a.Resource, Amount: uint16(amount)})
+	}
+	return added
+}
+
+// Refund the given amounts by adding them to the stock.
+func (s *Stock) Refund(cost []terr.ResourceAmount) {
+	for _, c := range cost {
+		s.Res[c.Resource] += int(c.Amount)
+	}
+}
@@ -1,6 +1,8 @@
 package res
 
 import (
+	"image"
+
 	"github.com/mlange-42/ark/ecs"
 	"github.com/mlange-42/tiny-world/game/terr"
 )
@@ -19,14 +21,93 @@ func NewTerrain(w, h int) Terrain {
 // LandUse resource
 type LandUse struct {
 	TerrainGrid
+	// Offsets of cells to the origin of multi-tile buildings, packed as dx<<4 | dy.
+	offsets Grid[uint8]
 }
 
 func NewLandUse(w, h int) LandUse {
 	return LandUse{
 		TerrainGrid: TerrainGrid{NewGrid[terr.Terrain](w, h)},
+		offsets:     NewGrid[uint8](w, h),
+	}
+}
+
+// Origin returns the origin of the land use covering a cell.
+// For multi-tile buildings, this is the covered cell with the smallest coordinates.
+// For all other land use, it is the cell itself.
+func (l *LandUse) Origin(x, y int) image.Point {
+	off := l.offsets.Get(x, y)
+	return image.Pt(x-int(off>>4), y-int(off&0x0f))
+}
+
+// Footprint returns all cells covered by the land use at a cell.
+func (l *LandUse) Footprint(x, y int) image.Rectangle {
+	origin := l.Origin(x, y)
+	size := terr.Properties[l.Get(origin.X, origin.Y)].Footprint
+	return image.Rectangle{Min: origin, Max: origin.Add(size)}
+}
+
+// SetFootprint sets a land use on all cells of its footprint, starting at the given origin.
+func (l *LandUse) SetFootprint(x, y int, t terr.Terrain) {
+	size := terr.Properties[t].Footprint
+	for dx := 0; dx < size.X; dx++ {
+		for dy := 0; dy < size.Y; dy++ {
+			l.Set(x+dx, y+dy, t)
+			l.offsets.Set(x+dx, y+dy, uint8(dx<<4|dy))
+		}
+	}
+}
+
+// ClearFootprint removes the land use covering a cell from all cells of its footprint.
+// Returns the cleared cells.
+func (l *LandUse) ClearFootprint(x, y int) image.Rectangle {
+	rect := l.Footprint(x, y)
+	for xx := rect.Min.X; xx < rect.Max.X; xx++ {
+		for yy := rect.Min.Y; yy < rect.Max.Y; yy++ {
+			l.Set(xx, yy, terr.Air)
+			l.offsets.Set(xx, yy, 0)
+		}
+	}
+	return rect
+}
+
+// FootprintFree checks whether all cells of a land use's footprint, placed at the given origin,
+// are inside the grid and either empty or covered by the land use at the origin.
+func (l *LandUse) FootprintFree(x, y int, t terr.Terrain) bool {
+	size := terr.Properties[t].Footprint
+	for dx := 0; dx < size.X; dx++ {
+		for dy := 0; dy < size.Y; dy++ {
+			xx, yy := x+dx, y+dy
+			if !l.Contains(xx, yy) {
+				return false
+			}
+			if l.Get(xx, yy) != terr.Air && l.Origin(xx, yy) != image.Pt(x, y) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// PathVersion resource.
+// Counts changes of path, bridge and building tiles in the [LandUse],
+// to invalidate cached navigation data.
+type PathVersion struct {
+	Version uint64
+}
+
+// Update increments the version if the change of a tile's land use affects the path network.
+func (v *PathVersion) Update(from, to terr.Terrain) {
+	if from != to && (isNavigable(from) || isNavigable(to)) {
+		v.Version++
 	}
 }
 
+// isNavigable checks whether haulers can walk on or to a land use.
+func isNavigable(t terr.Terrain) bool {
+	return terr.Paths.Contains(t) || terr.Buildings.Contains(t)
+}
+
 // TerrainEntities resource
 type TerrainEntities struct {
 	Grid[ecs.Entity]
@@ -37,6 +118,15 @@ type LandUseEntities struct {
 	Grid[ecs.Entity]
 }
 
+// SetFootprint sets the entity for all cells of a multi-tile building's footprint.
+func (e *LandUseEntities) SetFootprint(rect image.Rectangle, entity ecs.Entity) {
+	for x := rect.Min.X; x < rect.Max.X; x++ {
+		for y := rect.Min.Y; y < rect.Max.Y; y++ {
+			e.Set(x, y, entity)
+		}
+	}
+}
+
 // Buildable resource
 type Buildable struct {
 	Grid[uint16]
@@ -202,6 +292,32 @@ func (g *TerrainGrid) CountNeighbors8(x, y int, tp terr.Terrain) int {
 	return cnt
 }
 
+// CountNeighborsRect4 counts cells of the given terrains that are orthogonal neighbors of a rectangle.
+func (g *TerrainGrid) CountNeighborsRect4(rect image.Rectangle, tp terr.Terrains) int {
+	cnt := 0
+	for x := rect.Min.X; x < rect.Max.X; x++ {
+		cnt += g.countCell(x, rect.Min.Y-1, tp) + g.countCell(x, rect.Max.Y, tp)
+	}
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		cnt += g.countCell(rect.Min.X-1, y, tp) + g.countCell(rect.Max.X, y, tp)
+	}
+	return cnt
+}
+
+// CountNeighborsRect8 counts cells of the given terrains that are neighbors of a rectangle, including diagonals.
+func (g *TerrainGrid) CountNeighborsRect8(rect image.Rectangle, tp terr.Terrains) int {
+	return g.CountNeighborsRect4(rect, tp) +
+		g.countCell(rect.Min.X-1, rect.Min.Y-1, tp) + g.countCell(rect.Max.X, rect.Min.Y-1, tp) +
+		g.countCell(rect.Min.X-1, rect.Max.Y, tp) + g.countCell(rect.Max.X, rect.Max.Y, tp)
+}
+
+func (g *TerrainGrid) countCell(x, y int, tp terr.Terrains) int {
+	if g.Contains(x, y) && tp.Contains(g.Get(x, y)) {
+		return 1
+	}
+	return 0
+}
+
 func (g *TerrainGrid) CountNeighborsMask8(x, y int, tp terr.Terrains) int {
 	cnt := g.CountNeighborsMask4(x, y, tp)
 	if g.isNeighborMask(x, y, 1, -1, tp) {
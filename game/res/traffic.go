@@ -0,0 +1,21 @@
+package res
+
+// Traffic resource, for the congestion model.
+// Counts haulers on path tiles.
+type Traffic struct {
+	// Number of haulers per tile, updated every tick.
+	Load Grid[uint8]
+	// Snapshot of Load used for pathfinding, updated once per update interval.
+	// Only holds loads over the capacity of the tile, and 0 otherwise.
+	Routing Grid[uint8]
+	// Incremented on each change of Routing, to invalidate cached routes.
+	Version uint64
+}
+
+// NewTraffic creates a new Traffic resource.
+func NewTraffic(w, h int) Traffic {
+	return Traffic{
+		Load:    NewGrid[uint8](w, h),
+		Routing: NewGrid[uint8](w, h),
+	}
+}
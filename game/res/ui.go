@@ -5,7 +5,6 @@ import (
 	stdimage "image"
 	"image/color"
 	"math"
-	"math/rand"
 	"time"
 
 	"github.com/ebitenui/ebitenui"
@@ -40,6 +39,10 @@ const helpText = "Tiny World Help" +
 	"as well as current and maximum storage. " +
 	"For population buildings, indicators show current and maximum supported population." +
 	"\n\n" +
+	"Fires may break out at buildings and in forests. " +
+	"Neighboring water and wells reduce the risk, and help to contain fires. " +
+	"Recent events are listed in the log." +
+	"\n\n" +
 	"For further information, see the tooltips of the individual buildings and natural features." +
 	"\n\n" +
 	"Controls:\n" +
@@ -47,7 +50,12 @@ const helpText = "Tiny World Help" +
 	" - Zoom: +/- or mouse wheel\n" +
 	" - Pause/resume: Space\n" +
 	" - Game speed: [/] (square brackets)\n" +
-	" - Toggle fullscreen: F11"
+	" - Undo/redo: Ctrl+Z/Ctrl+Y\n" +
+	" - Toggle fullscreen: F11\n" +
+	" - Toggle traffic overlay: T"
+
+const logPanelWidth = 480
+const logPanelHeight = 300
 
 const helpPanelWidth = 680
 const helpPanelHeight = 460
@@ -73,8 +81,13 @@ type UI struct {
 	saveEvent      *SaveEvent
 	editor         *EditorMode
 	randomTerrains *RandomTerrains
+	eventLog       *EventLog
+	// Generator for the sprites of the editor palette, seeded like the world.
+	// Separate from [Rand], as the palette is not part of the simulation.
+	paletteRand Rand
 
 	resourceLabels   []*widget.Text
+	resourceTooltips []*widget.Text
 	populationLabel  *widget.Text
 	timerLabel       *widget.Text
 	speedLabel       *widget.Text
@@ -143,6 +156,10 @@ func (ui *UI) SetResourceLabel(id resource.Resource, text string, warning bool)
 	}
 }
 
+func (ui *UI) SetResourceDetails(id resource.Resource, text string) {
+	ui.resourceTooltips[id].Label = resourceTooltip(id) + "\n\n" + text
+}
+
 func (ui *UI) SetPopulationLabel(text string, warning bool) {
 	ui.populationLabel.Label = text
 	if warning {
@@ -208,7 +225,7 @@ func (ui *UI) MouseInside(x, y int) bool {
 
 func NewUI(world *ecs.World,
 	selection *Selection, fonts *Fonts, sprts *Sprites,
-	randomTerrains *RandomTerrains, save *SaveEvent, editor *EditorMode) UI {
+	randomTerrains *RandomTerrains, save *SaveEvent, editor *EditorMode, eventLog *EventLog) UI {
 	ui := UI{
 		randomButtons:  map[int]randomButton{},
 		selection:      selection,
@@ -218,6 +235,8 @@ func NewUI(world *ecs.World,
 		saveEvent:      save,
 		editor:         editor,
 		randomTerrains: randomTerrains,
+		eventLog:       eventLog,
+		paletteRand:    NewRand(ecs.GetResource[Rand](world).Seed()),
 
 		specialCardSprite:    sprts.GetIndex(sprites.SpecialCardMarker),
 		buttonIdleSprite:     sprts.GetIndex(sprites.Button),
@@ -270,20 +289,30 @@ func NewUI(world *ecs.World,
 	return ui
 }
 
-func (ui *UI) createRandomButton(rules *Rules, index int) {
-	t := rules.RandomTerrains[rand.Intn(len(rules.RandomTerrains))]
-	randSprite := uint16(rand.Int31n(math.MaxUint16))
-	allowRemove := terr.Properties[t].TerrainBits.Contains(terr.IsTerrain) &&
-		rand.Float64() < rules.SpecialCardProbability
+func (ui *UI) createRandomButton(index int) {
+	t := ui.randomTerrains.Terrains[index]
+	allowRemove := ui.randomTerrains.AllowRemove[index]
+	randSprite := ui.randomTerrains.Sprites[index]
 
 	button, _, id := ui.createButton(t, allowRemove, randSprite)
 	ui.randomContainers[index].AddChild(button)
 	ui.randomButtons[id] = randomButton{t, randSprite, allowRemove, button, index}
 }
 
-func (ui *UI) ReplaceButton(stock *Stock, rules *Rules, randTerrains *RandomTerrains, renderTick int64, target stdimage.Point) bool {
-	id := ui.selection.ButtonID
-	if bt, ok := ui.randomButtons[id]; ok {
+// CardIndex returns the card index of the random terrain button with the given ID.
+func (ui *UI) CardIndex(id int) (int, bool) {
+	bt, ok := ui.randomButtons[id]
+	return bt.Index, ok
+}
+
+// ReplaceButton animates the card at the given index towards the target screen position,
+// and replaces its button by the card that was drawn for the index.
+// In editor mode, the button is kept.
+func (ui *UI) ReplaceButton(index int, renderTick int64, target stdimage.Point) {
+	for _, bt := range ui.randomButtons {
+		if bt.Index != index {
+			continue
+		}
 		ui.animMapper.NewEntity(&comp.CardAnimation{
 			Point:      bt.Button.GetWidget().Rect.Min,
 			Target:     target,
@@ -291,42 +320,49 @@ func (ui *UI) ReplaceButton(stock *Stock, rules *Rules, randTerrains *RandomTerr
 			RandSprite: bt.RandomSprite,
 			StartTick:  renderTick,
 		})
-		if ui.editor.IsEditor {
-			return true
+		if !ui.editor.IsEditor {
+			ui.RefreshButton(index)
 		}
+		return
+	}
+}
 
-		randTerrains.TotalPlaced++
+// RefreshButton re-creates the random terrain button at the given index from the player's hand.
+// Re-selects the new button if the old one was selected.
+func (ui *UI) RefreshButton(index int) {
+	for id, bt := range ui.randomButtons {
+		if bt.Index != index {
+			continue
+		}
+		wasSelected := ui.selection.ButtonID == id
 
 		ui.randomContainers[bt.Index].RemoveChild(bt.Button)
 		delete(ui.randomButtons, id)
+		ui.createRandomButton(bt.Index)
 
-		ui.createRandomButton(rules, bt.Index)
-		ui.updateRandomTerrains()
-
+		if !wasSelected {
+			return
+		}
 		ui.ClearSelection()
 		// Try at the same index first
 		for id2, bt2 := range ui.randomButtons {
 			if bt2.Index == bt.Index && bt2.Terrain == bt.Terrain && bt2.AllowRemove == bt.AllowRemove {
 				ui.selectTerrain(bt2.Button, bt2.Terrain, id2, bt2.RandomSprite, false, bt2.AllowRemove)
-				return true
+				return
 			}
 		}
 		// Try to find any
 		for id2, bt2 := range ui.randomButtons {
 			if bt2.Terrain == bt.Terrain && bt2.AllowRemove == bt.AllowRemove {
 				ui.selectTerrain(bt2.Button, bt2.Terrain, id2, bt2.RandomSprite, false, bt2.AllowRemove)
-				return true
+				return
 			}
 		}
-		return true
-	}
-	if !stock.CanPay(terr.Properties[ui.selection.BuildType].BuildCost) {
-		ui.ClearSelection()
+		return
 	}
-	return false
 }
 
-func (ui *UI) ReplaceAllButtons(rules *Rules) {
+func (ui *UI) ReplaceAllButtons(rules *Rules, rng *Rand) {
 	ui.ClearSelection()
 	ids := []int{}
 	for id := range ui.randomButtons {
@@ -337,8 +373,8 @@ func (ui *UI) ReplaceAllButtons(rules *Rules) {
 		ui.randomContainers[bt.Index].RemoveChild(bt.Button)
 		delete(ui.randomButtons, id)
 
-		ui.createRandomButton(rules, bt.Index)
-		ui.updateRandomTerrains()
+		ui.randomTerrains.Draw(rules, rng, bt.Index)
+		ui.createRandomButton(bt.Index)
 	}
 }
 
@@ -427,7 +463,7 @@ func (ui *UI) createUI() *widget.Container {
 	return anchor
 }
 
-func (ui *UI) CreateRandomButtons(randomTerrains int) {
+func (ui *UI) CreateRandomButtons() {
 	ui.randomContainers = make([]*widget.Container, 0)
 	if ui.editor.IsEditor {
 		idx := 0
@@ -436,7 +472,7 @@ func (ui *UI) CreateRandomButtons(randomTerrains int) {
 			if !prop.TerrainBits.Contains(terr.CanBuild) || prop.TerrainBits.Contains(terr.CanBuy) {
 				continue
 			}
-			randSprite := uint16(rand.Int31n(math.MaxUint16))
+			randSprite := randomSprite(&ui.paletteRand)
 			button, _, id := ui.createButton(terr.Terrain(i), prop.TerrainBits.Contains(terr.IsTerrain), randSprite)
 
 			container := widget.NewContainer(widget.ContainerOpts.Layout(
@@ -448,44 +484,17 @@ func (ui *UI) CreateRandomButtons(randomTerrains int) {
 
 			idx++
 		}
-	} else if len(ui.randomTerrains.Terrains) == 0 {
-		for i := 0; i < randomTerrains; i++ {
-			randSprite := uint16(rand.Int31n(math.MaxUint16))
-			button, _, id := ui.createButton(terr.Default, false, randSprite)
-
-			container := widget.NewContainer(widget.ContainerOpts.Layout(
-				widget.NewGridLayout(widget.GridLayoutOpts.Columns(1))))
-			container.AddChild(button)
-			ui.randomContainers = append(ui.randomContainers, container)
-			ui.randomButtonsContainer.AddChild(container)
-			ui.randomButtons[id] = randomButton{terr.Default, randSprite, false, button, i}
-		}
-		ui.updateRandomTerrains()
 	} else {
-		for i, t := range ui.randomTerrains.Terrains {
-			rem := ui.randomTerrains.AllowRemove[i]
-			randSprite := uint16(rand.Int31n(math.MaxUint16))
-			button, _, id := ui.createButton(t, rem, randSprite)
-
+		for i := range ui.randomTerrains.Terrains {
 			container := widget.NewContainer(widget.ContainerOpts.Layout(
 				widget.NewGridLayout(widget.GridLayoutOpts.Columns(1))))
-			container.AddChild(button)
 			ui.randomContainers = append(ui.randomContainers, container)
 			ui.randomButtonsContainer.AddChild(container)
-			ui.randomButtons[id] = randomButton{t, randSprite, rem, button, i}
+			ui.createRandomButton(i)
 		}
 	}
 }
 
-func (ui *UI) updateRandomTerrains() {
-	ui.randomTerrains.Terrains = ui.randomTerrains.Terrains[:0]
-	ui.randomTerrains.AllowRemove = ui.randomTerrains.AllowRemove[:0]
-	for _, bt := range ui.randomButtons {
-		ui.randomTerrains.Terrains = append(ui.randomTerrains.Terrains, bt.Terrain)
-		ui.randomTerrains.AllowRemove = append(ui.randomTerrains.AllowRemove, bt.AllowRemove)
-	}
-}
-
 func (ui *UI) createHUD() *widget.Container {
 	anchor := widget.NewContainer(
 		widget.ContainerOpts.Layout(widget.NewAnchorLayout()),
@@ -611,8 +620,38 @@ func (ui *UI) createMenu() *widget.Container {
 	)
 	ui.mouseBlockers = append(ui.mouseBlockers, helpButton.GetWidget())
 
+	logScroll, logTooltipContainer := ui.createScrollPanel(logPanelHeight)
+
+	logLabel := widget.NewText(
+		widget.TextOpts.Text("", &ui.fonts.Default, ui.sprites.TextColor),
+		widget.TextOpts.Position(widget.TextPositionStart, widget.TextPositionCenter),
+		widget.TextOpts.MaxWidth(logPanelWidth),
+	)
+	logTooltipContainer.AddChild(logLabel)
+
+	logButton := widget.NewButton(
+		widget.ButtonOpts.WidgetOpts(
+			widget.WidgetOpts.ContextMenu(logScroll),
+			widget.WidgetOpts.ContextMenuCloseMode(widget.CLICK_OUT),
+		),
+		widget.ButtonOpts.Image(ui.defaultButtonImage()),
+		widget.ButtonOpts.Text("Log", &ui.fonts.Default, &widget.ButtonTextColor{
+			Idle: ui.sprites.TextColor,
+		}),
+		widget.ButtonOpts.TextPadding(widget.NewInsetsSimple(5)),
+		widget.ButtonOpts.ClickedHandler(func(args *widget.ButtonClickedEventArgs) {
+			logLabel.Label = ui.eventLogText()
+			if args.Button.GetWidget().ContextMenu != nil {
+				cx, cy := ebiten.CursorPosition()
+				args.Button.GetWidget().FireContextMenuEvent(nil, stdimage.Pt(cx, cy))
+			}
+		}),
+	)
+	ui.mouseBlockers = append(ui.mouseBlockers, logButton.GetWidget())
+
 	menuContainer.AddChild(menuButton)
 	menuContainer.AddChild(helpButton)
+	menuContainer.AddChild(logButton)
 
 	anchor.AddChild(menuContainer)
 
@@ -620,6 +659,23 @@ func (ui *UI) createMenu() *widget.Container {
 	return anchor
 }
 
+// eventLogText returns the entries of the event log, newest first.
+func (ui *UI) eventLogText() string {
+	entries := ui.eventLog.Entries
+	if len(entries) == 0 {
+		return "No events yet."
+	}
+	text := ""
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := &entries[i]
+		if text != "" {
+			text += "\n"
+		}
+		text += fmt.Sprintf("%s  %s", util.FormatDuration(time.Duration(e.Time)*time.Second), e.Message)
+	}
+	return text
+}
+
 func (ui *UI) createMainMenu() *widget.Container {
 	contextMenu := widget.NewContainer(
 		widget.ContainerOpts.Layout(widget.NewRowLayout(
@@ -749,12 +805,13 @@ func (ui *UI) createInfo() *widget.Container {
 	)
 
 	ui.resourceLabels = make([]*widget.Text, len(resource.Properties))
+	ui.resourceTooltips = make([]*widget.Text, len(resource.Properties))
 	for i := range resource.Properties {
-		cont, lab := ui.createLabel(resource.Properties[i].Short,
-			fmt.Sprintf("%s:\n   +production -consumption\n   (stock / max)", util.Capitalize(resource.Properties[i].Name)),
-			150, widget.TextPositionStart)
+		cont, lab, tooltip := ui.createTooltipLabel(resource.Properties[i].Short,
+			resourceTooltip(resource.Resource(i)), 150, widget.TextPositionStart)
 		infoContainer.AddChild(cont)
 		ui.resourceLabels[i] = lab
+		ui.resourceTooltips[i] = tooltip
 	}
 	cont, lab := ui.createLabel("Pop", "Population: current/max", 50, widget.TextPositionStart)
 	infoContainer.AddChild(cont)
@@ -771,7 +828,16 @@ func (ui *UI) createInfo() *widget.Container {
 	return infoContainer
 }
 
+func resourceTooltip(id resource.Resource) string {
+	return fmt.Sprintf("%s:\n   +production -consumption\n   (stock / max)", util.Capitalize(resource.Properties[id].Name))
+}
+
 func (ui *UI) createLabel(text, tooltip string, width int, align widget.TextPosition) (*widget.Container, *widget.Text) {
+	cont, counter, _ := ui.createTooltipLabel(text, tooltip, width, align)
+	return cont, counter
+}
+
+func (ui *UI) createTooltipLabel(text, tooltip string, width int, align widget.TextPosition) (*widget.Container, *widget.Text, *widget.Text) {
 	tooltipContainer := widget.NewContainer(
 		widget.ContainerOpts.Layout(widget.NewRowLayout(
 			widget.RowLayoutOpts.Direction(widget.DirectionVertical),
@@ -780,12 +846,12 @@ func (ui *UI) createLabel(text, tooltip string, width int, align widget.TextPosi
 		widget.ContainerOpts.AutoDisableChildren(),
 		widget.ContainerOpts.BackgroundImage(ui.background),
 	)
-	label := widget.NewText(
+	tooltipLabel := widget.NewText(
 		widget.TextOpts.Text(tooltip, &ui.fonts.Default, ui.sprites.TextColor),
 		widget.TextOpts.Position(widget.TextPositionStart, widget.TextPositionCenter),
 		widget.TextOpts.MaxWidth(360),
 	)
-	tooltipContainer.AddChild(label)
+	tooltipContainer.AddChild(tooltipLabel)
 
 	cont := widget.NewContainer(
 		widget.ContainerOpts.Layout(widget.NewRowLayout(
@@ -817,7 +883,7 @@ func (ui *UI) createLabel(text, tooltip string, width int, align widget.TextPosi
 	)
 	cont.AddChild(counter)
 
-	return cont, counter
+	return cont, counter, tooltipLabel
 }
 
 func (ui *UI) prepareButtons() {
@@ -832,14 +898,27 @@ func (ui *UI) prepareButtons() {
 		anyInfo := false
 		costs := ""
 		if len(props.BuildCost) > 0 {
-			costs = "Cost: "
-			for i, cost := range props.BuildCost {
-				if i > 0 {
-					costs += ", "
-				}
-				costs += fmt.Sprintf("%d %s", cost.Amount, resource.Properties[cost.Resource].Short)
+			costs = fmt.Sprintf("Cost: %s\n", ui.costsToString(props.BuildCost))
+			anyInfo = true
+		}
+		if props.UpgradesTo != terr.Air {
+			costs += fmt.Sprintf("Upgrades to %s\n", terr.Properties[props.UpgradesTo].Name)
+			anyInfo = true
+		}
+		for j := range terr.Properties {
+			from := &terr.Properties[j]
+			if from.UpgradesTo == terr.Terrain(i) && terr.Terrain(i) != terr.Air {
+				costs += fmt.Sprintf("Upgrade from %s: %s\n", from.Name, ui.costsToString(from.UpgradeCost))
+				anyInfo = true
+			}
+		}
+		salvage := ""
+		if props.Salvage.BuildCost > 0 || props.Salvage.ReturnStock {
+			salvage = fmt.Sprintf("Salvage: %d%% of cost", props.Salvage.BuildCost)
+			if props.Salvage.ReturnStock {
+				salvage += ", local stock"
 			}
-			costs += "\n"
+			salvage += "\n"
 			anyInfo = true
 		}
 		maxProd := ""
@@ -865,6 +944,12 @@ func (ui *UI) prepareButtons() {
 			anyInfo = true
 		}
 
+		inputs := ""
+		if props.Production.HasInputs() {
+			inputs = fmt.Sprintf("Input: %s per unit\n", ui.costsToString(props.Production.Inputs))
+			anyInfo = true
+		}
+
 		storage := ""
 		if props.TerrainBits.Contains(terr.IsWarehouse) {
 			storage = fmt.Sprintf("Stores: %s\n", ui.resourcesToString(props.Storage))
@@ -874,12 +959,23 @@ func (ui *UI) prepareButtons() {
 		text := fmt.Sprintf("%s\n\n%s%s.", util.Capitalize(props.Name), props.Description, maxProd)
 
 		if anyInfo {
-			text += fmt.Sprintf("\n\n%s%s%s%s%s", costs, requires, pop, radius, storage)
+			text += fmt.Sprintf("\n\n%s%s%s%s%s%s%s", costs, salvage, requires, inputs, pop, radius, storage)
 		}
 		ui.buttonTooltip[i] = text
 	}
 }
 
+func (ui *UI) costsToString(cost []terr.ResourceAmount) string {
+	out := ""
+	for i, c := range cost {
+		if i > 0 {
+			out += ", "
+		}
+		out += fmt.Sprintf("%d %s", c.Amount, resource.Properties[c.Resource].Short)
+	}
+	return out
+}
+
 func (ui *UI) resourcesToString(res []uint8) string {
 	out := ""
 	cnt := 0
@@ -919,8 +1015,10 @@ func (ui *UI) createButtonImage(t terr.Terrain, randSprite uint16, allowRemove b
 	}
 
 	sp1 := ui.sprites.GetRand(idx, 0, int(randSprite))
+	scale := ui.sprites.IconScale(sp1)
 	op := ebiten.DrawImageOptions{}
-	op.GeoM.Translate(float64(xOff), float64(ui.buttonSize.X-sp1.Bounds().Dy()-height-yOff))
+	op.GeoM.Scale(scale, scale)
+	op.GeoM.Translate(float64(xOff), float64(ui.buttonSize.X-height-yOff)-float64(sp1.Bounds().Dy())*scale)
 	img.DrawImage(sp1, &op)
 
 	if allowRemove {
@@ -14,6 +14,8 @@ type View struct {
 	MouseOffset int
 	// Current zoom factor.
 	Zoom float64
+	// Whether the traffic overlay is shown, for congestion.
+	ShowTraffic bool
 }
 
 func NewView(tileWidth, tileHeight int) View {
@@ -71,6 +73,11 @@ func (v *View) ScreenToGlobal(x, y int) (int, int) {
 		v.Y + int(float64(y)/v.Zoom)
 }
 
+func (v *View) GlobalToScreen(x, y int) (int, int) {
+	return int(float64(x-v.X) * v.Zoom),
+		int(float64(y-v.Y) * v.Zoom)
+}
+
 func (v *View) MapBounds(screenWidth, screenHeight int) image.Rectangle {
 	p := v.GlobalToTile(v.ScreenToGlobal(0, 0))
 	xMin := p.X
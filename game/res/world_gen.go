@@ -0,0 +1,39 @@
+package res
+
+// WorldGen resource, holding the parameters for procedural generation of a new world.
+// Only used when a new world is started, not for maps and save games.
+type WorldGen struct {
+	// Side length of the generated area, in tiles.
+	// Zero for a world that starts with a single tile.
+	Size int
+	// Seed for the terrain layout. Independent of the gameplay random numbers.
+	Seed uint64
+	// Fraction of generated tiles that are water.
+	Water float64
+	// Fraction of generated tiles that are hills.
+	Hills float64
+	// Fraction of generated tiles that are desert.
+	Desert float64
+	// Fraction of plains and hills covered by trees.
+	Forest float64
+	// Fraction of land tiles covered by rocks.
+	Rocks float64
+}
+
+// NewWorldGen returns the default generator parameters, for a world of the given size.
+func NewWorldGen(size int, seed uint64) WorldGen {
+	return WorldGen{
+		Size:   size,
+		Seed:   seed,
+		Water:  0.2,
+		Hills:  0.15,
+		Desert: 0.1,
+		Forest: 0.3,
+		Rocks:  0.03,
+	}
+}
+
+// IsEnabled returns whether a world is generated.
+func (g *WorldGen) IsEnabled() bool {
+	return g.Size > 0
+}
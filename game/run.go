@@ -14,6 +14,7 @@ import (
 	"github.com/mlange-42/tiny-world/game/res/achievements"
 	"github.com/mlange-42/tiny-world/game/resource"
 	"github.com/mlange-42/tiny-world/game/save"
+	"github.com/mlange-42/tiny-world/game/sim"
 	"github.com/mlange-42/tiny-world/game/sys"
 	"github.com/mlange-42/tiny-world/game/terr"
 )
@@ -39,8 +40,8 @@ func Run(data embed.FS) {
 	}
 }
 
-func run(g *Game, name string, mapLoc save.MapLocation, load save.LoadType, isEditor bool) {
-	if err := runGame(g, load, name, mapLoc, "paper", isEditor); err != nil {
+func run(g *Game, name string, mapLoc save.MapLocation, load save.LoadType, seed uint64, gen res.WorldGen, isEditor bool) {
+	if err := runGame(g, load, name, mapLoc, "paper", seed, gen, isEditor); err != nil {
 		panic(err)
 	}
 }
@@ -48,6 +49,7 @@ func run(g *Game, name string, mapLoc save.MapLocation, load save.LoadType, isEd
 func runMenu(g *Game, tab int) {
 	ebiten.SetVsyncEnabled(true)
 	g.App = app.New()
+	g.saveGame = nil
 
 	ecs.AddResource(&g.App.World, &g.Screen)
 
@@ -58,8 +60,8 @@ func runMenu(g *Game, tab int) {
 
 	fonts := res.NewFonts(GameData)
 	ui := menu.NewUI(GameData, saveFolder, mapsFolder, tab, &sprites, &fonts, achievements,
-		func(name string, mapLoc save.MapLocation, load save.LoadType, isEditor bool) {
-			run(g, name, mapLoc, load, isEditor)
+		func(name string, mapLoc save.MapLocation, load save.LoadType, seed uint64, gen res.WorldGen, isEditor bool) {
+			run(g, name, mapLoc, load, seed, gen, isEditor)
 		},
 		func(tab int) {
 			runMenu(g, tab)
@@ -74,7 +76,7 @@ func runMenu(g *Game, tab int) {
 	g.App.Initialize()
 }
 
-func runGame(g *Game, load save.LoadType, name string, mapLoc save.MapLocation, tileSet string, isEditor bool) error {
+func runGame(g *Game, load save.LoadType, name string, mapLoc save.MapLocation, tileSet string, seed uint64, gen res.WorldGen, isEditor bool) error {
 	ebiten.SetVsyncEnabled(true)
 
 	g.App = app.New()
@@ -85,55 +87,13 @@ func runGame(g *Game, load save.LoadType, name string, mapLoc save.MapLocation,
 
 	// =========== Resources ===========
 
-	rules := res.NewRules(GameData, "data/json/rules.json")
-	ecs.AddResource(&g.App.World, &rules)
-
-	gameSpeed := res.GameSpeed{
-		MinSpeed: -2,
-		MaxSpeed: 3,
-	}
-	ecs.AddResource(&g.App.World, &gameSpeed)
-
-	gameTick := res.GameTick{}
-	ecs.AddResource(&g.App.World, &gameTick)
-
-	terrain := res.NewTerrain(rules.WorldSize, rules.WorldSize)
-	ecs.AddResource(&g.App.World, &terrain)
-
-	terrainEntities := res.TerrainEntities{Grid: res.NewGrid[ecs.Entity](rules.WorldSize, rules.WorldSize)}
-	ecs.AddResource(&g.App.World, &terrainEntities)
-
-	landUse := res.NewLandUse(rules.WorldSize, rules.WorldSize)
-	ecs.AddResource(&g.App.World, &landUse)
-
-	landUseEntities := res.LandUseEntities{Grid: res.NewGrid[ecs.Entity](rules.WorldSize, rules.WorldSize)}
-	ecs.AddResource(&g.App.World, &landUseEntities)
-
-	buildable := res.NewBuildable(rules.WorldSize, rules.WorldSize)
-	ecs.AddResource(&g.App.World, &buildable)
-
-	selection := res.Selection{}
-	ecs.AddResource(&g.App.World, &selection)
-
-	bounds := res.WorldBounds{}
-	ecs.AddResource(&g.App.World, &bounds)
-
-	editor := res.EditorMode{IsEditor: isEditor}
-	ecs.AddResource(&g.App.World, &editor)
-
-	saveTime := res.SaveTime{}
-	ecs.AddResource(&g.App.World, &saveTime)
-
-	randomTerrains := res.RandomTerrains{
-		TotalAvailable: rules.InitialRandomTerrains,
+	sim.AddResources(&g.App.World, GameData, "data/json/rules.json", TPS, seed, isEditor)
+	if load == save.LoadTypeNone {
+		*ecs.GetResource[res.WorldGen](&g.App.World) = gen
 	}
-	ecs.AddResource(&g.App.World, &randomTerrains)
-
-	update := res.UpdateInterval{
-		Interval:  TPS,
-		Countdown: 60,
+	if load != save.LoadTypeGame {
+		sim.RecordReplay(&g.App.World, seed, load, mapsFolder, mapLoc, isEditor)
 	}
-	ecs.AddResource(&g.App.World, &update)
 
 	sprites := res.NewSprites(GameData, "data/gfx", tileSet)
 	ecs.AddResource(&g.App.World, &sprites)
@@ -141,55 +101,26 @@ func runGame(g *Game, load save.LoadType, name string, mapLoc save.MapLocation,
 	view := res.NewView(sprites.TileWidth, sprites.TileHeight)
 	ecs.AddResource(&g.App.World, &view)
 
-	production := res.NewProduction()
-	ecs.AddResource(&g.App.World, &production)
-
-	stock := res.NewStock(rules.InitialResources)
-	ecs.AddResource(&g.App.World, &stock)
-
 	ecs.AddResource(&g.App.World, &g.Screen)
 	ecs.AddResource(&g.App.World, &g.Mouse)
 
-	saveEvent := res.SaveEvent{}
-	ecs.AddResource(&g.App.World, &saveEvent)
-
 	fonts := res.NewFonts(GameData)
 	ecs.AddResource(&g.App.World, &fonts)
 
-	factory := res.NewEntityFactory(&g.App.World)
-	ecs.AddResource(&g.App.World, &factory)
-
 	achievements := achievements.New(&g.App.World, GameData, "data/json/achievements.json", "user/achievements.json")
 	ecs.AddResource(&g.App.World, achievements)
 
 	// =========== Systems ===========
 
-	if load == save.LoadTypeGame {
-		g.App.AddSystem(&sys.InitTerrainLoaded{})
-	} else if load == save.LoadTypeMap {
-		g.App.AddSystem(&sys.InitTerrainMap{
-			FS:        GameData,
-			MapFolder: mapsFolder,
-			Map:       mapLoc,
-		})
-	} else {
-		g.App.AddSystem(&sys.InitTerrain{})
-	}
+	sim.AddInitSystem(g.App, load, GameData, mapsFolder, mapLoc)
 	g.App.AddSystem(&sys.InitUI{})
 
-	g.App.AddSystem(&sys.Tick{})
-	g.App.AddSystem(&sys.UpdateProduction{})
-	g.App.AddSystem(&sys.UpdatePopulation{})
-	g.App.AddSystem(&sys.DoProduction{})
-	g.App.AddSystem(&sys.DoConsumption{})
-	g.App.AddSystem(&sys.Haul{})
-	g.App.AddSystem(&sys.UpdateStats{})
-	g.App.AddSystem(&sys.RemoveMarkers{
-		MaxTime: TPS,
+	sim.AddSystems(g.App, TPS)
+	// Commands pushed by Build are applied by ApplyCommands in the next update.
+	g.App.AddSystem(&sys.Build{
+		UndoKey: ebiten.KeyZ,
+		RedoKey: ebiten.KeyY,
 	})
-
-	g.App.AddSystem(&sys.Build{})
-	g.App.AddSystem(&sys.AssignHaulers{})
 	g.App.AddSystem(&sys.Achievements{
 		PlayerFile: "user/achievements.json",
 	})
@@ -205,23 +136,37 @@ func runGame(g *Game, load save.LoadType, name string, mapLoc save.MapLocation,
 
 	g.App.AddSystem(&sys.UpdateUI{})
 	g.App.AddSystem(&sys.Cheats{})
-	g.App.AddSystem(&sys.SaveGame{
-		SaveFolder:   "save",
-		MapFolder:    "maps",
-		Name:         name,
-		MainMenuFunc: func() { runMenu(g, 0) },
-	})
+
+	settings, err := save.LoadSettings(GameData, "data/json/save.json")
+	if err != nil {
+		return err
+	}
+	// Loading an autosave or emergency slot continues the world, and saves to it.
+	saveGame := &sys.SaveGame{
+		SaveFolder:       "save",
+		MapFolder:        "maps",
+		Name:             save.WorldName(name),
+		Encoding:         settings.Encoding,
+		AutosaveInterval: int64(settings.AutosaveInterval) * TPS,
+		AutosaveSlots:    settings.AutosaveSlots,
+		MainMenuFunc:     func() { runMenu(g, 0) },
+	}
+	g.App.AddSystem(saveGame)
 	g.App.AddSystem(&sys.GameControls{
 		PauseKey:      ebiten.KeySpace,
 		SlowerKey:     '[',
 		FasterKey:     ']',
 		FullscreenKey: ebiten.KeyF11,
+		SaveKey:       ebiten.KeyS,
+		TrafficKey:    ebiten.KeyT,
 	})
 
 	// =========== UI Systems ===========
 
 	g.App.AddUISystem(&render.CenterView{})
 	g.App.AddUISystem(&render.Terrain{})
+	g.App.AddUISystem(&render.Traffic{})
+	g.App.AddUISystem(&render.Fire{})
 	g.App.AddUISystem(&render.Markers{
 		MinOffset: view.TileHeight * 2,
 		MaxOffset: view.TileHeight*2 + 30,
@@ -239,7 +184,10 @@ func runGame(g *Game, load save.LoadType, name string, mapLoc save.MapLocation,
 		if err != nil {
 			return err
 		}
-		selection.Reset()
+		ecs.GetResource[res.Selection](&g.App.World).Reset()
+		if err := sim.ContinueReplay(&g.App.World, saveFolder, name); err != nil {
+			log.Printf("Error loading replay, not recording: %s", err.Error())
+		}
 
 		view.TileWidth = sprites.TileWidth
 		view.TileHeight = sprites.TileHeight
@@ -249,6 +197,9 @@ func runGame(g *Game, load save.LoadType, name string, mapLoc save.MapLocation,
 
 	// =========== Run ===========
 
+	// Armed before initialization, so that a crash while initializing is saved too.
+	// Not armed if loading failed above, to not overwrite emergency saves with a broken world.
+	g.saveGame = saveGame
 	g.App.Initialize()
 
 	return nil
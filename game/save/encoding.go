@@ -0,0 +1,70 @@
+package save
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// Encoding of save games.
+type Encoding uint8
+
+const (
+	// EncodingJSON writes save games as plain JSON.
+	EncodingJSON Encoding = iota
+	// EncodingGzip writes save games as gzip-compressed JSON.
+	EncodingGzip
+)
+
+var encodingNames = []string{"json", "gzip"}
+
+func (e Encoding) String() string {
+	return encodingNames[e]
+}
+
+// ParseEncoding returns the encoding with the given name.
+func ParseEncoding(name string) (Encoding, error) {
+	for i, n := range encodingNames {
+		if n == name {
+			return Encoding(i), nil
+		}
+	}
+	return EncodingJSON, fmt.Errorf("unknown save game encoding '%s', expected one of %v", name, encodingNames)
+}
+
+// gzipMagic are the first bytes of gzip data.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// encodeSave encodes a serialized world with the given encoding.
+func encodeSave(jsData []byte, enc Encoding) ([]byte, error) {
+	if enc == EncodingJSON {
+		return jsData, nil
+	}
+	buf := bytes.Buffer{}
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(jsData); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeSave detects the encoding of a save game and returns its JSON.
+func decodeSave(data []byte) ([]byte, Encoding, error) {
+	if !bytes.HasPrefix(data, gzipMagic) {
+		return data, EncodingJSON, nil
+	}
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, EncodingGzip, err
+	}
+	defer r.Close()
+	jsData, err := io.ReadAll(r)
+	if err != nil {
+		return nil, EncodingGzip, fmt.Errorf("corrupt compressed save game: %w", err)
+	}
+	return jsData, EncodingGzip, nil
+}
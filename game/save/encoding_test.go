@@ -0,0 +1,77 @@
+package save
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestParseEncoding(t *testing.T) {
+	for _, enc := range []Encoding{EncodingJSON, EncodingGzip} {
+		if got, err := ParseEncoding(enc.String()); err != nil || got != enc {
+			t.Errorf("ParseEncoding(%q) = %v, %v, want %v", enc.String(), got, err, enc)
+		}
+	}
+	if _, err := ParseEncoding("zip"); err == nil {
+		t.Errorf("ParseEncoding(\"zip\") succeeded, want error")
+	}
+}
+
+func TestEncodeSave(t *testing.T) {
+	jsData, err := os.ReadFile("testdata/v0.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, enc := range []Encoding{EncodingJSON, EncodingGzip} {
+		t.Run(enc.String(), func(t *testing.T) {
+			data, err := encodeSave(jsData, enc)
+			if err != nil {
+				t.Fatalf("encodeSave() error = %v", err)
+			}
+			if enc == EncodingJSON && !bytes.Equal(data, jsData) {
+				t.Errorf("encodeSave() changed plain JSON")
+			}
+			if enc == EncodingGzip && len(data) >= len(jsData) {
+				t.Errorf("encodeSave() compressed %d bytes to %d bytes", len(jsData), len(data))
+			}
+
+			decoded, detected, err := decodeSave(data)
+			if err != nil {
+				t.Fatalf("decodeSave() error = %v", err)
+			}
+			if detected != enc {
+				t.Errorf("decodeSave() detected %v, want %v", detected, enc)
+			}
+			if !bytes.Equal(decoded, jsData) {
+				t.Errorf("decodeSave() returned other data than encoded")
+			}
+		})
+	}
+}
+
+func TestDecodeSaveCorrupt(t *testing.T) {
+	valid, err := encodeSave([]byte(`{"Resources":{}}`), EncodingGzip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The gzip trailer holds a checksum and the length of the data.
+	flipped := bytes.Clone(valid)
+	flipped[len(flipped)-5] ^= 0xff
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "header only", data: gzipMagic},
+		{name: "missing trailer", data: valid[:len(valid)-8]},
+		{name: "wrong checksum", data: flipped},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, enc, err := decodeSave(tt.data); err == nil || enc != EncodingGzip {
+				t.Errorf("decodeSave() detected %v, error = %v, want gzip and error", enc, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package save
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"time"
+
+	"github.com/mlange-42/ark/ecs"
+	"github.com/mlange-42/tiny-world/game/comp"
+	"github.com/mlange-42/tiny-world/game/res"
+	"github.com/mlange-42/tiny-world/game/resource"
+	"github.com/mlange-42/tiny-world/game/terr"
+)
+
+// ThumbnailSize is the maximum width and height of save game thumbnails, in pixels.
+const ThumbnailSize = 96
+
+// updateInfo updates the summary of a save game, including the thumbnail.
+func updateInfo(world *ecs.World) error {
+	info := ecs.GetResource[res.SaveInfo](world)
+	stock := ecs.GetResource[res.Stock](world)
+
+	// The update interval is the number of ticks per second.
+	ticks := ecs.GetResource[res.GameTick](world).Tick
+	info.PlayTime = time.Duration(ticks) * time.Second / time.Duration(ecs.GetResource[res.UpdateInterval](world).Interval)
+	info.Population = stock.Population
+	info.Resources = map[string]int{}
+	for i := range resource.Properties {
+		info.Resources[resource.Properties[i].Name] = stock.Res[i]
+	}
+
+	info.Buildings = 0
+	query := ecs.NewFilter1[comp.Terrain](world).Query()
+	for query.Next() {
+		if terr.Buildings.Contains(query.Get().Terrain) {
+			info.Buildings++
+		}
+	}
+
+	var err error
+	info.Thumbnail, err = thumbnail(world)
+	return err
+}
+
+// thumbnail draws a minimap of the world bounds, with one pixel per tile
+// or less for large worlds, and returns it PNG-encoded.
+func thumbnail(world *ecs.World) ([]byte, error) {
+	terrain := ecs.GetResource[res.Terrain](world)
+	landUse := ecs.GetResource[res.LandUse](world)
+	bounds := ecs.GetResource[res.WorldBounds](world)
+
+	width, height := bounds.Dx()+1, bounds.Dy()+1
+	if width <= 0 || height <= 0 {
+		return nil, nil
+	}
+	step := (max(width, height) + ThumbnailSize - 1) / ThumbnailSize
+	img := image.NewRGBA(image.Rect(0, 0, (width+step-1)/step, (height+step-1)/step))
+
+	for y := 0; y < img.Bounds().Dy(); y++ {
+		for x := 0; x < img.Bounds().Dx(); x++ {
+			xx, yy := bounds.Min.X+x*step, bounds.Min.Y+y*step
+			if !terrain.Contains(xx, yy) {
+				continue
+			}
+			c := terr.Properties[landUse.Get(xx, yy)].MinimapColor
+			if c.A == 0 {
+				c = terr.Properties[terrain.Get(xx, yy)].MinimapColor
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+
+	buf := bytes.Buffer{}
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
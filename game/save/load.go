@@ -9,10 +9,11 @@ import (
 	"slices"
 	"strings"
 
+	serde "github.com/mlange-42/ark-serde"
 	"github.com/mlange-42/ark/ecs"
 	"github.com/mlange-42/tiny-world/game/comp"
 	"github.com/mlange-42/tiny-world/game/maps"
-	"github.com/mlange-42/tiny-world/game/terr"
+	"github.com/mlange-42/tiny-world/game/res"
 )
 
 func LoadWorld(world *ecs.World, folder, name string) error {
@@ -26,24 +27,62 @@ func LoadWorld(world *ecs.World, folder, name string) error {
 	_ = ecs.ComponentID[comp.Path](world)
 	_ = ecs.ComponentID[comp.Hauler](world)
 	_ = ecs.ComponentID[comp.HaulerSprite](world)
+	_ = ecs.ComponentID[comp.Delivery](world)
 	_ = ecs.ComponentID[comp.ProductionMarker](world)
+	_ = ecs.ComponentID[comp.Burning](world)
 
-	return loadWorld(world, folder, name)
+	data, err := loadWorldData(folder, name)
+	if err != nil {
+		return err
+	}
+	jsData, _, err := decodeSave(data)
+	if err != nil {
+		return fmt.Errorf("loading save game '%s': %w", name, err)
+	}
+	jsData, migrated, err := migrateSave(jsData)
+	if err != nil {
+		return fmt.Errorf("loading save game '%s': %w", name, err)
+	}
+	if err := serde.Deserialize(jsData, world); err != nil {
+		return err
+	}
+	ecs.GetResource[res.SaveFormat](world).Migrated = migrated
+	return nil
+}
+
+// LoadReplay loads the replay of a game.
+// Returns an error wrapping [fs.ErrNotExist] if there is no replay for the game.
+func LoadReplay(folder, name string) (res.Replay, error) {
+	jsData, err := loadReplayFromFile(folder, name)
+	if err != nil {
+		return res.Replay{}, err
+	}
+	replay := res.Replay{}
+	if err := json.Unmarshal(jsData, &replay); err != nil {
+		return res.Replay{}, err
+	}
+	return replay, nil
 }
 
 func LoadAchievements(file string, completed *[]string) error {
 	return loadAchievements(file, completed)
 }
 
+// ListSaveGames lists the save games in the given folder, most recent first.
+// Autosave and emergency slots are listed in [SaveGame.Slots] of their world.
 func ListSaveGames(folder string) ([]SaveGame, error) {
 	games, err := listGames(folder)
 	if err != nil {
 		return nil, err
 	}
 
-	slices.SortFunc(games, func(a, b SaveGame) int {
+	byTime := func(a, b SaveGame) int {
 		return -a.Time.Compare(b.Time)
-	})
+	}
+	slices.SortFunc(games, byTime)
+	games = groupSlots(games)
+	// Worlds without a main save game are sorted by their most recent slot.
+	slices.SortStableFunc(games, byTime)
 
 	return games, nil
 }
@@ -57,57 +96,6 @@ func LoadMap(f fs.FS, folder string, mapLoc MapLocation) (maps.Map, error) {
 	return ParseMap(mapStr)
 }
 
-func ParseMap(mapStr string) (maps.Map, error) {
-	helper := mapJs{}
-	err := json.Unmarshal([]byte(mapStr), &helper)
-	if err != nil {
-		return maps.Map{}, nil
-	}
-
-	terrains := []rune{}
-	for tStr, cnt := range helper.Terrains {
-		rn := []rune(tStr)
-		if len(rn) > 1 {
-			return maps.Map{}, fmt.Errorf("symbols must be single runes. Got '%s'", tStr)
-		}
-		sym := rn[0]
-		t, ok := terr.SymbolToTerrain[sym]
-		if !ok {
-			return maps.Map{}, fmt.Errorf("symbol not found: '%s'", tStr)
-		}
-		var ter terr.Terrain
-		if t.LandUse != terr.Air {
-			ter = t.LandUse
-		} else {
-			ter = t.Terrain
-		}
-		props := &terr.Properties[ter]
-		if props.TerrainBits.Contains(terr.CanBuy) || !props.TerrainBits.Contains(terr.CanBuild) {
-			return maps.Map{}, fmt.Errorf("terrain '%s' ('%s') is not a natural feature", props.Name, tStr)
-		}
-		for i := 0; i < cnt; i++ {
-			terrains = append(terrains, sym)
-		}
-	}
-
-	var result [][]rune
-	for _, s := range helper.Map {
-		if len(s) > 0 {
-			runes := []rune(s)
-			result = append(result, runes)
-		}
-	}
-
-	return maps.Map{
-		Data:                  result,
-		Terrains:              terrains,
-		InitialRandomTerrains: helper.InitialRandomTerrains,
-		Center:                helper.Center,
-		Achievements:          helper.Achievements,
-		Description:           strings.Join(helper.Description, "\n"),
-	}, nil
-}
-
 func LoadMapData(f fs.FS, folder string, mapLoc MapLocation) (MapInfo, error) {
 	mapStr, err := loadMap(f, folder, mapLoc)
 	if err != nil {
@@ -116,10 +104,16 @@ func LoadMapData(f fs.FS, folder string, mapLoc MapLocation) (MapInfo, error) {
 	helper := mapInfoJs{}
 	err = json.Unmarshal([]byte(mapStr), &helper)
 	if err != nil {
-		return MapInfo{}, nil
+		return MapInfo{}, jsonError([]byte(mapStr), err)
 	}
 
-	return MapInfo{Achievements: helper.Achievements, Description: strings.Join(helper.Description, "\n")}, nil
+	return MapInfo{
+		Achievements: helper.Achievements,
+		Title:        helper.Title,
+		Author:       helper.Author,
+		Difficulty:   helper.Difficulty,
+		Description:  strings.Join(helper.Description, "\n"),
+	}, nil
 }
 
 func ListMaps(f fs.FS, folder string) ([]MapLocation, error) {
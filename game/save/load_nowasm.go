@@ -4,35 +4,45 @@ package save
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
 	"path"
 	"path/filepath"
 	"strings"
-
-	serde "github.com/mlange-42/ark-serde"
-	"github.com/mlange-42/ark/ecs"
 )
 
-func loadWorld(world *ecs.World, folder, name string) error {
-	jsData, err := os.ReadFile(path.Join(folder, name) + ".json")
-	if err != nil {
-		return err
+func loadWorldData(folder, name string) ([]byte, error) {
+	for _, ext := range saveExtensions {
+		data, err := os.ReadFile(path.Join(folder, name) + ext)
+		if err == nil || !errors.Is(err, fs.ErrNotExist) {
+			return data, err
+		}
 	}
+	return nil, fmt.Errorf("save game %s: %w", name, fs.ErrNotExist)
+}
 
-	return serde.Deserialize(jsData, world)
+func loadReplayFromFile(folder, name string) ([]byte, error) {
+	return os.ReadFile(path.Join(folder, name) + ".replay")
 }
 
-func loadSaveTime(folder, name string) (saveTime, error) {
-	jsData, err := os.ReadFile(path.Join(folder, name) + ".json")
+// loadSaveInfo loads the save time and summary of a save game.
+func loadSaveInfo(folder, name string) (saveGameResources, error) {
+	data, err := loadWorldData(folder, name)
 	if err != nil {
-		return saveTime{}, err
+		return saveGameResources{}, err
+	}
+	jsData, _, err := decodeSave(data)
+	if err != nil {
+		return saveGameResources{}, err
 	}
 	helper := saveGameInfo{}
 	err = json.Unmarshal(jsData, &helper)
 	if err != nil {
-		return saveTime{}, err
+		return saveGameResources{}, err
 	}
-	return helper.Resources.SaveTime, nil
+	return helper.Resources, nil
 }
 
 func loadAchievements(file string, completed *[]string) error {
@@ -55,16 +65,19 @@ func listGames(folder string) ([]SaveGame, error) {
 		if file.IsDir() {
 			continue
 		}
-		ext := filepath.Ext(file.Name())
-		if ext == ".json" {
-			base := strings.TrimSuffix(file.Name(), ".json")
-			info, err := loadSaveTime(folder, base)
+		for _, ext := range saveExtensions {
+			if !strings.HasSuffix(file.Name(), ext) {
+				continue
+			}
+			base := strings.TrimSuffix(file.Name(), ext)
+			info, err := loadSaveInfo(folder, base)
 			if err != nil {
 				return nil, err
 			}
 			games = append(games, SaveGame{
 				Name: base,
-				Time: info.Time,
+				Time: info.SaveTime.Time,
+				Info: info.SaveInfo,
 			})
 		}
 	}
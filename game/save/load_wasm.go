@@ -3,35 +3,62 @@
 package save
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
+	"io/fs"
 	"strings"
 	"syscall/js"
-
-	serde "github.com/mlange-42/ark-serde"
-	"github.com/mlange-42/ark/ecs"
 )
 
-func loadWorld(world *ecs.World, folder, name string) error {
+func loadWorldData(folder, name string) ([]byte, error) {
 	_ = folder
 
 	storage := js.Global().Get("localStorage")
-	jsData := storage.Call("getItem", saveGamePrefix+name)
+	data := storage.Call("getItem", saveGamePrefix+name)
+	if data.IsNull() {
+		return nil, fmt.Errorf("save game %s: %w", name, fs.ErrNotExist)
+	}
 
-	return serde.Deserialize([]byte(jsData.String()), world)
+	// Compressed save games are stored base64-encoded, see saveToFile.
+	str := data.String()
+	if strings.HasPrefix(str, "{") {
+		return []byte(str), nil
+	}
+	return base64.StdEncoding.DecodeString(str)
 }
 
-func loadSaveTime(folder, name string) (saveTime, error) {
+func loadReplayFromFile(folder, name string) ([]byte, error) {
 	_ = folder
 
 	storage := js.Global().Get("localStorage")
-	jsData := storage.Call("getItem", saveGamePrefix+name)
+	jsData := storage.Call("getItem", replayPrefix+name)
+
+	if jsData.IsNull() {
+		return nil, fmt.Errorf("replay %s: %w", name, fs.ErrNotExist)
+	}
+	return []byte(jsData.String()), nil
+}
+
+// loadSaveInfo loads the save time and summary of a save game.
+func loadSaveInfo(folder, name string) (saveGameResources, error) {
+	_ = folder
+
+	data, err := loadWorldData(folder, name)
+	if err != nil {
+		return saveGameResources{}, err
+	}
+	jsData, _, err := decodeSave(data)
+	if err != nil {
+		return saveGameResources{}, err
+	}
 
 	helper := saveGameInfo{}
-	err := json.Unmarshal([]byte(jsData.String()), &helper)
+	err = json.Unmarshal(jsData, &helper)
 	if err != nil {
-		return saveTime{}, err
+		return saveGameResources{}, err
 	}
-	return helper.Resources.SaveTime, nil
+	return helper.Resources, nil
 }
 
 func loadAchievements(file string, completed *[]string) error {
@@ -58,14 +85,15 @@ func listGames(folder string) ([]SaveGame, error) {
 		key := storage.Call("key", i).String()
 		if strings.HasPrefix(key, saveGamePrefix) {
 			name := strings.TrimPrefix(key, saveGamePrefix)
-			info, err := loadSaveTime(folder, name)
+			info, err := loadSaveInfo(folder, name)
 			if err != nil {
 				return nil, err
 			}
 
 			games = append(games, SaveGame{
 				Name: name,
-				Time: info.Time,
+				Time: info.SaveTime.Time,
+				Info: info.SaveInfo,
 			})
 		}
 	}
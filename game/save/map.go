@@ -0,0 +1,370 @@
+package save
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	gmaps "github.com/mlange-42/tiny-world/game/maps"
+	"github.com/mlange-42/tiny-world/game/terr"
+)
+
+// ParseMap parses a map from JSON.
+// Supports the original symbol-based format (version 1),
+// and the layered format (version 2).
+// Errors in the map file are returned as [MapError], with their position in the file.
+func ParseMap(mapStr string) (gmaps.Map, error) {
+	data := []byte(mapStr)
+
+	version := mapVersionJs{}
+	if err := json.Unmarshal(data, &version); err != nil {
+		return gmaps.Map{}, jsonError(data, err)
+	}
+
+	switch version.Version {
+	case 0, 1:
+		return parseMapV1(data)
+	case 2:
+		return parseMapV2(data)
+	default:
+		src := newMapSource(data)
+		return gmaps.Map{}, src.keyError("version", fmt.Errorf("unsupported map version %d", version.Version))
+	}
+}
+
+func parseMapV1(data []byte) (gmaps.Map, error) {
+	helper := mapJs{}
+	if err := json.Unmarshal(data, &helper); err != nil {
+		return gmaps.Map{}, jsonError(data, err)
+	}
+	src := newMapSource(data)
+
+	terrains, err := parseRandomTerrains(helper.Terrains, func(key string) (terr.Terrain, error) {
+		rn := []rune(key)
+		if len(rn) != 1 {
+			return terr.Air, fmt.Errorf("symbols must be single runes. Got '%s'", key)
+		}
+		t, ok := terr.SymbolToTerrain[rn[0]]
+		if !ok {
+			return terr.Air, fmt.Errorf("symbol not found: '%s'", key)
+		}
+		if t.LandUse != terr.Air {
+			return t.LandUse, nil
+		}
+		return t.Terrain, nil
+	})
+	if err != nil {
+		return gmaps.Map{}, src.keyError("terrains", err)
+	}
+
+	terrain := [][]terr.Terrain{}
+	landUse := [][]terr.Terrain{}
+	for row, s := range helper.Map {
+		if len(s) == 0 {
+			continue
+		}
+		terrRow := make([]terr.Terrain, 0, len(s))
+		luRow := make([]terr.Terrain, 0, len(s))
+		for col, rn := range []rune(s) {
+			t, ok := terr.SymbolToTerrain[rn]
+			if !ok {
+				return gmaps.Map{}, src.cellError("map", row, col, fmt.Errorf("unknown map symbol '%s'", string(rn)))
+			}
+			terrRow = append(terrRow, t.Terrain)
+			luRow = append(luRow, t.LandUse)
+		}
+		terrain = append(terrain, terrRow)
+		landUse = append(landUse, luRow)
+	}
+
+	return gmaps.Map{
+		Terrain:               terrain,
+		LandUse:               landUse,
+		Terrains:              terrains,
+		InitialRandomTerrains: helper.InitialRandomTerrains,
+		Center:                helper.Center,
+		Achievements:          helper.Achievements,
+		Description:           strings.Join(helper.Description, "\n"),
+	}, nil
+}
+
+func parseMapV2(data []byte) (gmaps.Map, error) {
+	helper := mapV2Js{}
+	if err := json.Unmarshal(data, &helper); err != nil {
+		return gmaps.Map{}, jsonError(data, err)
+	}
+	src := newMapSource(data)
+
+	if helper.Difficulty != "" && !slices.Contains(Difficulties, helper.Difficulty) {
+		return gmaps.Map{}, src.keyError("difficulty",
+			fmt.Errorf("unknown difficulty '%s', expected one of %s", helper.Difficulty, strings.Join(Difficulties, ", ")))
+	}
+	if helper.Rules != nil {
+		if err := helper.Rules.Validate(); err != nil {
+			return gmaps.Map{}, src.keyError("rules", err)
+		}
+	}
+
+	terrains, err := parseRandomTerrains(helper.Terrains, func(key string) (terr.Terrain, error) {
+		t, ok := terr.TerrainID(key)
+		if !ok {
+			return terr.Air, fmt.Errorf("unknown terrain '%s'", key)
+		}
+		return t, nil
+	})
+	if err != nil {
+		return gmaps.Map{}, src.keyError("terrains", err)
+	}
+
+	legend := map[rune]terr.Terrain{'.': terr.Air}
+	for _, sym := range slices.Sorted(maps.Keys(helper.Legend)) {
+		rn := []rune(sym)
+		if len(rn) != 1 {
+			return gmaps.Map{}, src.keyError("legend", fmt.Errorf("symbols must be single runes. Got '%s'", sym))
+		}
+		t, ok := terr.TerrainID(helper.Legend[sym])
+		if !ok {
+			return gmaps.Map{}, src.keyError("legend", fmt.Errorf("unknown terrain '%s' for symbol '%s'", helper.Legend[sym], sym))
+		}
+		legend[rn[0]] = t
+	}
+
+	terrain, err := parseLayer(src, "terrain", helper.Terrain, legend)
+	if err != nil {
+		return gmaps.Map{}, err
+	}
+	landUse, err := parseLayer(src, "land_use", helper.LandUse, legend)
+	if err != nil {
+		return gmaps.Map{}, err
+	}
+	if len(landUse) == 0 {
+		landUse = make([][]terr.Terrain, len(terrain))
+		for y, row := range terrain {
+			landUse[y] = make([]terr.Terrain, len(row))
+		}
+	}
+	if len(landUse) != len(terrain) {
+		return gmaps.Map{}, src.keyError("land_use",
+			fmt.Errorf("layer has %d rows, but terrain has %d", len(landUse), len(terrain)))
+	}
+
+	for y, row := range terrain {
+		if len(landUse[y]) != len(row) {
+			return gmaps.Map{}, src.cellError("land_use", y, 0,
+				fmt.Errorf("row has %d cells, but terrain has %d", len(landUse[y]), len(row)))
+		}
+		for x, t := range row {
+			props := &terr.Properties[t]
+			if t != terr.Air && (!props.TerrainBits.Contains(terr.IsTerrain) || !props.TerrainBits.Contains(terr.CanBuild)) {
+				return gmaps.Map{}, src.cellError("terrain", y, x, fmt.Errorf("'%s' is not a terrain", props.Name))
+			}
+			lu := landUse[y][x]
+			if lu == terr.Air {
+				continue
+			}
+			luProps := &terr.Properties[lu]
+			if luProps.TerrainBits.Contains(terr.IsTerrain) || !luProps.TerrainBits.Contains(terr.CanBuild) {
+				return gmaps.Map{}, src.cellError("land_use", y, x, fmt.Errorf("'%s' is not a land use", luProps.Name))
+			}
+			if t == terr.Air {
+				return gmaps.Map{}, src.cellError("land_use", y, x, fmt.Errorf("%s without terrain", luProps.Name))
+			}
+			if !luProps.BuildOn.Contains(t) {
+				return gmaps.Map{}, src.cellError("land_use", y, x, fmt.Errorf("%s can't be built on %s", luProps.Name, props.Name))
+			}
+		}
+	}
+
+	if helper.Center.Y < 0 || helper.Center.Y >= len(terrain) ||
+		helper.Center.X < 0 || helper.Center.X >= len(terrain[helper.Center.Y]) {
+		return gmaps.Map{}, src.keyError("center", fmt.Errorf("center %v is outside of the map", helper.Center))
+	}
+
+	return gmaps.Map{
+		Terrain:               terrain,
+		LandUse:               landUse,
+		Terrains:              terrains,
+		InitialRandomTerrains: helper.InitialRandomTerrains,
+		Center:                helper.Center,
+		Achievements:          helper.Achievements,
+		Title:                 helper.Title,
+		Author:                helper.Author,
+		Difficulty:            helper.Difficulty,
+		Description:           strings.Join(helper.Description, "\n"),
+		Rules:                 helper.Rules,
+	}, nil
+}
+
+// parseRandomTerrains converts the terrains to draw cards from, by key and count.
+// Keys are sorted for a deterministic order of cards.
+func parseRandomTerrains(counts map[string]int, toTerrain func(key string) (terr.Terrain, error)) ([]terr.Terrain, error) {
+	terrains := []terr.Terrain{}
+	for _, key := range slices.Sorted(maps.Keys(counts)) {
+		ter, err := toTerrain(key)
+		if err != nil {
+			return nil, err
+		}
+		props := &terr.Properties[ter]
+		if props.TerrainBits.Contains(terr.CanBuy) || !props.TerrainBits.Contains(terr.CanBuild) {
+			return nil, fmt.Errorf("terrain '%s' ('%s') is not a natural feature", props.Name, key)
+		}
+		for i := 0; i < counts[key]; i++ {
+			terrains = append(terrains, ter)
+		}
+	}
+	return terrains, nil
+}
+
+// parseLayer converts the rows of a layer to terrains, using the legend.
+func parseLayer(src *mapSource, key string, rows []string, legend map[rune]terr.Terrain) ([][]terr.Terrain, error) {
+	result := make([][]terr.Terrain, len(rows))
+	for y, s := range rows {
+		row := make([]terr.Terrain, 0, len(s))
+		for x, rn := range []rune(s) {
+			t, ok := legend[rn]
+			if !ok {
+				return nil, src.cellError(key, y, x, fmt.Errorf("symbol '%s' not in legend", string(rn)))
+			}
+			row = append(row, t)
+		}
+		result[y] = row
+	}
+	return result, nil
+}
+
+// jsonError converts JSON syntax and type errors to a [MapError].
+func jsonError(data []byte, err error) error {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		// The offset is after the invalid character.
+		return newMapError(data, syntaxErr.Offset-1, err)
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return newMapError(data, typeErr.Offset, err)
+	}
+	return err
+}
+
+// newMapError creates a [MapError] for the given byte offset in the file.
+func newMapError(data []byte, offset int64, err error) *MapError {
+	offset = min(max(offset, 0), int64(len(data)))
+	before := data[:offset]
+	line := bytes.Count(before, []byte{'\n'}) + 1
+	lineStart := bytes.LastIndexByte(before, '\n') + 1
+	return &MapError{
+		Line:   line,
+		Column: utf8.RuneCount(before[lineStart:]) + 1,
+		Err:    err,
+	}
+}
+
+// mapSource locates top-level values and layer rows in the JSON of a map, for error messages.
+type mapSource struct {
+	data []byte
+	// Offsets of top-level values, by key.
+	keys map[string]int64
+	// Offsets of array elements of top-level values, by key.
+	elements map[string][]int64
+}
+
+// newMapSource indexes the given JSON. Data must be valid JSON.
+func newMapSource(data []byte) *mapSource {
+	src := mapSource{
+		data:     data,
+		keys:     map[string]int64{},
+		elements: map[string][]int64{},
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return &src
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return &src
+		}
+		key, _ := tok.(string)
+		src.keys[key] = src.skipSeparators(dec.InputOffset())
+
+		if !bytes.HasPrefix(data[src.keys[key]:], []byte{'['}) {
+			var value json.RawMessage
+			if err := dec.Decode(&value); err != nil {
+				return &src
+			}
+			continue
+		}
+		if _, err := dec.Token(); err != nil {
+			return &src
+		}
+		elements := []int64{}
+		for dec.More() {
+			elements = append(elements, src.skipSeparators(dec.InputOffset()))
+			var value json.RawMessage
+			if err := dec.Decode(&value); err != nil {
+				return &src
+			}
+		}
+		if _, err := dec.Token(); err != nil {
+			return &src
+		}
+		src.elements[key] = elements
+	}
+	return &src
+}
+
+// skipSeparators returns the offset of the next value, skipping whitespace, colons and commas.
+func (s *mapSource) skipSeparators(offset int64) int64 {
+	for offset < int64(len(s.data)) {
+		switch s.data[offset] {
+		case ' ', '\t', '\r', '\n', ':', ',':
+			offset++
+		default:
+			return offset
+		}
+	}
+	return offset
+}
+
+// keyError creates a [MapError] at the value of the given top-level key.
+func (s *mapSource) keyError(key string, err error) error {
+	return newMapError(s.data, s.keys[key], err)
+}
+
+// cellError creates a [MapError] at the given cell of a layer.
+func (s *mapSource) cellError(key string, row, col int, err error) error {
+	elements, ok := s.elements[key]
+	if !ok || row >= len(elements) {
+		return s.keyError(key, err)
+	}
+	offset := elements[row] + 1 // Skip the opening quote.
+	for i := 0; i < col && offset < int64(len(s.data)); i++ {
+		if s.data[offset] != '\\' {
+			_, size := utf8.DecodeRune(s.data[offset:])
+			offset += int64(size)
+			continue
+		}
+		if offset+1 < int64(len(s.data)) && s.data[offset+1] == 'u' {
+			offset += 6
+			// Runes outside the basic plane are escaped as surrogate pairs.
+			if bytes.HasPrefix(s.data[offset:], []byte(`\u`)) && isHighSurrogate(s.data[offset-4:offset]) {
+				offset += 6
+			}
+			continue
+		}
+		offset += 2
+	}
+	return newMapError(s.data, offset, err)
+}
+
+// isHighSurrogate checks whether the given hex digits encode a UTF-16 high surrogate.
+func isHighSurrogate(hex []byte) bool {
+	h := strings.ToLower(string(hex))
+	return len(h) == 4 && h[0] == 'd' && h[1] >= '8' && h[1] <= 'b'
+}
@@ -0,0 +1,253 @@
+package save
+
+import (
+	"errors"
+	"image"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mlange-42/tiny-world/game/resource"
+	"github.com/mlange-42/tiny-world/game/terr"
+)
+
+func TestMain(m *testing.M) {
+	data := os.DirFS("../..")
+	resource.Prepare(data, "data/json/resources.json")
+	terr.Prepare(data, "data/json/terrain.json")
+	os.Exit(m.Run())
+}
+
+// testMap is a version 2 map, written with one key per line and one layer row per line.
+// Values are raw JSON, and layer rows are raw JSON string content.
+type testMap struct {
+	Version    string
+	Difficulty string
+	Rules      string
+	Terrains   string
+	Center     string
+	Legend     string
+	Terrain    []string
+	LandUse    []string
+}
+
+// newTestMap returns a valid map. Its keys start at the following lines and columns:
+//
+//	version     2, 14
+//	difficulty  3, 17
+//	rules       4, 12
+//	terrains    5, 15
+//	center      6, 13
+//	legend      7, 13
+//	terrain     8, 14; rows from line 9, with cells from column 6
+//	land_use   12, 15; rows from line 13, with cells from column 6
+func newTestMap() testMap {
+	return testMap{
+		Version:    `2`,
+		Difficulty: `"easy"`,
+		Rules:      `{}`,
+		Terrains:   `{"plains": 2, "tree": 1}`,
+		Center:     `{"X": 1, "Y": 1}`,
+		Legend:     `{"-": "plains", "^": "hills", "~": "water", "t": "tree", "f": "farm"}`,
+		Terrain:    []string{"-^~", "---"},
+		LandUse:    []string{"t..", ".f."},
+	}
+}
+
+func (m testMap) String() string {
+	layer := func(rows []string) string {
+		quoted := make([]string, len(rows))
+		for i, r := range rows {
+			quoted[i] = `    "` + r + `"`
+		}
+		return "[\n" + strings.Join(quoted, ",\n") + "\n  ]"
+	}
+	return strings.Join([]string{
+		`{`,
+		`  "version": ` + m.Version + `,`,
+		`  "difficulty": ` + m.Difficulty + `,`,
+		`  "rules": ` + m.Rules + `,`,
+		`  "terrains": ` + m.Terrains + `,`,
+		`  "center": ` + m.Center + `,`,
+		`  "legend": ` + m.Legend + `,`,
+		`  "terrain": ` + layer(m.Terrain) + `,`,
+		`  "land_use": ` + layer(m.LandUse),
+		`}`,
+	}, "\n")
+}
+
+func TestParseMapV2(t *testing.T) {
+	m, err := ParseMap(newTestMap().String())
+	if err != nil {
+		t.Fatalf("ParseMap() error = %v", err)
+	}
+
+	plains, hills, water := terr.ToTerrain("plains"), terr.ToTerrain("hills"), terr.ToTerrain("water")
+	tree, farm := terr.ToTerrain("tree"), terr.ToTerrain("farm")
+	tests := []struct {
+		name string
+		got  any
+		want any
+	}{
+		{"terrain", m.Terrain, [][]terr.Terrain{{plains, hills, water}, {plains, plains, plains}}},
+		{"land use", m.LandUse, [][]terr.Terrain{{tree, terr.Air, terr.Air}, {terr.Air, farm, terr.Air}}},
+		{"random terrains", m.Terrains, []terr.Terrain{plains, plains, tree}},
+		{"center", m.Center, image.Pt(1, 1)},
+		{"difficulty", m.Difficulty, "easy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !reflect.DeepEqual(tt.got, tt.want) {
+				t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMapV2Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		edit   func(m *testMap)
+		line   int
+		column int
+		// Expected part of the error message.
+		err string
+	}{
+		{
+			name: "syntax error",
+			edit: func(m *testMap) { m.Difficulty = `easy` },
+			line: 3, column: 17, err: "invalid character 'e'",
+		},
+		{
+			// Type errors are reported after the value.
+			name: "type error",
+			edit: func(m *testMap) { m.Center = `{"X": "1", "Y": 1}` },
+			line: 6, column: 22, err: "cannot unmarshal string",
+		},
+		{
+			name: "unsupported version",
+			edit: func(m *testMap) { m.Version = `3` },
+			line: 2, column: 14, err: "unsupported map version 3",
+		},
+		{
+			name: "unknown difficulty",
+			edit: func(m *testMap) { m.Difficulty = `"insane"` },
+			line: 3, column: 17, err: "unknown difficulty 'insane'",
+		},
+		{
+			name: "invalid rules",
+			edit: func(m *testMap) { m.Rules = `{"fire_probability": 2}` },
+			line: 4, column: 12, err: "rule fire_probability must be in range [0, 1]",
+		},
+		{
+			name: "unknown random terrain",
+			edit: func(m *testMap) { m.Terrains = `{"plains": 2, "lava": 1}` },
+			line: 5, column: 15, err: "unknown terrain 'lava'",
+		},
+		{
+			name: "building as random terrain",
+			edit: func(m *testMap) { m.Terrains = `{"farm": 1}` },
+			line: 5, column: 15, err: "terrain 'farm' ('farm') is not a natural feature",
+		},
+		{
+			name: "legend symbol too long",
+			edit: func(m *testMap) { m.Legend = `{"--": "plains"}` },
+			line: 7, column: 13, err: "symbols must be single runes. Got '--'",
+		},
+		{
+			name: "legend with unknown terrain",
+			edit: func(m *testMap) { m.Legend = `{"-": "plains", "x": "lava"}` },
+			line: 7, column: 13, err: "unknown terrain 'lava' for symbol 'x'",
+		},
+		{
+			name: "symbol not in legend",
+			edit: func(m *testMap) { m.Terrain[1] = "--x" },
+			line: 10, column: 8, err: "symbol 'x' not in legend",
+		},
+		{
+			name: "symbol after multi-byte rune",
+			edit: func(m *testMap) {
+				m.Legend = `{"-": "plains", "ü": "hills"}`
+				m.Terrain[0] = "üx-"
+			},
+			line: 9, column: 7, err: "symbol 'x' not in legend",
+		},
+		{
+			name: "symbol after escaped rune",
+			edit: func(m *testMap) {
+				m.Legend = `{"-": "plains", "ü": "hills"}`
+				m.Terrain[0] = `\u00fcx-`
+			},
+			line: 9, column: 12, err: "symbol 'x' not in legend",
+		},
+		{
+			name: "symbol after surrogate pair",
+			edit: func(m *testMap) {
+				m.Legend = `{"-": "plains", "🌲": "hills"}`
+				m.Terrain[0] = `\ud83c\udf32x-`
+			},
+			line: 9, column: 18, err: "symbol 'x' not in legend",
+		},
+		{
+			name: "land use in terrain layer",
+			edit: func(m *testMap) { m.Terrain[0] = "t^~" },
+			line: 9, column: 6, err: "'tree' is not a terrain",
+		},
+		{
+			name: "terrain in land use layer",
+			edit: func(m *testMap) { m.LandUse[1] = "..^" },
+			line: 14, column: 8, err: "'hills' is not a land use",
+		},
+		{
+			name: "land use without terrain",
+			edit: func(m *testMap) {
+				m.Terrain[0] = "-^."
+				m.LandUse[0] = "..t"
+			},
+			line: 13, column: 8, err: "tree without terrain",
+		},
+		{
+			name: "land use on wrong terrain",
+			edit: func(m *testMap) { m.LandUse[0] = "..t" },
+			line: 13, column: 8, err: "tree can't be built on water",
+		},
+		{
+			name: "missing land use row",
+			edit: func(m *testMap) { m.LandUse = m.LandUse[:1] },
+			line: 12, column: 15, err: "layer has 1 rows, but terrain has 2",
+		},
+		{
+			name: "short land use row",
+			edit: func(m *testMap) { m.LandUse[1] = ".f" },
+			line: 14, column: 6, err: "row has 2 cells, but terrain has 3",
+		},
+		{
+			name: "center outside",
+			edit: func(m *testMap) { m.Center = `{"X": 3, "Y": 1}` },
+			line: 6, column: 13, err: "center (3,1) is outside of the map",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestMap()
+			tt.edit(&m)
+			_, err := ParseMap(m.String())
+			if err == nil {
+				t.Fatal("ParseMap() succeeded, want error")
+			}
+			var mapErr *MapError
+			if !errors.As(err, &mapErr) {
+				t.Fatalf("ParseMap() error %q is not a MapError", err)
+			}
+			if mapErr.Line != tt.line || mapErr.Column != tt.column {
+				t.Errorf("ParseMap() error at line %d, column %d, want line %d, column %d",
+					mapErr.Line, mapErr.Column, tt.line, tt.column)
+			}
+			if !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("ParseMap() error = %q, want error containing %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,345 @@
+package save
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/mlange-42/tiny-world/game/res"
+	"github.com/mlange-42/tiny-world/game/resource"
+	"github.com/mlange-42/tiny-world/game/terr"
+)
+
+// SaveVersion is the version of the save game format written by [SaveWorld].
+// Increment it and add a migration for every change of components or resources
+// that older save games can't be loaded into.
+const SaveVersion = 1
+
+// migration upgrades a save game to the next version.
+type migration struct {
+	// Description of the changes, for error messages.
+	Description string
+	Migrate     func(s *saveData) error
+}
+
+// migrations upgrade save games step by step, indexed by the version they upgrade from.
+var migrations = []migration{
+	{Description: "add format version and name tables", Migrate: migrateV0},
+}
+
+// legacyTerrains are the terrains of save games without format version, in ID order.
+var legacyTerrains = []string{
+	"air", "buildable", "bulldoze", "plains", "hills", "water", "desert", "building_base", "fence",
+	"path", "bridge", "field", "pasture", "tree", "rock", "farm", "shepherd", "fisherman", "lumberjack",
+	"mason", "windmill", "watermill", "warehouse", "tower", "castle", "church", "monastery",
+}
+
+// legacyResources are the resources of save games without format version, in ID order.
+var legacyResources = []string{"food", "wood", "stones"}
+
+// migrateV0 adds the format to save games written before versioning,
+// with the terrains and resources of the last release without it.
+func migrateV0(s *saveData) error {
+	s.Format.Terrains = legacyTerrains
+	s.Format.Resources = legacyResources
+	return nil
+}
+
+// currentFormat returns the save game format for the current version and game data.
+func currentFormat() res.SaveFormat {
+	format := res.SaveFormat{
+		Version:   SaveVersion,
+		Terrains:  make([]string, len(terr.Properties)),
+		Resources: make([]string, len(resource.Properties)),
+	}
+	for i := range terr.Properties {
+		format.Terrains[i] = terr.Properties[i].Name
+	}
+	for i := range resource.Properties {
+		format.Resources[i] = resource.Properties[i].Name
+	}
+	return format
+}
+
+// migrateSave upgrades a serialized save game to the current version,
+// and converts terrain and resource IDs to the current game data by name.
+// Returns the data unchanged if no migration is required.
+func migrateSave(jsData []byte) ([]byte, bool, error) {
+	s, err := newSaveData(jsData)
+	if err != nil {
+		return nil, false, err
+	}
+
+	version := s.Format.Version
+	if version > SaveVersion {
+		return nil, false, fmt.Errorf("save game has version %d, but the game only supports versions up to %d; please update the game", version, SaveVersion)
+	}
+	current := currentFormat()
+	if version == SaveVersion && slices.Equal(s.Format.Terrains, current.Terrains) && slices.Equal(s.Format.Resources, current.Resources) {
+		return jsData, false, nil
+	}
+
+	for v := version; v < SaveVersion; v++ {
+		m := &migrations[v]
+		if err := m.Migrate(s); err != nil {
+			return nil, false, fmt.Errorf("can't migrate save game from version %d to %d (%s): %w", v, v+1, m.Description, err)
+		}
+		s.Format.Version = v + 1
+	}
+
+	if err := s.remap(&current); err != nil {
+		return nil, false, fmt.Errorf("can't convert save game to the current game data: %w", err)
+	}
+
+	jsData, err = s.encode()
+	if err != nil {
+		return nil, false, err
+	}
+	return jsData, true, nil
+}
+
+const (
+	formatKey     = "res.SaveFormat"
+	resourcesKey  = "Resources"
+	componentsKey = "Components"
+)
+
+// saveData is a save game, decoded to the level of individual components and resources.
+type saveData struct {
+	Format     res.SaveFormat
+	top        map[string]json.RawMessage
+	resources  map[string]json.RawMessage
+	components []map[string]json.RawMessage
+}
+
+func newSaveData(jsData []byte) (*saveData, error) {
+	s := saveData{}
+	if err := json.Unmarshal(jsData, &s.top); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(s.top[resourcesKey], &s.resources); err != nil {
+		return nil, fmt.Errorf("invalid save game resources: %w", err)
+	}
+	if comps, ok := s.top[componentsKey]; ok {
+		if err := json.Unmarshal(comps, &s.components); err != nil {
+			return nil, fmt.Errorf("invalid save game components: %w", err)
+		}
+	}
+	if format, ok := s.resources[formatKey]; ok {
+		if err := json.Unmarshal(format, &s.Format); err != nil {
+			return nil, fmt.Errorf("invalid save game format: %w", err)
+		}
+	}
+	return &s, nil
+}
+
+func (s *saveData) encode() ([]byte, error) {
+	var err error
+	if s.resources[formatKey], err = json.Marshal(&s.Format); err != nil {
+		return nil, err
+	}
+	if s.top[resourcesKey], err = json.Marshal(s.resources); err != nil {
+		return nil, err
+	}
+	if s.components != nil {
+		if s.top[componentsKey], err = json.Marshal(s.components); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(s.top)
+}
+
+// Resource calls the given function with the fields of a resource, if it is in the save game.
+func (s *saveData) Resource(name string, fn func(fields map[string]any) error) error {
+	data, ok := s.resources[name]
+	if !ok {
+		return nil
+	}
+	out, err := updateFields(data, fn)
+	if err != nil {
+		return fmt.Errorf("in resource %s: %w", name, err)
+	}
+	s.resources[name] = out
+	return nil
+}
+
+// Components calls the given function with the fields of each component of the given type.
+func (s *saveData) Components(name string, fn func(fields map[string]any) error) error {
+	for _, entity := range s.components {
+		data, ok := entity[name]
+		if !ok {
+			continue
+		}
+		out, err := updateFields(data, fn)
+		if err != nil {
+			return fmt.Errorf("in component %s: %w", name, err)
+		}
+		entity[name] = out
+	}
+	return nil
+}
+
+func updateFields(data json.RawMessage, fn func(fields map[string]any) error) (json.RawMessage, error) {
+	fields := map[string]any{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&fields); err != nil {
+		return nil, err
+	}
+	if err := fn(fields); err != nil {
+		return nil, err
+	}
+	return json.Marshal(fields)
+}
+
+// remap converts terrain and resource IDs from the save game's name tables to the given format.
+func (s *saveData) remap(target *res.SaveFormat) error {
+	terrains := idTable{Kind: "terrain", Names: s.Format.Terrains, IDs: lookupTable(s.Format.Terrains, target.Terrains)}
+	resources := idTable{Kind: "resource", Names: s.Format.Resources, IDs: lookupTable(s.Format.Resources, target.Resources), Size: len(target.Resources)}
+
+	steps := []func() error{
+		func() error { return s.Components("comp.Terrain", terrains.Field("Terrain")) },
+		func() error { return s.Components("comp.CardAnimation", terrains.Field("Terrain")) },
+		func() error { return s.Resource("res.RandomTerrains", terrains.List("Terrains")) },
+		func() error {
+			return s.Resource("res.Rules", all(terrains.List("RandomTerrains"), resources.Array("InitialResources", true)))
+		},
+
+		func() error { return s.Components("comp.Production", resources.Field("Resource")) },
+		func() error { return s.Components("comp.ProductionMarker", resources.Field("Resource")) },
+		func() error { return s.Components("comp.Hauler", resources.Field("Hauls")) },
+		func() error { return s.Components("comp.InputBuffer", resources.Array("Stock", true)) },
+		func() error {
+			return s.Components("comp.Consumption", all(resources.Array("Amount", true), resources.Array("Countdown", false)))
+		},
+		func() error {
+			return s.Components("comp.Supply", all(resources.Array("Stock", true), resources.Array("Requested", false)))
+		},
+		func() error { return s.Components("comp.Warehouse", resources.Array("Stock", true)) },
+		func() error {
+			return s.Resource("res.Stock", all(resources.Array("Res", true), resources.Array("Cap", false), resources.Array("Total", false)))
+		},
+		func() error {
+			return s.Resource("res.Production", all(resources.Array("Prod", false), resources.Array("Cons", false)))
+		},
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			return err
+		}
+	}
+
+	s.Format.Terrains = target.Terrains
+	s.Format.Resources = target.Resources
+	return nil
+}
+
+// lookupTable returns the new ID for each old name, or -1 if it does not exist anymore.
+func lookupTable(oldNames, newNames []string) []int {
+	table := make([]int, len(oldNames))
+	for i, name := range oldNames {
+		table[i] = slices.Index(newNames, name)
+	}
+	return table
+}
+
+// idTable converts IDs of terrains or resources in fields of components and resources.
+type idTable struct {
+	Kind  string
+	Names []string
+	// New ID by old ID. -1 for removed entries.
+	IDs []int
+	// Number of new IDs, for arrays indexed by ID.
+	Size int
+}
+
+func (t *idTable) convert(value any) (any, error) {
+	n, ok := value.(json.Number)
+	if !ok {
+		return nil, fmt.Errorf("expected %s ID, got %v", t.Kind, value)
+	}
+	id, err := n.Int64()
+	if err != nil {
+		return nil, err
+	}
+	if id < 0 || int(id) >= len(t.IDs) {
+		return nil, fmt.Errorf("unknown %s ID %d", t.Kind, id)
+	}
+	if t.IDs[id] < 0 {
+		return nil, fmt.Errorf("%s '%s' does not exist anymore", t.Kind, t.Names[id])
+	}
+	return t.IDs[id], nil
+}
+
+// Field converts a single ID.
+func (t *idTable) Field(name string) func(fields map[string]any) error {
+	return func(fields map[string]any) error {
+		value, ok := fields[name]
+		if !ok {
+			return nil
+		}
+		var err error
+		fields[name], err = t.convert(value)
+		return err
+	}
+}
+
+// List converts a list of IDs.
+func (t *idTable) List(name string) func(fields map[string]any) error {
+	return func(fields map[string]any) error {
+		list, _ := fields[name].([]any)
+		for i, value := range list {
+			var err error
+			if list[i], err = t.convert(value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// Array re-orders an array indexed by ID. Entries of new IDs are zero.
+// If strict, non-zero entries of removed IDs are an error. Otherwise, they are dropped.
+func (t *idTable) Array(name string, strict bool) func(fields map[string]any) error {
+	return func(fields map[string]any) error {
+		list, _ := fields[name].([]any)
+		if len(list) == 0 {
+			return nil
+		}
+		var zero any = json.Number("0")
+		if _, ok := list[0].(bool); ok {
+			zero = false
+		}
+		result := make([]any, t.Size)
+		for i := range result {
+			result[i] = zero
+		}
+		for oldID, value := range list {
+			if oldID < len(t.IDs) && t.IDs[oldID] >= 0 {
+				result[t.IDs[oldID]] = value
+				continue
+			}
+			if strict && value != zero {
+				if oldID < len(t.Names) {
+					return fmt.Errorf("%s '%s' does not exist anymore, but the save game has %v of it", t.Kind, t.Names[oldID], value)
+				}
+				return fmt.Errorf("unknown %s ID %d in %s", t.Kind, oldID, name)
+			}
+		}
+		fields[name] = result
+		return nil
+	}
+}
+
+// all combines field conversions.
+func all(fns ...func(fields map[string]any) error) func(fields map[string]any) error {
+	return func(fields map[string]any) error {
+		for _, fn := range fns {
+			if err := fn(fields); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,264 @@
+package save
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mlange-42/tiny-world/game/comp"
+	"github.com/mlange-42/tiny-world/game/res"
+	"github.com/mlange-42/tiny-world/game/terr"
+)
+
+// testdata/v0.json is a save game without format version,
+// with the terrain and resource IDs of the last release before versioning.
+func TestMigrateLegacySave(t *testing.T) {
+	legacy, err := os.ReadFile("testdata/v0.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jsData, migrated, err := migrateSave(legacy)
+	if err != nil {
+		t.Fatalf("migrateSave() error = %v", err)
+	}
+	if !migrated {
+		t.Fatal("migrateSave() did not migrate the legacy save game")
+	}
+	old, err := newSaveData(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := newSaveData(jsData)
+	if err != nil {
+		t.Fatalf("can't decode migrated save game: %v", err)
+	}
+
+	current := currentFormat()
+	if s.Format.Version != SaveVersion ||
+		!slices.Equal(s.Format.Terrains, current.Terrains) || !slices.Equal(s.Format.Resources, current.Resources) {
+		t.Errorf("migrated save game has format %+v, want %+v", s.Format, current)
+	}
+
+	// Terrains keep their names, whatever their new IDs are.
+	terrains := []string{}
+	for _, entity := range s.components {
+		if data, ok := entity["comp.Terrain"]; ok {
+			var c comp.Terrain
+			decode(t, data, &c)
+			terrains = append(terrains, terr.Properties[c.Terrain].Name)
+		}
+	}
+	var card comp.CardAnimation
+	decode(t, []byte(savedValue(s, "comp.CardAnimation")), &card)
+	var rules res.Rules
+	decode(t, []byte(savedValue(s, "res.Rules")), &rules)
+	var cards res.RandomTerrains
+	decode(t, []byte(savedValue(s, "res.RandomTerrains")), &cards)
+
+	tests := []struct {
+		name string
+		got  []string
+		want []string
+	}{
+		{"terrains", terrains, []string{"plains", "warehouse", "plains", "lumberjack", "tree", "castle", "monastery"}},
+		{"card animation", terrainNames(card.Terrain), []string{"hills"}},
+		{"rules", terrainNames(rules.RandomTerrains...), []string{"plains", "plains", "hills", "water", "desert", "tree", "rock"}},
+		{"random terrains", terrainNames(cards.Terrains...), []string{"plains", "tree", "water", "plains", "hills", "rock"}},
+	}
+	for _, tt := range tests {
+		if !slices.Equal(tt.got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+
+	// Resources keep their IDs, and arrays get entries for planks.
+	resources := map[string]string{
+		"res.Stock":        `{"Cap":[45,45,45,0],"Res":[31,20,18,0],"Total":[50,22,0,0],"Population":3,"MaxPopulation":40}`,
+		"res.Production":   `{"Prod":[0,2,0,0],"Cons":[6,0,1,0]}`,
+		"comp.Production":  savedValue(old, "comp.Production"),
+		"comp.Consumption": `{"Amount":[1,0,0,0],"Countdown":[23,0,0,0],"IsSatisfied":true}`,
+		"comp.Hauler":      savedValue(old, "comp.Hauler"),
+	}
+	for name, js := range resources {
+		var got, want any
+		decode(t, []byte(savedValue(s, name)), &got)
+		decode(t, []byte(js), &want)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestMigrateSave(t *testing.T) {
+	current := currentFormat()
+	lumberjack := int(terr.ToTerrain("lumberjack"))
+
+	tests := []struct {
+		name string
+		save string
+		// Whether the save game is expected to change.
+		migrated bool
+		// Expected JSON of resources and components after the migration, by name.
+		want map[string]string
+		// Expected part of the error message. Empty if no error is expected.
+		wantErr string
+	}{
+		{
+			name:     "current",
+			save:     saveJSON(&current, `"res.Stock":{"Res":[1,2,3,4]}`, `{"comp.Terrain":{"Terrain":`+strconv.Itoa(lumberjack)+`}}`),
+			migrated: false,
+		},
+		{
+			name: "reordered resources",
+			save: saveJSON(&res.SaveFormat{Version: 1, Terrains: current.Terrains, Resources: []string{"wood", "food", "stones", "planks"}},
+				`"res.Stock":{"Res":[6,5,7,8]}`,
+				`{"comp.Production":{"Resource":0},"comp.InputBuffer":{"Stock":[1,0,0,0]}}`,
+				`{"comp.Supply":{"Stock":[1,2,0,0],"Requested":[true,false,false,false]}}`,
+			),
+			migrated: true,
+			want: map[string]string{
+				"res.Stock":        `{"Res":[5,6,7,8]}`,
+				"comp.Production":  `{"Resource":1}`,
+				"comp.InputBuffer": `{"Stock":[0,1,0,0]}`,
+				"comp.Supply":      `{"Requested":[false,true,false,false],"Stock":[2,1,0,0]}`,
+			},
+		},
+		{
+			name: "unused removed terrain",
+			save: saveJSON(&res.SaveFormat{Version: 1, Terrains: append(slices.Clone(current.Terrains), "ruins"), Resources: current.Resources},
+				`{"comp.Terrain":{"Terrain":`+strconv.Itoa(lumberjack)+`}}`,
+			),
+			migrated: true,
+			want: map[string]string{
+				"comp.Terrain": `{"Terrain":` + strconv.Itoa(lumberjack) + `}`,
+			},
+		},
+		{
+			name: "removed resource without stock",
+			save: saveJSON(&res.SaveFormat{Version: 1, Terrains: current.Terrains, Resources: []string{"food", "gold", "wood", "stones", "planks"}},
+				`{"comp.Consumption":{"Amount":[1,0,0,0,0],"Countdown":[3,9,0,0,0]}}`,
+			),
+			migrated: true,
+			want: map[string]string{
+				"comp.Consumption": `{"Amount":[1,0,0,0],"Countdown":[3,0,0,0]}`,
+			},
+		},
+		{
+			name: "removed terrain in use",
+			save: saveJSON(&res.SaveFormat{Version: 1, Terrains: append(slices.Clone(current.Terrains), "ruins"), Resources: current.Resources},
+				`{"comp.Terrain":{"Terrain":`+strconv.Itoa(len(current.Terrains))+`}}`,
+			),
+			wantErr: "terrain 'ruins' does not exist anymore",
+		},
+		{
+			name: "removed resource with stock",
+			save: saveJSON(&res.SaveFormat{Version: 1, Terrains: current.Terrains, Resources: []string{"food", "wood", "stones", "planks", "gold"}},
+				`{"comp.Warehouse":{"Stock":[0,0,0,0,5]}}`,
+			),
+			wantErr: "resource 'gold' does not exist anymore, but the save game has 5 of it",
+		},
+		{
+			name:    "unknown legacy terrain",
+			save:    saveJSON(nil, `{"comp.Terrain":{"Terrain":99}}`),
+			wantErr: "unknown terrain ID 99",
+		},
+		{
+			name:    "newer version",
+			save:    saveJSON(&res.SaveFormat{Version: SaveVersion + 1, Terrains: current.Terrains, Resources: current.Resources}),
+			wantErr: "please update the game",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jsData, migrated, err := migrateSave([]byte(tt.save))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("migrateSave() error = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("migrateSave() error = %v", err)
+			}
+			if migrated != tt.migrated {
+				t.Errorf("migrateSave() migrated = %v, want %v", migrated, tt.migrated)
+			}
+			if !migrated {
+				if string(jsData) != tt.save {
+					t.Errorf("migrateSave() changed a save game that needs no migration")
+				}
+				return
+			}
+
+			s, err := newSaveData(jsData)
+			if err != nil {
+				t.Fatalf("can't decode migrated save game: %v", err)
+			}
+			for name, want := range tt.want {
+				if got := savedValue(s, name); got != want {
+					t.Errorf("%s = %s, want %s", name, got, want)
+				}
+			}
+
+			// Migrated save games are current, and not migrated again.
+			if _, again, err := migrateSave(jsData); err != nil || again {
+				t.Errorf("migrateSave() of the migrated save game: migrated = %v, error = %v", again, err)
+			}
+		})
+	}
+}
+
+// saveJSON creates a save game with the given format, resources and component entities.
+// Without format, the save game has no version, like those written before versioning.
+func saveJSON(format *res.SaveFormat, entries ...string) string {
+	resources := []string{}
+	components := []string{}
+	if format != nil {
+		js, err := json.Marshal(format)
+		if err != nil {
+			panic(err)
+		}
+		resources = append(resources, `"`+formatKey+`":`+string(js))
+	}
+	for _, e := range entries {
+		if strings.HasPrefix(e, "{") {
+			components = append(components, e)
+		} else {
+			resources = append(resources, e)
+		}
+	}
+	return `{"Resources":{` + strings.Join(resources, ",") + `},"Components":[` + strings.Join(components, ",") + `]}`
+}
+
+// savedValue returns the JSON of a resource, or of the first component with the given name.
+func savedValue(s *saveData, name string) string {
+	if data, ok := s.resources[name]; ok {
+		return string(data)
+	}
+	for _, entity := range s.components {
+		if data, ok := entity[name]; ok {
+			return string(data)
+		}
+	}
+	return ""
+}
+
+func decode(t *testing.T, data []byte, value any) {
+	t.Helper()
+	if err := json.Unmarshal(data, value); err != nil {
+		t.Fatalf("can't decode %s: %v", data, err)
+	}
+}
+
+func terrainNames(terrains ...terr.Terrain) []string {
+	names := make([]string, len(terrains))
+	for i, t := range terrains {
+		names[i] = terr.Properties[t].Name
+	}
+	return names
+}
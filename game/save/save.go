@@ -13,7 +13,15 @@ import (
 	"github.com/mlange-42/tiny-world/game/terr"
 )
 
-func SaveWorld(folder, name string, world *ecs.World, skip []ecs.Comp) error {
+// SaveWorld saves the world as a save game, in the given encoding.
+// Replaces the save game of the same name in any encoding.
+// Updates the summary for the list of save games, see [res.SaveInfo].
+func SaveWorld(folder, name string, world *ecs.World, skip []ecs.Comp, enc Encoding) error {
+	*ecs.GetResource[res.SaveFormat](world) = currentFormat()
+	if err := updateInfo(world); err != nil {
+		return err
+	}
+
 	js, err := as.Serialize(world,
 		as.Opts.SkipResources(
 			skip...,
@@ -23,7 +31,50 @@ func SaveWorld(folder, name string, world *ecs.World, skip []ecs.Comp) error {
 		return err
 	}
 
-	return saveToFile(folder, name, js)
+	data, err := encodeSave(js, enc)
+	if err != nil {
+		return err
+	}
+	return saveToFile(folder, name, data, enc)
+}
+
+// ConvertGame converts a save game to the given encoding, and migrates it to the current version.
+// Returns the previous encoding of the save game.
+//
+// If the save game was migrated, its replay is deleted.
+// Recorded commands refer to the game data before the migration, see [res.SaveFormat.Migrated].
+func ConvertGame(folder, name string, enc Encoding) (Encoding, error) {
+	data, err := loadWorldData(folder, name)
+	if err != nil {
+		return EncodingJSON, err
+	}
+	jsData, oldEnc, err := decodeSave(data)
+	if err != nil {
+		return oldEnc, fmt.Errorf("converting save game '%s': %w", name, err)
+	}
+	jsData, migrated, err := migrateSave(jsData)
+	if err != nil {
+		return oldEnc, fmt.Errorf("converting save game '%s': %w", name, err)
+	}
+	if data, err = encodeSave(jsData, enc); err != nil {
+		return oldEnc, err
+	}
+	if err := saveToFile(folder, name, data, enc); err != nil {
+		return oldEnc, err
+	}
+	if migrated {
+		return oldEnc, deleteReplay(folder, name)
+	}
+	return oldEnc, nil
+}
+
+// SaveReplay saves the replay of a game, next to the save game.
+func SaveReplay(folder, name string, replay *res.Replay) error {
+	js, err := json.Marshal(replay)
+	if err != nil {
+		return err
+	}
+	return saveReplayToFile(folder, name, js)
 }
 
 func SaveAchievements(file string, completed []string) error {
@@ -43,6 +94,7 @@ func DeleteGame(folder, name string) error {
 	return deleteGame(folder, name)
 }
 
+// SaveMap saves the world as a map, in the layered map format (version 2).
 func SaveMap(folder, name string, world *ecs.World) error {
 	rules := ecs.GetResource[res.Rules](world)
 	bounds := ecs.GetResource[res.WorldBounds](world)
@@ -50,54 +102,66 @@ func SaveMap(folder, name string, world *ecs.World) error {
 	landUse := ecs.GetResource[res.LandUse](world)
 
 	terrains := map[string]int{}
-
 	for _, t := range rules.RandomTerrains {
-		var tp terr.TerrainPair
-		if terr.Properties[t].TerrainBits.Contains(terr.IsTerrain) {
-			tp.Terrain = t
-		} else {
-			tp.LandUse = t
-		}
-		sym, ok := terr.TerrainToSymbol[tp]
-		if !ok {
-			return fmt.Errorf("symbol not found for %s/%s", terr.Properties[tp.Terrain].Name, terr.Properties[tp.LandUse].Name)
-		}
-		symStr := string(sym)
-		if cnt, ok := terrains[symStr]; ok {
-			terrains[symStr] = cnt + 1
-		} else {
-			terrains[symStr] = 1
-		}
+		terrains[terr.Properties[t].Name]++
 	}
 
 	center := image.Pt(terrain.Width()/2-bounds.Min.X, terrain.Height()/2-bounds.Min.Y)
 
-	rows := make([]string, bounds.Dy()+1)
-	b := strings.Builder{}
+	legend := map[string]string{}
+	addSymbol := func(t terr.Terrain) (rune, error) {
+		if t == terr.Air {
+			return '.', nil
+		}
+		props := &terr.Properties[t]
+		if len(props.Symbols) == 0 {
+			return 0, fmt.Errorf("symbol not found for %s", props.Name)
+		}
+		sym := props.Symbols[0]
+		legend[string(sym)] = props.Name
+		return sym, nil
+	}
+
+	terrRows := make([]string, bounds.Dy()+1)
+	luRows := make([]string, bounds.Dy()+1)
+	tb := strings.Builder{}
+	lb := strings.Builder{}
 	for y := bounds.Min.Y; y <= bounds.Max.Y; y++ {
 		for x := bounds.Min.X; x <= bounds.Max.X; x++ {
 			ter := terrain.Get(x, y)
+			lu := landUse.Get(x, y)
 			if !terr.Properties[ter].TerrainBits.Contains(terr.CanBuild) {
-				ter = terr.Air
+				ter, lu = terr.Air, terr.Air
+			}
+			sym, err := addSymbol(ter)
+			if err != nil {
+				return err
 			}
-			t := terr.TerrainPair{Terrain: ter, LandUse: landUse.Get(x, y)}
-			sym, ok := terr.TerrainToSymbol[t]
-			if !ok {
-				return fmt.Errorf("symbol not found for %s/%s", terr.Properties[t.Terrain].Name, terr.Properties[t.LandUse].Name)
+			tb.WriteRune(sym)
+			sym, err = addSymbol(lu)
+			if err != nil {
+				return err
 			}
-			b.WriteRune(sym)
+			lb.WriteRune(sym)
 		}
-		rows[y-bounds.Min.Y] = b.String()
-		b.Reset()
+		terrRows[y-bounds.Min.Y] = tb.String()
+		luRows[y-bounds.Min.Y] = lb.String()
+		tb.Reset()
+		lb.Reset()
 	}
 
-	mapJs := mapJs{
+	mapJs := mapV2Js{
+		Version:               MapVersion,
+		Title:                 name,
+		Author:                "",
+		Description:           []string{},
+		Achievements:          []string{},
 		Terrains:              terrains,
-		Center:                center,
 		InitialRandomTerrains: rules.InitialRandomTerrains,
-		Achievements:          []string{},
-		Description:           []string{},
-		Map:                   rows,
+		Center:                center,
+		Legend:                legend,
+		Terrain:               terrRows,
+		LandUse:               luRows,
 	}
 	jsData, err := json.MarshalIndent(mapJs, "", "  ")
 	if err != nil {
@@ -4,13 +4,19 @@ package save
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
 	"path"
 	"path/filepath"
 )
 
-func saveToFile(folder, name string, jsData []byte) error {
-	file := path.Join(folder, name) + ".json"
+// saveExtensions are the file extensions of save games, by encoding.
+var saveExtensions = []string{".json", ".json.gz"}
+
+func saveToFile(folder, name string, data []byte, enc Encoding) error {
+	file := path.Join(folder, name) + saveExtensions[enc]
 	dir := filepath.Dir(file)
 	err := os.MkdirAll(dir, os.ModePerm)
 	if err != nil {
@@ -23,11 +29,33 @@ func saveToFile(folder, name string, jsData []byte) error {
 	}
 	defer f.Close()
 
-	f.Write(jsData)
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
 
+	// Remove the save game in other encodings, so it is not listed twice.
+	for e, ext := range saveExtensions {
+		if Encoding(e) == enc {
+			continue
+		}
+		if err := os.Remove(path.Join(folder, name) + ext); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
 	return nil
 }
 
+func saveReplayToFile(folder, name string, jsData []byte) error {
+	file := path.Join(folder, name) + ".replay"
+	dir := filepath.Dir(file)
+	err := os.MkdirAll(dir, os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(file, jsData, 0666)
+}
+
 func saveAchievements(file string, completed []string) error {
 	jsData, err := json.MarshalIndent(completed, "", " ")
 	if err != nil {
@@ -52,8 +80,27 @@ func saveAchievements(file string, completed []string) error {
 }
 
 func deleteGame(folder, name string) error {
-	file := path.Join(folder, name) + ".json"
-	return os.Remove(file)
+	found := false
+	for _, ext := range saveExtensions {
+		err := os.Remove(path.Join(folder, name) + ext)
+		if err == nil {
+			found = true
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	if !found {
+		return fmt.Errorf("save game %s: %w", name, fs.ErrNotExist)
+	}
+	return deleteReplay(folder, name)
+}
+
+func deleteReplay(folder, name string) error {
+	replay := path.Join(folder, name) + ".replay"
+	if err := os.Remove(replay); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
 }
 
 func saveMapToFile(folder, name string, mapData string) error {
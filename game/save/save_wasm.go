@@ -3,6 +3,7 @@
 package save
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"syscall/js"
 )
@@ -11,15 +12,30 @@ import (
 const (
 	saveGamePrefix  = "mlange-42/tiny-world/save/"
 	saveMapPrefix   = "mlange-42/tiny-world/maps/"
+	replayPrefix    = "mlange-42/tiny-world/replay/"
 	achievementsKey = "mlange-42/tiny-world/achievements"
 )
 
-func saveToFile(folder, name string, jsData []byte) error {
+func saveToFile(folder, name string, data []byte, enc Encoding) error {
+	_ = folder
+
+	// localStorage only holds strings, so compressed save games are stored base64-encoded.
+	str := string(data)
+	if enc != EncodingJSON {
+		str = base64.StdEncoding.EncodeToString(data)
+	}
+	storage := js.Global().Get("localStorage")
+	storage.Call("setItem", saveGamePrefix+name, js.ValueOf(str))
+
+	return nil
+}
+
+func saveReplayToFile(folder, name string, jsData []byte) error {
 	_ = folder
 
 	data := js.ValueOf(string(jsData))
 	storage := js.Global().Get("localStorage")
-	storage.Call("setItem", saveGamePrefix+name, data)
+	storage.Call("setItem", replayPrefix+name, data)
 
 	return nil
 }
@@ -44,6 +60,14 @@ func deleteGame(folder, name string) error {
 
 	storage := js.Global().Get("localStorage")
 	storage.Delete(saveGamePrefix + name)
+	return deleteReplay(folder, name)
+}
+
+func deleteReplay(folder, name string) error {
+	_ = folder
+
+	storage := js.Global().Get("localStorage")
+	storage.Delete(replayPrefix + name)
 	return nil
 }
 
@@ -0,0 +1,47 @@
+package save
+
+import (
+	"fmt"
+	"io/fs"
+
+	"github.com/mlange-42/tiny-world/cmd/util"
+)
+
+// Settings for saving games, read from JSON.
+type Settings struct {
+	// Encoding of save games.
+	Encoding Encoding
+	// Interval between autosaves, in seconds of game time. Disabled if 0.
+	AutosaveInterval int
+	// Number of rotating autosave slots.
+	AutosaveSlots int
+}
+
+// LoadSettings reads save settings from the given file.
+func LoadSettings(f fs.FS, file string) (Settings, error) {
+	helper := settingsJs{}
+	if err := util.FromJsonFs(f, file, &helper); err != nil {
+		return Settings{}, err
+	}
+	enc, err := ParseEncoding(helper.Encoding)
+	if err != nil {
+		return Settings{}, err
+	}
+	if helper.AutosaveInterval < 0 {
+		return Settings{}, fmt.Errorf("autosave interval must not be negative, got %d", helper.AutosaveInterval)
+	}
+	if helper.AutosaveInterval > 0 && helper.AutosaveSlots < 1 {
+		return Settings{}, fmt.Errorf("autosave requires at least one slot, got %d", helper.AutosaveSlots)
+	}
+	return Settings{
+		Encoding:         enc,
+		AutosaveInterval: helper.AutosaveInterval,
+		AutosaveSlots:    helper.AutosaveSlots,
+	}, nil
+}
+
+type settingsJs struct {
+	Encoding         string `json:"encoding"`
+	AutosaveInterval int    `json:"autosave_interval"`
+	AutosaveSlots    int    `json:"autosave_slots"`
+}
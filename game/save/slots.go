@@ -0,0 +1,74 @@
+package save
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Slots of a world are saved as "<world>.<slot>".
+// Valid world names don't contain dots, see [IsValidName].
+const (
+	slotSeparator = "."
+	autosaveSlot  = "auto"
+	emergencySlot = "emergency"
+)
+
+// AutosaveName returns the name of an autosave slot of a world, like "world.auto1".
+func AutosaveName(world string, slot int) string {
+	return fmt.Sprintf("%s%s%s%d", world, slotSeparator, autosaveSlot, slot)
+}
+
+// EmergencyName returns the name of the emergency save of a world, written when the game crashes.
+func EmergencyName(world string) string {
+	return world + slotSeparator + emergencySlot
+}
+
+// WorldName returns the name of the world of a save game, without the slot.
+func WorldName(name string) string {
+	world, _ := splitName(name)
+	return world
+}
+
+// SlotLabel returns a human-readable label for a save game slot.
+func SlotLabel(slot string) string {
+	if slot == emergencySlot {
+		return "emergency save"
+	}
+	if num, ok := strings.CutPrefix(slot, autosaveSlot); ok {
+		return "autosave " + num
+	}
+	return slot
+}
+
+func splitName(name string) (string, string) {
+	world, slot, _ := strings.Cut(name, slotSeparator)
+	return world, slot
+}
+
+// groupSlots moves save games in slots to the main save game of their world.
+// If a world has no main save game, its most recent slot stands in for it.
+// Expects the games sorted by time, most recent first.
+func groupSlots(games []SaveGame) []SaveGame {
+	result := []SaveGame{}
+	index := map[string]int{}
+	for i := range games {
+		games[i].Slots = nil
+		if _, games[i].Slot = splitName(games[i].Name); games[i].Slot == "" {
+			index[games[i].Name] = len(result)
+			result = append(result, games[i])
+		}
+	}
+	for _, g := range games {
+		if g.Slot == "" {
+			continue
+		}
+		world := WorldName(g.Name)
+		if idx, ok := index[world]; ok {
+			result[idx].Slots = append(result[idx].Slots, g)
+			continue
+		}
+		index[world] = len(result)
+		result = append(result, g)
+	}
+	return result
+}
@@ -1,8 +1,11 @@
 package save
 
 import (
+	"fmt"
 	"image"
 	"time"
+
+	"github.com/mlange-42/tiny-world/game/res"
 )
 
 type LoadType uint8
@@ -21,6 +24,12 @@ type MapLocation struct {
 type SaveGame struct {
 	Name string
 	Time time.Time
+	// Slot of the save game, like "auto1". Empty for the main save game of a world.
+	Slot string
+	// Autosave and emergency slots of the world, most recent first.
+	Slots []SaveGame
+	// Summary of the game. Empty for games saved by older versions.
+	Info res.SaveInfo
 }
 
 type saveGameInfo struct {
@@ -28,7 +37,8 @@ type saveGameInfo struct {
 }
 
 type saveGameResources struct {
-	SaveTime saveTime `json:"res.SaveTime"`
+	SaveTime saveTime     `json:"res.SaveTime"`
+	SaveInfo res.SaveInfo `json:"res.SaveInfo"`
 }
 
 type saveTime struct {
@@ -37,14 +47,49 @@ type saveTime struct {
 
 type MapInfo struct {
 	Achievements []string
+	Title        string
+	Author       string
+	Difficulty   string
 	Description  string
 }
 
 type mapInfoJs struct {
 	Achievements []string `json:"achievements"`
+	Title        string   `json:"title"`
+	Author       string   `json:"author"`
+	Difficulty   string   `json:"difficulty"`
 	Description  []string `json:"description"`
 }
 
+// MapVersion is the version of the map format written by [SaveMap].
+const MapVersion = 2
+
+// Difficulties of maps, in increasing order.
+var Difficulties = []string{"easy", "normal", "hard"}
+
+// MapError is an error in a map file, with its position in the file.
+type MapError struct {
+	// Line in the file, starting at 1.
+	Line int
+	// Column in the line, in characters, starting at 1.
+	Column int
+	Err    error
+}
+
+func (e *MapError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Err.Error())
+}
+
+func (e *MapError) Unwrap() error {
+	return e.Err
+}
+
+type mapVersionJs struct {
+	Version int `json:"version"`
+}
+
+// mapJs is the original map format, version 1.
+// Cells are single runes, each standing for a pair of terrain and land use.
 type mapJs struct {
 	Terrains              map[string]int `json:"terrains"`
 	Map                   []string       `json:"map"`
@@ -53,3 +98,21 @@ type mapJs struct {
 	Center                image.Point    `json:"center"`
 	InitialRandomTerrains int            `json:"initial_terrains"`
 }
+
+// mapV2Js is the map format version 2, with separate layers for terrain and land use.
+// Cells are single runes, with their terrain names given by the legend.
+type mapV2Js struct {
+	Version               int                `json:"version"`
+	Title                 string             `json:"title"`
+	Author                string             `json:"author"`
+	Difficulty            string             `json:"difficulty,omitempty"`
+	Description           []string           `json:"description"`
+	Achievements          []string           `json:"achievements"`
+	Rules                 *res.RulesOverride `json:"rules,omitempty"`
+	Terrains              map[string]int     `json:"terrains"`
+	InitialRandomTerrains int                `json:"initial_terrains"`
+	Center                image.Point        `json:"center"`
+	Legend                map[string]string  `json:"legend"`
+	Terrain               []string           `json:"terrain"`
+	LandUse               []string           `json:"land_use"`
+}
@@ -0,0 +1,88 @@
+package sim
+
+import (
+	"image"
+	"testing"
+
+	"github.com/mlange-42/tiny-world/game/res"
+	"github.com/mlange-42/tiny-world/game/terr"
+)
+
+func TestFootprint(t *testing.T) {
+	place := func(update int, tp res.CommandType, name string, dx, dy int) scriptCommand {
+		return scriptCommand{update, res.Command{Type: tp, Terrain: terr.ToTerrain(name), Tile: image.Pt(dx, dy)}}
+	}
+	bulldoze := func(update, dx, dy int) scriptCommand {
+		return scriptCommand{update, res.Command{Type: res.Bulldoze, Tile: image.Pt(dx, dy)}}
+	}
+	// Plains for buildings of up to 2x2 tiles, with their origin at 1,0 from the center.
+	plains := []scriptCommand{
+		place(10, res.PlaceTerrain, "plains", 1, 0),
+		place(10, res.PlaceTerrain, "plains", 2, 0),
+		place(10, res.PlaceTerrain, "plains", 1, 1),
+		place(10, res.PlaceTerrain, "plains", 2, 1),
+	}
+
+	tests := []struct {
+		name   string
+		script []scriptCommand
+		// Expected land use of the cells covered by the building.
+		want string
+	}{
+		{
+			name:   "castle",
+			script: []scriptCommand{place(20, res.PlaceBuilding, "castle", 1, 0)},
+			want:   "castle",
+		},
+		{
+			name:   "monastery",
+			script: []scriptCommand{place(20, res.PlaceBuilding, "monastery", 1, 0)},
+			want:   "monastery",
+		},
+		{
+			name:   "bulldoze castle at origin",
+			script: []scriptCommand{place(20, res.PlaceBuilding, "castle", 1, 0), bulldoze(30, 1, 0)},
+		},
+		{
+			name:   "bulldoze castle at other cell",
+			script: []scriptCommand{place(20, res.PlaceBuilding, "castle", 1, 0), bulldoze(30, 2, 1)},
+		},
+		{
+			name:   "bulldoze monastery at other cell",
+			script: []scriptCommand{place(20, res.PlaceBuilding, "monastery", 1, 0), bulldoze(30, 2, 0)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			script := append(append([]scriptCommand{}, plains...), tt.script...)
+			s := runSession(&setup{Seed: 5, IsEditor: true, Script: script, Updates: 60}, nil)
+			if s.Accepted != len(script) {
+				t.Fatalf("%d of %d commands were accepted", s.Accepted, len(script))
+			}
+
+			landUse := &s.LandUse
+			origin := image.Pt(landUse.Width()/2+1, landUse.Height()/2)
+			rect := image.Rectangle{}
+			if tt.want != "" {
+				rect.Min = origin
+				rect.Max = origin.Add(terr.Properties[terr.ToTerrain(tt.want)].Footprint)
+				if got := landUse.Footprint(rect.Max.X-1, rect.Max.Y-1); got != rect {
+					t.Errorf("Footprint() = %v, want %v", got, rect)
+				}
+			}
+			// All cells of the footprint are covered, or free after bulldozing any of them.
+			for x := origin.X; x < origin.X+2; x++ {
+				for y := origin.Y; y < origin.Y+2; y++ {
+					want := terr.Air
+					if image.Pt(x, y).In(rect) {
+						want = terr.ToTerrain(tt.want)
+					}
+					if got := landUse.Get(x, y); got != want {
+						t.Errorf("land use at %d,%d is %s, want %s", x, y, terr.Properties[got].Name, terr.Properties[want].Name)
+					}
+				}
+			}
+		})
+	}
+}
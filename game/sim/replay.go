@@ -0,0 +1,70 @@
+package sim
+
+import (
+	"errors"
+	"io/fs"
+
+	"github.com/mlange-42/ark/ecs"
+	"github.com/mlange-42/tiny-world/game/res"
+	"github.com/mlange-42/tiny-world/game/save"
+)
+
+// RecordReplay starts recording a replay of a new game.
+func RecordReplay(world *ecs.World, seed uint64, load save.LoadType, mapFolder string, mapLoc save.MapLocation, isEditor bool) {
+	replay := ecs.GetResource[res.Replay](world)
+	*replay = res.Replay{
+		Mode:     res.ReplayRecord,
+		Seed:     seed,
+		IsEditor: isEditor,
+	}
+	if load == save.LoadTypeMap {
+		replay.Map = mapLoc.Name
+		replay.MapFolder = mapFolder
+		replay.MapEmbedded = mapLoc.IsEmbedded
+	} else if gen := ecs.GetResource[res.WorldGen](world); gen.IsEnabled() {
+		g := *gen
+		replay.Generator = &g
+	}
+}
+
+// ContinueReplay continues recording the replay of a loaded game.
+// Must be called after the game was loaded.
+// Recording stays disabled if there is no replay for the game,
+// or if the game was migrated on load.
+func ContinueReplay(world *ecs.World, folder, name string) error {
+	if ecs.GetResource[res.SaveFormat](world).Migrated {
+		// Recorded commands refer to the game data before the migration.
+		return nil
+	}
+	loaded, err := save.LoadReplay(folder, name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	replay := ecs.GetResource[res.Replay](world)
+	*replay = loaded
+	replay.Mode = res.ReplayRecord
+
+	// Game speed is not stored in save games, so the reset is recorded.
+	replay.RecordSpeed(ecs.GetResource[res.GameTick](world), ecs.GetResource[res.GameSpeed](world))
+	return nil
+}
+
+// PlayReplay sets up the world for playing back a replay.
+// Call before the init system is added, and use the returned location for it.
+func PlayReplay(world *ecs.World, replay *res.Replay) (save.LoadType, string, save.MapLocation) {
+	r := ecs.GetResource[res.Replay](world)
+	*r = *replay
+	r.Mode = res.ReplayPlay
+	r.Next = 0
+	if replay.Generator != nil {
+		*ecs.GetResource[res.WorldGen](world) = *replay.Generator
+	}
+
+	if replay.Map == "" {
+		return save.LoadTypeNone, "", save.MapLocation{}
+	}
+	return save.LoadTypeMap, replay.MapFolder, save.MapLocation{Name: replay.Map, IsEmbedded: replay.MapEmbedded}
+}
@@ -0,0 +1,236 @@
+package sim
+
+import (
+	"encoding/json"
+	"image"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/mlange-42/ark-tools/app"
+	"github.com/mlange-42/ark/ecs"
+	"github.com/mlange-42/tiny-world/game/res"
+	"github.com/mlange-42/tiny-world/game/resource"
+	"github.com/mlange-42/tiny-world/game/save"
+	"github.com/mlange-42/tiny-world/game/sys"
+	"github.com/mlange-42/tiny-world/game/terr"
+)
+
+const tps = 60
+
+func TestMain(m *testing.M) {
+	data := os.DirFS("../..")
+	resource.Prepare(data, "data/json/resources.json")
+	terr.Prepare(data, "data/json/terrain.json")
+	os.Exit(m.Run())
+}
+
+// scriptCommand is a command pushed before the given update of a recorded session.
+// The command's tile is relative to the center of the world.
+type scriptCommand struct {
+	Update  int
+	Command res.Command
+}
+
+// setup configures a recorded session.
+type setup struct {
+	Seed     uint64
+	IsEditor bool
+	// Embedded map to start from. Empty for a new world.
+	Map     string
+	Script  []scriptCommand
+	Updates int
+}
+
+// session is the outcome of a headless game session.
+type session struct {
+	Replay   res.Replay
+	Accepted int
+	Tick     int64
+	Stock    res.Stock
+	Hash     uint64
+	// Terrain and land use of all tiles.
+	Tiles   []terr.Terrain
+	LandUse res.LandUse
+}
+
+// runSession runs a headless game with the given setup, and records a replay.
+// If a replay is given, it is played back instead, and the setup is ignored.
+func runSession(st *setup, replay *res.Replay) session {
+	data := os.DirFS("../..")
+	a := app.New()
+	world := &a.World
+
+	seed, isEditor := st.Seed, st.IsEditor
+	if replay != nil {
+		seed, isEditor = replay.Seed, replay.IsEditor
+	}
+	AddResources(world, data, "data/json/rules.json", tps, seed, isEditor)
+	ecs.AddResource(world, &res.Feedback{HUD: &res.HeadlessHUD{}})
+
+	load, mapFolder, mapLoc := save.LoadTypeNone, "", save.MapLocation{}
+	if replay != nil {
+		load, mapFolder, mapLoc = PlayReplay(world, replay)
+	} else {
+		if st.Map != "" {
+			load, mapFolder, mapLoc = save.LoadTypeMap, "maps", save.MapLocation{Name: st.Map, IsEmbedded: true}
+		}
+		RecordReplay(world, seed, load, mapFolder, mapLoc, isEditor)
+	}
+	AddInitSystem(a, load, data, mapFolder, mapLoc)
+	AddSystems(a, tps)
+	a.Initialize()
+
+	result := session{}
+	gameTick := ecs.GetResource[res.GameTick](world)
+	stock := ecs.GetResource[res.Stock](world)
+	if replay != nil {
+		for gameTick.RenderTick < replay.Final.RenderTick {
+			a.Update()
+		}
+	} else {
+		terrain := ecs.GetResource[res.Terrain](world)
+		commands := ecs.GetResource[res.Commands](world)
+		center := image.Pt(terrain.Width()/2, terrain.Height()/2)
+		for i := 0; i < st.Updates; i++ {
+			for _, c := range st.Script {
+				if c.Update == i {
+					cmd := c.Command
+					cmd.Tile = cmd.Tile.Add(center)
+					commands.Push(cmd)
+				}
+			}
+			a.Update()
+			for _, r := range commands.Results {
+				if r.Accepted() {
+					result.Accepted++
+				}
+			}
+		}
+		rec := ecs.GetResource[res.Replay](world)
+		rec.Finish(gameTick, stock)
+		result.Replay = *rec
+	}
+
+	terrain := ecs.GetResource[res.Terrain](world)
+	landUse := ecs.GetResource[res.LandUse](world)
+	for x := 0; x < terrain.Width(); x++ {
+		for y := 0; y < terrain.Height(); y++ {
+			result.Tiles = append(result.Tiles, terrain.Get(x, y), landUse.Get(x, y))
+		}
+	}
+	result.LandUse = *landUse
+	result.Tick = gameTick.Tick
+	result.Hash = sys.StateHash(world)
+	result.Stock = *stock
+	a.Finalize()
+	return result
+}
+
+func TestReplay(t *testing.T) {
+	card := func(update, card, dx, dy int) scriptCommand {
+		return scriptCommand{update, res.Command{Type: res.PlaceRandomCard, Card: card, Tile: image.Pt(dx, dy), Randomize: true}}
+	}
+	place := func(update int, tp res.CommandType, name string, dx, dy int) scriptCommand {
+		return scriptCommand{update, res.Command{Type: tp, Terrain: terr.ToTerrain(name), Tile: image.Pt(dx, dy), Randomize: true}}
+	}
+
+	tests := []struct {
+		name  string
+		setup setup
+	}{
+		{
+			name:  "no commands",
+			setup: setup{Seed: 1, Updates: 300},
+		},
+		{
+			// Cards are drawn randomly, so playback depends on the restored seed.
+			name: "cards",
+			setup: setup{
+				Seed: 2,
+				Script: []scriptCommand{
+					card(10, 0, 1, 0), card(20, 1, 2, 0), card(30, 2, 0, 1),
+					card(40, 0, 1, 1), card(50, 3, -1, 0), card(60, 1, 0, -1),
+				},
+				Updates: 600,
+			},
+		},
+		{
+			name: "editor",
+			setup: setup{
+				Seed:     3,
+				IsEditor: true,
+				Script: []scriptCommand{
+					place(10, res.PlaceTerrain, "plains", 1, 0),
+					place(10, res.PlaceTerrain, "plains", 2, 0),
+					place(10, res.PlaceTerrain, "plains", 3, 0),
+					place(20, res.PlaceBuilding, "path", 1, 0),
+					place(30, res.PlaceBuilding, "path", 2, 0),
+					place(40, res.PlaceBuilding, "farm", 3, 0),
+					{50, res.Command{Type: res.Bulldoze, Tile: image.Pt(2, 0)}},
+				},
+				Updates: 300,
+			},
+		},
+		{
+			// Production runs, so the timing of commands matters.
+			name: "map",
+			setup: setup{
+				Seed: 4,
+				Map:  "Great Plains",
+				Script: []scriptCommand{
+					place(10, res.PlaceBuilding, "path", 1, 0),
+					place(10, res.PlaceBuilding, "path", 2, 0),
+					place(20, res.PlaceBuilding, "farm", 3, 0),
+					place(30, res.PlaceBuilding, "path", -1, 0),
+					place(30, res.PlaceBuilding, "path", -2, 0),
+					place(40, res.PlaceBuilding, "lumberjack", -3, 0),
+					{50, res.Command{Type: res.Bulldoze, Tile: image.Pt(-1, 0)}},
+					place(60, res.PlaceBuilding, "path", -1, 0),
+				},
+				Updates: 1200,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorded := runSession(&tt.setup, nil)
+			if recorded.Accepted != len(tt.setup.Script) {
+				t.Fatalf("%d of %d commands were accepted", recorded.Accepted, len(tt.setup.Script))
+			}
+
+			// Replays are stored as JSON.
+			js, err := json.Marshal(&recorded.Replay)
+			if err != nil {
+				t.Fatal(err)
+			}
+			replay := res.Replay{}
+			if err := json.Unmarshal(js, &replay); err != nil {
+				t.Fatal(err)
+			}
+
+			played := runSession(&setup{}, &replay)
+			if played.Tick != recorded.Tick {
+				t.Errorf("replay ended at tick %d, want %d", played.Tick, recorded.Tick)
+			}
+			if !slices.Equal(played.Tiles, recorded.Tiles) {
+				t.Errorf("replay has different terrain than the recording")
+			}
+			if played.Hash != recorded.Hash {
+				t.Errorf("replay has state hash %016x, want %016x", played.Hash, recorded.Hash)
+			}
+			if diff := replay.Verify(&played.Stock); len(diff) > 0 {
+				t.Errorf("replay differs from the recording: %v", diff)
+			}
+
+			// A replay that misses a command must diverge.
+			if len(tt.setup.Script) > 0 {
+				replay.Events = replay.Events[1:]
+				if diverged := runSession(&setup{}, &replay); slices.Equal(diverged.Tiles, recorded.Tiles) {
+					t.Errorf("replay without the first command has the recorded terrain")
+				}
+			}
+		})
+	}
+}
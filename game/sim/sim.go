@@ -0,0 +1,151 @@
+// Package sim sets up the game simulation, independent of rendering and user input.
+package sim
+
+import (
+	"io/fs"
+
+	"github.com/mlange-42/ark-tools/app"
+	"github.com/mlange-42/ark/ecs"
+	"github.com/mlange-42/tiny-world/game/nav"
+	"github.com/mlange-42/tiny-world/game/res"
+	"github.com/mlange-42/tiny-world/game/save"
+	"github.com/mlange-42/tiny-world/game/sys"
+)
+
+// AddResources creates all resources required by the simulation systems, and adds them to the world.
+// The seed is used for all gameplay randomness. It is replaced by the stored state when loading a game.
+func AddResources(world *ecs.World, f fs.FS, rulesFile string, tps int64, seed uint64, isEditor bool) {
+	rules := res.NewRules(f, rulesFile)
+	ecs.AddResource(world, &rules)
+
+	rng := res.NewRand(seed)
+	ecs.AddResource(world, &rng)
+
+	gameSpeed := res.GameSpeed{
+		MinSpeed: -2,
+		MaxSpeed: 3,
+	}
+	ecs.AddResource(world, &gameSpeed)
+
+	gameTick := res.GameTick{}
+	ecs.AddResource(world, &gameTick)
+
+	terrain := res.NewTerrain(rules.WorldSize, rules.WorldSize)
+	ecs.AddResource(world, &terrain)
+
+	terrainEntities := res.TerrainEntities{Grid: res.NewGrid[ecs.Entity](rules.WorldSize, rules.WorldSize)}
+	ecs.AddResource(world, &terrainEntities)
+
+	landUse := res.NewLandUse(rules.WorldSize, rules.WorldSize)
+	ecs.AddResource(world, &landUse)
+
+	landUseEntities := res.LandUseEntities{Grid: res.NewGrid[ecs.Entity](rules.WorldSize, rules.WorldSize)}
+	ecs.AddResource(world, &landUseEntities)
+
+	pathVersion := res.PathVersion{}
+	ecs.AddResource(world, &pathVersion)
+
+	traffic := res.NewTraffic(rules.WorldSize, rules.WorldSize)
+	ecs.AddResource(world, &traffic)
+
+	// Built by the init systems, as maps and save games can change the rules.
+	network := nav.Network{}
+	ecs.AddResource(world, &network)
+
+	buildable := res.NewBuildable(rules.WorldSize, rules.WorldSize)
+	ecs.AddResource(world, &buildable)
+
+	selection := res.Selection{}
+	ecs.AddResource(world, &selection)
+
+	bounds := res.WorldBounds{}
+	ecs.AddResource(world, &bounds)
+
+	editor := res.EditorMode{IsEditor: isEditor}
+	ecs.AddResource(world, &editor)
+
+	saveTime := res.SaveTime{}
+	ecs.AddResource(world, &saveTime)
+
+	saveFormat := res.SaveFormat{}
+	ecs.AddResource(world, &saveFormat)
+
+	saveInfo := res.SaveInfo{}
+	ecs.AddResource(world, &saveInfo)
+
+	eventLog := res.EventLog{Limit: 100}
+	ecs.AddResource(world, &eventLog)
+
+	worldGen := res.WorldGen{}
+	ecs.AddResource(world, &worldGen)
+
+	randomTerrains := res.RandomTerrains{
+		TotalAvailable: rules.InitialRandomTerrains,
+	}
+	ecs.AddResource(world, &randomTerrains)
+
+	update := res.UpdateInterval{
+		Interval:  tps,
+		Countdown: 60,
+	}
+	ecs.AddResource(world, &update)
+
+	production := res.NewProduction()
+	ecs.AddResource(world, &production)
+
+	stock := res.NewStock(rules.InitialResources)
+	ecs.AddResource(world, &stock)
+
+	saveEvent := res.SaveEvent{}
+	ecs.AddResource(world, &saveEvent)
+
+	commands := res.Commands{}
+	ecs.AddResource(world, &commands)
+
+	replay := res.Replay{}
+	ecs.AddResource(world, &replay)
+
+	// Built by the init systems, like the network.
+	history := res.History{}
+	ecs.AddResource(world, &history)
+
+	factory := res.NewEntityFactory(world)
+	ecs.AddResource(world, &factory)
+}
+
+// AddInitSystem adds the system that initializes the terrain, depending on the load type.
+func AddInitSystem(a *app.App, load save.LoadType, f fs.FS, mapFolder string, mapLoc save.MapLocation) {
+	if load == save.LoadTypeGame {
+		a.AddSystem(&sys.InitTerrainLoaded{})
+	} else if load == save.LoadTypeMap {
+		a.AddSystem(&sys.InitTerrainMap{
+			FS:        f,
+			MapFolder: mapFolder,
+			Map:       mapLoc,
+		})
+	} else {
+		a.AddSystem(&sys.InitTerrain{})
+	}
+}
+
+// AddSystems adds the simulation systems, in the same order as in the game.
+// Systems for user input and rendering are not added.
+func AddSystems(a *app.App, tps int64) {
+	a.AddSystem(&sys.PlayReplay{})
+	a.AddSystem(&sys.Tick{})
+	a.AddSystem(&sys.SupplyInputs{})
+	a.AddSystem(&sys.UpdateProduction{})
+	a.AddSystem(&sys.UpdatePopulation{})
+	a.AddSystem(&sys.DoProduction{})
+	a.AddSystem(&sys.DoConsumption{})
+	a.AddSystem(&sys.GrowNature{})
+	a.AddSystem(&sys.Fire{})
+	a.AddSystem(&sys.Deliver{})
+	a.AddSystem(&sys.Haul{})
+	a.AddSystem(&sys.UpdateStats{})
+	a.AddSystem(&sys.RemoveMarkers{
+		MaxTime: tps,
+	})
+	a.AddSystem(&sys.ApplyCommands{})
+	a.AddSystem(&sys.AssignHaulers{})
+}
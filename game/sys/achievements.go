@@ -16,7 +16,7 @@ type Achievements struct {
 	time         ecs.Resource[res.GameTick]
 	update       ecs.Resource[res.UpdateInterval]
 	editor       ecs.Resource[res.EditorMode]
-	ui           ecs.Resource[res.UI]
+	hud          ecs.Resource[res.Feedback]
 	achievements ecs.Resource[achievements.Achievements]
 }
 
@@ -25,7 +25,7 @@ func (s *Achievements) Initialize(world *ecs.World) {
 	s.time = ecs.NewResource[res.GameTick](world)
 	s.update = ecs.NewResource[res.UpdateInterval](world)
 	s.editor = ecs.NewResource[res.EditorMode](world)
-	s.ui = ecs.NewResource[res.UI](world)
+	s.hud = ecs.NewResource[res.Feedback](world)
 	s.achievements = ecs.NewResource[achievements.Achievements](world)
 }
 
@@ -52,7 +52,7 @@ func (s *Achievements) Update(world *ecs.World) {
 			achievements.Completed = append(achievements.Completed, ach.ID)
 			save.SaveAchievements(s.PlayerFile, achievements.Completed)
 			println(fmt.Sprintf("Achievement completed: %s", ach.Name))
-			s.ui.Get().SetStatusLabel(fmt.Sprintf(" \nAchievement completed!\n\"%s\"\n ", ach.Name))
+			s.hud.Get().SetStatusLabel(fmt.Sprintf(" \nAchievement completed!\n\"%s\"\n ", ach.Name))
 		}
 	}
 }
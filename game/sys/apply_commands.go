@@ -0,0 +1,803 @@
+package sys
+
+import (
+	"image"
+	"slices"
+
+	"github.com/mlange-42/ark/ecs"
+	"github.com/mlange-42/tiny-world/game/comp"
+	"github.com/mlange-42/tiny-world/game/res"
+	"github.com/mlange-42/tiny-world/game/resource"
+	"github.com/mlange-42/tiny-world/game/terr"
+)
+
+// ApplyCommands system.
+type ApplyCommands struct {
+	rules        ecs.Resource[res.Rules]
+	rand         ecs.Resource[res.Rand]
+	terrain      ecs.Resource[res.Terrain]
+	landUse      ecs.Resource[res.LandUse]
+	landUseE     ecs.Resource[res.LandUseEntities]
+	buildable    ecs.Resource[res.Buildable]
+	stock        ecs.Resource[res.Stock]
+	factory      ecs.Resource[res.EntityFactory]
+	editor       ecs.Resource[res.EditorMode]
+	randTerrains ecs.Resource[res.RandomTerrains]
+	commands     ecs.Resource[res.Commands]
+	replay       ecs.Resource[res.Replay]
+	history      ecs.Resource[res.History]
+	time         ecs.Resource[res.GameTick]
+	update       ecs.Resource[res.UpdateInterval]
+	bounds       ecs.Resource[res.WorldBounds]
+	hud          ecs.Resource[res.Feedback]
+
+	warehouseMap *ecs.Map1[comp.Warehouse]
+	warehouses   warehouseFinder
+}
+
+// Initialize the system
+func (s *ApplyCommands) Initialize(world *ecs.World) {
+	s.rules = ecs.NewResource[res.Rules](world)
+	s.rand = ecs.NewResource[res.Rand](world)
+	s.terrain = ecs.NewResource[res.Terrain](world)
+	s.landUse = ecs.NewResource[res.LandUse](world)
+	s.landUseE = ecs.NewResource[res.LandUseEntities](world)
+	s.buildable = ecs.NewResource[res.Buildable](world)
+	s.stock = ecs.NewResource[res.Stock](world)
+	s.factory = ecs.NewResource[res.EntityFactory](world)
+	s.editor = ecs.NewResource[res.EditorMode](world)
+	s.randTerrains = ecs.NewResource[res.RandomTerrains](world)
+	s.commands = ecs.NewResource[res.Commands](world)
+	s.replay = ecs.NewResource[res.Replay](world)
+	s.history = ecs.NewResource[res.History](world)
+	s.time = ecs.NewResource[res.GameTick](world)
+	s.update = ecs.NewResource[res.UpdateInterval](world)
+	s.bounds = ecs.NewResource[res.WorldBounds](world)
+	s.hud = ecs.NewResource[res.Feedback](world)
+
+	s.warehouseMap = s.warehouseMap.New(world)
+	s.warehouses = newWarehouseFinder(world)
+
+	if !s.editor.Get().IsEditor {
+		s.randTerrains.Get().Fill(s.rules.Get().RandomTerrainsCount, s.rand.Get())
+	}
+}
+
+// Update the system
+func (s *ApplyCommands) Update(world *ecs.World) {
+	commands := s.commands.Get()
+	replay := s.replay.Get()
+	history := s.history.Get()
+	time := s.time.Get()
+	hud := s.hud.Get()
+
+	commands.Results = commands.Results[:0]
+	for _, cmd := range commands.Queue {
+		if !cmd.Continue {
+			history.EndStep()
+		}
+		var result res.CommandResult
+		switch cmd.Type {
+		case res.Undo:
+			result = s.undo(world, cmd)
+		case res.Redo:
+			result = s.redo(world, cmd)
+		case res.Upgrade:
+			result = s.upgrade(world, cmd)
+		default:
+			result = s.apply(world, cmd)
+		}
+		if result.Accepted() {
+			replay.RecordCommand(time, cmd)
+			if s.rules.Get().LocalStock {
+				s.warehouses.UpdateTotals(s.stock.Get())
+			}
+		} else {
+			hud.SetStatusLabel(result.Message())
+		}
+		commands.Results = append(commands.Results, result)
+	}
+	commands.Queue = commands.Queue[:0]
+}
+
+// Finalize the system
+func (s *ApplyCommands) Finalize(world *ecs.World) {}
+
+func (s *ApplyCommands) apply(world *ecs.World, cmd res.Command) res.CommandResult {
+	result := res.CommandResult{Command: cmd}
+
+	isEditor := s.editor.Get().IsEditor
+	randTerr := s.randTerrains.Get()
+
+	build, allowRemove, ok := s.terrainToPlace(&cmd, isEditor, randTerr)
+	if !ok {
+		result.Reason = res.RejectInvalid
+		return result
+	}
+
+	terrain := s.terrain.Get()
+	if !terrain.Contains(cmd.Tile.X, cmd.Tile.Y) {
+		result.Reason = res.RejectOutsideWorld
+		return result
+	}
+
+	fac := s.factory.Get()
+	stock := s.stock.Get()
+	landUse := s.landUse.Get()
+
+	tile := cmd.Tile
+	if cmd.Type == res.Bulldoze {
+		// Bulldozing any cell of a multi-tile building removes the entire building.
+		tile = landUse.Origin(tile.X, tile.Y)
+	}
+	x, y := tile.X, tile.Y
+
+	p := &terr.Properties[build]
+	if p.TerrainBits.Contains(terr.RequiresRange) && !s.inRange(x, y, build) {
+		result.Reason = res.RejectOutsideArea
+		return result
+	}
+
+	if !isEditor {
+		if result.Reason = s.canPay(tile, p.BuildCost); !result.Accepted() {
+			return result
+		}
+		if p.TerrainBits.Contains(terr.CanBuild) && !p.TerrainBits.Contains(terr.CanBuy) {
+			if randTerr.TotalPlaced >= randTerr.TotalAvailable {
+				result.Reason = res.RejectRandomTerrains
+				return result
+			}
+		}
+	}
+
+	luHere := landUse.Get(x, y)
+	result.Found = luHere
+
+	edit := res.Edit{Tile: tile, Card: -1}
+
+	if cmd.Type == res.Bulldoze {
+		luProps := &terr.Properties[luHere]
+
+		if luProps.TerrainBits.Contains(terr.IsWarehouse) && s.isLastWarehouse(stock, luHere) {
+			result.Reason = res.RejectLastWarehouse
+			return result
+		}
+		if !luProps.TerrainBits.Contains(terr.CanBuild) {
+			result.Reason = res.RejectNothingToRemove
+			return result
+		}
+
+		before := fac.LandUseState(x, y)
+		fac.RemoveLandUse(world, x, y)
+		edit.LandUse = &res.TileChange{Before: before, After: fac.LandUseState(x, y)}
+		if !isEditor {
+			edit.Stock = s.pay(tile, p.BuildCost)
+			edit.Cost = p.BuildCost
+			var changes []res.StockChange
+			edit.Refund, changes = s.salvage(tile, &before)
+			edit.Stock = append(edit.Stock, changes...)
+		}
+		s.history.Get().Record(edit, s.time.Get().Tick)
+		return result
+	}
+
+	if !isEditor {
+		if p.Population > 0 && stock.Population+int(p.Population) > stock.MaxPopulation {
+			result.Reason = res.RejectPopulation
+			return result
+		}
+	}
+
+	terrHere := terrain.Get(x, y)
+	if p.TerrainBits.Contains(terr.IsTerrain) {
+		result.Found = terrHere
+		if terrHere == terr.Air {
+			result.Reason = res.RejectNoNeighbor
+			return result
+		}
+		canBuild := luHere == terr.Air &&
+			(p.BuildOn.Contains(terrHere) || (allowRemove && terrHere != build))
+		if !canBuild {
+			result.Reason = res.RejectOccupied
+			return result
+		}
+		edit.Opened = s.airNeighbors(terrain, x, y)
+		edit.Bounds = *s.bounds.Get()
+		before := fac.TerrainState(x, y)
+		fac.Set(world, x, y, build, cmd.RandSprite, cmd.Randomize)
+		edit.Terrain = &res.TileChange{Before: before, After: fac.TerrainState(x, y)}
+	} else if p.Footprint != image.Pt(1, 1) {
+		if result.Found, result.Reason = s.checkFootprint(x, y, build); !result.Accepted() {
+			return result
+		}
+		before := fac.LandUseState(x, y)
+		fac.Set(world, x, y, build, cmd.RandSprite, cmd.Randomize)
+		edit.LandUse = &res.TileChange{Before: before, After: fac.LandUseState(x, y)}
+	} else {
+		if terrHere == terr.Air || terrHere == terr.Buildable {
+			result.Found = terrHere
+			result.Reason = res.RejectNoTerrain
+			return result
+		}
+		if !p.BuildOn.Contains(terrHere) {
+			result.Found = terrHere
+			result.Reason = res.RejectBuildOn
+			return result
+		}
+
+		luNatural := !terr.Properties[luHere].TerrainBits.Contains(terr.CanBuy)
+		if luHere != terr.Air && !(luNatural && p.TerrainBits.Contains(terr.CanBuy)) {
+			result.Reason = res.RejectOccupied
+			return result
+		}
+		before := fac.LandUseState(x, y)
+		if luHere != terr.Air {
+			fac.RemoveLandUse(world, x, y)
+		}
+		fac.Set(world, x, y, build, cmd.RandSprite, cmd.Randomize)
+		edit.LandUse = &res.TileChange{Before: before, After: fac.LandUseState(x, y)}
+	}
+
+	if !isEditor {
+		edit.Stock = s.pay(tile, p.BuildCost)
+		edit.Cost = p.BuildCost
+		if cmd.Type == res.PlaceRandomCard {
+			edit.Card = cmd.Card
+			edit.CardBefore = randTerr.Card(cmd.Card)
+			edit.TotalPlaced = randTerr.TotalPlaced
+
+			randTerr.TotalPlaced++
+			randTerr.Draw(s.rules.Get(), s.rand.Get(), cmd.Card)
+			edit.CardAfter = randTerr.Card(cmd.Card)
+		}
+	}
+	s.history.Get().Record(edit, s.time.Get().Tick)
+	return result
+}
+
+// terrainToPlace determines the terrain placed by a command, and whether it may replace existing terrain.
+// Returns false if the command is not valid in the current game mode.
+func (s *ApplyCommands) terrainToPlace(cmd *res.Command, isEditor bool, randTerr *res.RandomTerrains) (terr.Terrain, bool, bool) {
+	switch cmd.Type {
+	case res.PlaceTerrain:
+		p := &terr.Properties[cmd.Terrain]
+		ok := isEditor && p.TerrainBits.Contains(terr.CanBuild) && !p.TerrainBits.Contains(terr.CanBuy)
+		return cmd.Terrain, cmd.AllowRemove, ok
+	case res.PlaceBuilding:
+		ok := terr.Properties[cmd.Terrain].TerrainBits.Contains(terr.CanBuy)
+		return cmd.Terrain, false, ok
+	case res.Bulldoze:
+		return terr.Bulldoze, false, true
+	case res.PlaceRandomCard:
+		if isEditor || cmd.Card < 0 || cmd.Card >= len(randTerr.Terrains) {
+			return terr.Air, false, false
+		}
+		return randTerr.Terrains[cmd.Card], randTerr.AllowRemove[cmd.Card], true
+	}
+	return terr.Air, false, false
+}
+
+// checkFootprint checks whether a multi-tile building can be placed with its origin at the given cell.
+// All covered cells must be free, and on terrain the building can be built on.
+// Returns the land use or terrain found at the first rejected cell.
+func (s *ApplyCommands) checkFootprint(x, y int, build terr.Terrain) (terr.Terrain, res.Rejection) {
+	terrain := s.terrain.Get()
+	landUse := s.landUse.Get()
+	p := &terr.Properties[build]
+	for dx := 0; dx < p.Footprint.X; dx++ {
+		for dy := 0; dy < p.Footprint.Y; dy++ {
+			xx, yy := x+dx, y+dy
+			if !terrain.Contains(xx, yy) {
+				return terr.Air, res.RejectOutsideWorld
+			}
+			terrHere := terrain.Get(xx, yy)
+			if terrHere == terr.Air || terrHere == terr.Buildable {
+				return terrHere, res.RejectNoTerrain
+			}
+			if !p.BuildOn.Contains(terrHere) {
+				return terrHere, res.RejectBuildOn
+			}
+			if luHere := landUse.Get(xx, yy); luHere != terr.Air {
+				return luHere, res.RejectOccupied
+			}
+		}
+	}
+	return terr.Air, res.NotRejected
+}
+
+// inRange checks whether all cells covered by a land use with its origin at the given cell are in the buildable area.
+func (s *ApplyCommands) inRange(x, y int, t terr.Terrain) bool {
+	buildable := s.buildable.Get()
+	size := terr.Properties[t].Footprint
+	for dx := 0; dx < size.X; dx++ {
+		for dy := 0; dy < size.Y; dy++ {
+			if !buildable.Contains(x+dx, y+dy) || buildable.Get(x+dx, y+dy) == 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (s *ApplyCommands) isLastWarehouse(stock *res.Stock, building terr.Terrain) bool {
+	storage := terr.Properties[building].Storage
+	for i := range resource.Properties {
+		if stock.Cap[i] <= int(storage[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// upgrade replaces a building by its upgrade.
+func (s *ApplyCommands) upgrade(world *ecs.World, cmd res.Command) res.CommandResult {
+	result := res.CommandResult{Command: cmd}
+
+	terrain := s.terrain.Get()
+	landUse := s.landUse.Get()
+	if !terrain.Contains(cmd.Tile.X, cmd.Tile.Y) {
+		result.Reason = res.RejectOutsideWorld
+		return result
+	}
+	// Upgrading any cell of a multi-tile building upgrades the entire building.
+	tile := landUse.Origin(cmd.Tile.X, cmd.Tile.Y)
+	x, y := tile.X, tile.Y
+
+	luHere := landUse.Get(x, y)
+	result.Found = luHere
+	from := &terr.Properties[luHere]
+	if from.UpgradesTo != cmd.Terrain || cmd.Terrain == terr.Air {
+		result.Reason = res.RejectNoUpgrade
+		return result
+	}
+	p := &terr.Properties[cmd.Terrain]
+	// The upgrade may have a larger footprint, growing from the building's origin.
+	rect := image.Rectangle{Min: tile, Max: tile.Add(p.Footprint)}
+	for xx := rect.Min.X; xx < rect.Max.X; xx++ {
+		for yy := rect.Min.Y; yy < rect.Max.Y; yy++ {
+			if !terrain.Contains(xx, yy) {
+				result.Reason = res.RejectOutsideWorld
+				return result
+			}
+			if terrHere := terrain.Get(xx, yy); !p.BuildOn.Contains(terrHere) {
+				result.Found = terrHere
+				result.Reason = res.RejectBuildOn
+				return result
+			}
+			if luHere := landUse.Get(xx, yy); luHere != terr.Air && landUse.Origin(xx, yy) != tile {
+				result.Found = luHere
+				result.Reason = res.RejectOccupied
+				return result
+			}
+		}
+	}
+	if p.TerrainBits.Contains(terr.RequiresRange) && !s.inRange(x, y, cmd.Terrain) {
+		result.Reason = res.RejectOutsideArea
+		return result
+	}
+
+	stock := s.stock.Get()
+	isEditor := s.editor.Get().IsEditor
+	if !isEditor {
+		if result.Reason = s.canPay(tile, from.UpgradeCost); !result.Accepted() {
+			return result
+		}
+		if p.Population > from.Population && stock.Population+int(p.Population-from.Population) > stock.MaxPopulation {
+			result.Reason = res.RejectPopulation
+			return result
+		}
+	}
+
+	fac := s.factory.Get()
+	edit := res.Edit{Tile: tile, Card: -1}
+	before := fac.LandUseState(x, y)
+	fac.Upgrade(world, x, y, cmd.Terrain)
+	edit.LandUse = &res.TileChange{Before: before, After: fac.LandUseState(x, y)}
+	if !isEditor {
+		edit.Stock = s.pay(tile, from.UpgradeCost)
+		edit.Cost = from.UpgradeCost
+	}
+	s.history.Get().Record(edit, s.time.Get().Tick)
+
+	return result
+}
+
+// undo reverts the last step in the history, in reverse order of the edits.
+func (s *ApplyCommands) undo(world *ecs.World, cmd res.Command) res.CommandResult {
+	result := res.CommandResult{Command: cmd}
+	history := s.history.Get()
+
+	step := history.Undo()
+	if step == nil {
+		result.Reason = res.RejectNothingToUndo
+		return result
+	}
+	if result.Reason = s.checkUndo(step); !result.Accepted() {
+		return result
+	}
+
+	fac := s.factory.Get()
+	stock := s.stock.Get()
+	randTerr := s.randTerrains.Get()
+	isLocal := s.rules.Get().LocalStock
+	for i := len(step.Edits) - 1; i >= 0; i-- {
+		edit := &step.Edits[i]
+		x, y := edit.Tile.X, edit.Tile.Y
+		if isLocal {
+			s.applyStockChanges(edit.Stock, true)
+		} else {
+			stock.Refund(edit.Cost)
+			stock.Pay(edit.Refund)
+		}
+		if edit.LandUse != nil {
+			fac.RestoreLandUse(world, x, y, &edit.LandUse.Before)
+		}
+		if edit.Terrain != nil {
+			fac.RestoreTerrain(world, x, y, &edit.Terrain.Before)
+		}
+		for _, n := range edit.Opened {
+			fac.RemoveTerrain(world, n.X, n.Y)
+		}
+		if edit.Terrain != nil {
+			*s.bounds.Get() = edit.Bounds
+		}
+		if edit.Card >= 0 {
+			randTerr.SetCard(edit.Card, edit.CardBefore)
+			randTerr.TotalPlaced = edit.TotalPlaced
+			result.Cards = append(result.Cards, edit.Card)
+		}
+		result.Tile = edit.Tile
+	}
+	history.Undone()
+	return result
+}
+
+// redo re-applies the last undone step in the history.
+func (s *ApplyCommands) redo(world *ecs.World, cmd res.Command) res.CommandResult {
+	result := res.CommandResult{Command: cmd}
+	history := s.history.Get()
+
+	step := history.Redo()
+	if step == nil {
+		result.Reason = res.RejectNothingToRedo
+		return result
+	}
+	if result.Reason = s.checkRedo(step); !result.Accepted() {
+		return result
+	}
+
+	fac := s.factory.Get()
+	stock := s.stock.Get()
+	randTerr := s.randTerrains.Get()
+	isLocal := s.rules.Get().LocalStock
+	for i := range step.Edits {
+		edit := &step.Edits[i]
+		x, y := edit.Tile.X, edit.Tile.Y
+		if edit.Terrain != nil {
+			fac.RestoreTerrain(world, x, y, &edit.Terrain.After)
+		}
+		if edit.LandUse != nil {
+			fac.RestoreLandUse(world, x, y, &edit.LandUse.After)
+		}
+		if isLocal {
+			s.applyStockChanges(edit.Stock, false)
+		} else {
+			stock.Pay(edit.Cost)
+			if edit.Refund != nil {
+				edit.Refund, _ = s.salvage(edit.Tile, &edit.LandUse.Before)
+			}
+		}
+		if edit.Card >= 0 {
+			randTerr.SetCard(edit.Card, edit.CardAfter)
+			randTerr.TotalPlaced = edit.TotalPlaced + 1
+			result.Cards = append(result.Cards, edit.Card)
+		}
+		result.Tile = edit.Tile
+	}
+	history.Redone(s.time.Get().Tick)
+	return result
+}
+
+// checkUndo checks whether the world is still in the state after the step, and whether it can be reverted.
+func (s *ApplyCommands) checkUndo(step *res.HistoryStep) res.Rejection {
+	terrain := s.terrain.Get()
+	landUse := s.landUse.Get()
+	stock := s.stock.Get()
+
+	if undoTime := s.rules.Get().UndoTime; undoTime > 0 && !s.editor.Get().IsEditor &&
+		s.time.Get().Tick-step.Tick > int64(undoTime)*s.update.Get().Interval {
+		return res.RejectUndoExpired
+	}
+
+	population := 0
+	refund := make([]terr.ResourceAmount, len(resource.Properties))
+	visited := map[image.Point]bool{}
+	for i := len(step.Edits) - 1; i >= 0; i-- {
+		edit := &step.Edits[i]
+		x, y := edit.Tile.X, edit.Tile.Y
+		if !visited[edit.Tile] {
+			visited[edit.Tile] = true
+			if edit.Terrain != nil && terrain.Get(x, y) != edit.Terrain.After.Terrain {
+				return res.RejectWorldChanged
+			}
+			if edit.LandUse != nil && landUse.Get(x, y) != edit.LandUse.After.Terrain {
+				return res.RejectWorldChanged
+			}
+			// Cells of a removed multi-tile building may have been used since.
+			if edit.LandUse != nil && !landUse.FootprintFree(x, y, edit.LandUse.Before.Terrain) {
+				return res.RejectWorldChanged
+			}
+		}
+		for _, n := range edit.Opened {
+			if visited[n] {
+				continue
+			}
+			visited[n] = true
+			if terrain.Get(n.X, n.Y) != terr.Buildable || landUse.Get(n.X, n.Y) != terr.Air {
+				return res.RejectWorldChanged
+			}
+		}
+		if edit.LandUse != nil {
+			if reason := s.checkLandUseChange(stock, edit.LandUse.After.Terrain, edit.LandUse.Before.Terrain, &population); reason != res.NotRejected {
+				return reason
+			}
+		}
+		addCosts(refund, edit.Refund)
+	}
+	if s.rules.Get().LocalStock {
+		return s.checkStockChanges(step, true)
+	}
+	if !stock.CanPay(refund) {
+		return res.RejectResources
+	}
+	return res.NotRejected
+}
+
+// checkRedo checks whether the world is still in the state before the step, and whether it can be re-applied.
+func (s *ApplyCommands) checkRedo(step *res.HistoryStep) res.Rejection {
+	terrain := s.terrain.Get()
+	landUse := s.landUse.Get()
+	stock := s.stock.Get()
+	randTerr := s.randTerrains.Get()
+	isEditor := s.editor.Get().IsEditor
+
+	population := 0
+	cost := make([]terr.ResourceAmount, len(resource.Properties))
+	visited := map[image.Point]bool{}
+	for i := range step.Edits {
+		edit := &step.Edits[i]
+		x, y := edit.Tile.X, edit.Tile.Y
+		if !visited[edit.Tile] {
+			visited[edit.Tile] = true
+			if edit.Terrain != nil && terrain.Get(x, y) != edit.Terrain.Before.Terrain {
+				return res.RejectWorldChanged
+			}
+			if edit.LandUse != nil && landUse.Get(x, y) != edit.LandUse.Before.Terrain {
+				return res.RejectWorldChanged
+			}
+			// Cells for a multi-tile building must still be free.
+			if edit.LandUse != nil && !landUse.FootprintFree(x, y, edit.LandUse.After.Terrain) {
+				return res.RejectWorldChanged
+			}
+		}
+		for _, n := range edit.Opened {
+			if visited[n] {
+				continue
+			}
+			visited[n] = true
+			if terrain.Get(n.X, n.Y) != terr.Air {
+				return res.RejectWorldChanged
+			}
+		}
+		if edit.LandUse != nil {
+			after := edit.LandUse.After.Terrain
+			if terr.Properties[after].TerrainBits.Contains(terr.RequiresRange) && !s.inRange(x, y, after) {
+				return res.RejectOutsideArea
+			}
+			if reason := s.checkLandUseChange(stock, edit.LandUse.Before.Terrain, after, &population); reason != res.NotRejected {
+				return reason
+			}
+		}
+		addCosts(cost, edit.Cost)
+		if edit.Card >= 0 && !isEditor && randTerr.TotalPlaced >= randTerr.TotalAvailable {
+			return res.RejectRandomTerrains
+		}
+	}
+	if s.rules.Get().LocalStock {
+		return s.checkStockChanges(step, false)
+	}
+	if !stock.CanPay(cost) {
+		return res.RejectResources
+	}
+	return res.NotRejected
+}
+
+// checkLandUseChange checks whether land use can be replaced, regarding warehouses and population.
+// Population accumulates the population change over all edits of a step.
+func (s *ApplyCommands) checkLandUseChange(stock *res.Stock, from, to terr.Terrain, population *int) res.Rejection {
+	fromProps := &terr.Properties[from]
+	toProps := &terr.Properties[to]
+	if fromProps.TerrainBits.Contains(terr.IsWarehouse) && !toProps.TerrainBits.Contains(terr.IsWarehouse) &&
+		s.isLastWarehouse(stock, from) {
+		return res.RejectLastWarehouse
+	}
+	if s.editor.Get().IsEditor {
+		return res.NotRejected
+	}
+	*population += int(toProps.Population) - int(fromProps.Population)
+	if toProps.Population > 0 && stock.Population+*population > stock.MaxPopulation {
+		return res.RejectPopulation
+	}
+	return res.NotRejected
+}
+
+// airNeighbors returns the neighbors of a tile that are air, and become buildable when placing terrain.
+func (s *ApplyCommands) airNeighbors(terrain *res.Terrain, x, y int) []image.Point {
+	var points []image.Point
+	for _, n := range []image.Point{{x - 1, y}, {x + 1, y}, {x, y - 1}, {x, y + 1}} {
+		if terrain.Contains(n.X, n.Y) && terrain.Get(n.X, n.Y) == terr.Air {
+			points = append(points, n)
+		}
+	}
+	return points
+}
+
+// salvage recovers resources from bulldozed land use at a tile, according to its salvage rules.
+// Returns the amounts that were actually added to the stock,
+// and the changes of warehouse inventories in local stock mode.
+func (s *ApplyCommands) salvage(tile image.Point, state *res.TileState) ([]terr.ResourceAmount, []res.StockChange) {
+	p := &terr.Properties[state.Terrain]
+	stock := s.stock.Get()
+
+	amounts := []terr.ResourceAmount{}
+	for _, c := range p.BuildCost {
+		amounts = append(amounts, terr.ResourceAmount{
+			Resource: c.Resource,
+			Amount:   uint16(int(c.Amount) * int(p.Salvage.BuildCost) / 100),
+		})
+	}
+	if p.Salvage.ReturnStock && state.Production != nil {
+		amounts = append(amounts, terr.ResourceAmount{
+			Resource: state.Production.Resource,
+			Amount:   uint16(state.Production.Stock),
+		})
+	}
+
+	if s.rules.Get().LocalStock {
+		if p.Salvage.ReturnStock && state.Warehouse != nil {
+			for i, st := range state.Warehouse.Stock {
+				if st > 0 {
+					amounts = append(amounts, terr.ResourceAmount{Resource: resource.Resource(i), Amount: uint16(st)})
+				}
+			}
+		}
+		// The removed warehouse is not found anymore.
+		return s.warehouses.Deposit(stock, s.warehouses.Find(comp.Tile{Point: tile}, true), amounts)
+	}
+
+	var removedStorage []uint8
+	if p.TerrainBits.Contains(terr.IsWarehouse) {
+		removedStorage = p.Storage
+	}
+	return stock.Salvage(amounts, removedStorage), nil
+}
+
+// canPay checks whether the cost of an action at a tile can be paid.
+// In local stock mode, it must be paid from warehouses reachable from the tile.
+func (s *ApplyCommands) canPay(tile image.Point, cost []terr.ResourceAmount) res.Rejection {
+	if !s.stock.Get().CanPay(cost) {
+		return res.RejectResources
+	}
+	if s.rules.Get().LocalStock && len(cost) > 0 &&
+		!s.warehouses.CanPay(s.warehouses.Find(comp.Tile{Point: tile}, true), cost) {
+		return res.RejectUnreachable
+	}
+	return res.NotRejected
+}
+
+// pay the cost of an action at a tile.
+// Returns the changes of warehouse inventories in local stock mode, or nil otherwise.
+func (s *ApplyCommands) pay(tile image.Point, cost []terr.ResourceAmount) []res.StockChange {
+	stock := s.stock.Get()
+	if !s.rules.Get().LocalStock {
+		stock.Pay(cost)
+		return nil
+	}
+	if len(cost) == 0 {
+		return nil
+	}
+	return s.warehouses.Pay(stock, s.warehouses.Find(comp.Tile{Point: tile}, true), cost)
+}
+
+// applyStockChanges applies or reverts changes of warehouse inventories, in local stock mode.
+// Changes are reverted in reverse order.
+func (s *ApplyCommands) applyStockChanges(changes []res.StockChange, revert bool) {
+	stock := s.stock.Get()
+	landUseE := s.landUseE.Get()
+	for i := range changes {
+		c := &changes[i]
+		amount := c.Amount
+		if revert {
+			c = &changes[len(changes)-1-i]
+			amount = -c.Amount
+		}
+		wh := s.warehouseMap.Get(landUseE.Get(c.Tile.X, c.Tile.Y))
+		if amount >= 0 {
+			stock.Deposit(wh, c.Resource, amount)
+		} else {
+			stock.Withdraw(wh, c.Resource, -amount)
+		}
+	}
+}
+
+// checkStockChanges checks whether the changes of warehouse inventories of a step can be reverted or re-applied,
+// in local stock mode. Follows the land use changes of the step, as warehouses may be removed or restored by it.
+func (s *ApplyCommands) checkStockChanges(step *res.HistoryStep, undo bool) res.Rejection {
+	landUseE := s.landUseE.Get()
+	// Simulated inventories by tile. Nil for tiles without a warehouse.
+	inventories := map[image.Point][]int{}
+	inventory := func(p image.Point) []int {
+		if inv, ok := inventories[p]; ok {
+			return inv
+		}
+		var inv []int
+		if e := landUseE.Get(p.X, p.Y); !e.IsZero() && s.warehouseMap.HasAll(e) {
+			inv = append([]int{}, s.warehouseMap.Get(e).Stock...)
+		}
+		inventories[p] = inv
+		return inv
+	}
+	setLandUse := func(p image.Point, state *res.TileState) {
+		if state.Warehouse == nil {
+			inventories[p] = nil
+			return
+		}
+		inventories[p] = append([]int{}, state.Warehouse.Stock...)
+	}
+	apply := func(changes []res.StockChange, sign int) res.Rejection {
+		for _, c := range changes {
+			inv := inventory(c.Tile)
+			if inv == nil {
+				return res.RejectWorldChanged
+			}
+			inv[c.Resource] += sign * c.Amount
+			if inv[c.Resource] < 0 {
+				return res.RejectResources
+			}
+		}
+		return res.NotRejected
+	}
+
+	if undo {
+		for i := len(step.Edits) - 1; i >= 0; i-- {
+			edit := &step.Edits[i]
+			changes := slices.Clone(edit.Stock)
+			slices.Reverse(changes)
+			if reason := apply(changes, -1); reason != res.NotRejected {
+				return reason
+			}
+			if edit.LandUse != nil {
+				setLandUse(edit.Tile, &edit.LandUse.Before)
+			}
+		}
+		return res.NotRejected
+	}
+	for i := range step.Edits {
+		edit := &step.Edits[i]
+		if edit.LandUse != nil {
+			setLandUse(edit.Tile, &edit.LandUse.After)
+		}
+		if reason := apply(edit.Stock, 1); reason != res.NotRejected {
+			return reason
+		}
+	}
+	return res.NotRejected
+}
+
+// addCosts adds amounts to a total, indexed by resource.
+func addCosts(total []terr.ResourceAmount, amounts []terr.ResourceAmount) {
+	for _, c := range amounts {
+		total[c.Resource].Resource = c.Resource
+		total[c.Resource].Amount += c.Amount
+	}
+}
@@ -2,6 +2,7 @@ package sys
 
 import (
 	"cmp"
+	"math"
 	"slices"
 
 	"github.com/mlange-42/ark/ecs"
@@ -11,24 +12,33 @@ import (
 )
 
 // AssignHaulers system.
+// Collects the haulers on each path tile, and counts them for congestion.
 type AssignHaulers struct {
+	rules    ecs.Resource[res.Rules]
+	time     ecs.Resource[res.GameTick]
+	traffic  ecs.Resource[res.Traffic]
 	speed    ecs.Resource[res.GameSpeed]
 	update   ecs.Resource[res.UpdateInterval]
 	landUse  ecs.Resource[res.LandUse]
 	landUseE ecs.Resource[res.LandUseEntities]
 
 	haulerFilter *ecs.Filter1[comp.Hauler]
-	pathFilter   *ecs.Filter1[comp.Path]
+	pathFilter   *ecs.Filter2[comp.Tile, comp.Path]
 
 	pathMapper   *ecs.Map1[comp.Path]
 	haulerMapper *ecs.Map1[comp.Hauler]
 	prodMapper   *ecs.Map1[comp.Production]
+	deliveryMap  *ecs.Map1[comp.Delivery]
+	supplyMap    *ecs.Map1[comp.Supply]
 
 	toRemove []ecs.Entity
 }
 
 // Initialize the system
 func (s *AssignHaulers) Initialize(world *ecs.World) {
+	s.rules = ecs.NewResource[res.Rules](world)
+	s.time = ecs.NewResource[res.GameTick](world)
+	s.traffic = ecs.NewResource[res.Traffic](world)
 	s.speed = ecs.NewResource[res.GameSpeed](world)
 	s.update = ecs.NewResource[res.UpdateInterval](world)
 	s.landUse = ecs.NewResource[res.LandUse](world)
@@ -40,6 +50,8 @@ func (s *AssignHaulers) Initialize(world *ecs.World) {
 	s.pathMapper = s.pathMapper.New(world)
 	s.haulerMapper = s.haulerMapper.New(world)
 	s.prodMapper = s.prodMapper.New(world)
+	s.deliveryMap = s.deliveryMap.New(world)
+	s.supplyMap = s.supplyMap.New(world)
 }
 
 // Update the system
@@ -54,7 +66,7 @@ func (s *AssignHaulers) Update(world *ecs.World) {
 
 	pathQuery := s.pathFilter.Query()
 	for pathQuery.Next() {
-		path := pathQuery.Get()
+		_, path := pathQuery.Get()
 		path.Haulers = path.Haulers[:0]
 	}
 
@@ -92,21 +104,50 @@ func (s *AssignHaulers) Update(world *ecs.World) {
 		path.Haulers = append(path.Haulers, comp.HaulerEntry{Entity: haulerQuery.Entity(), YPos: yPos})
 	}
 
+	rules := s.rules.Get()
+	congestion, tolerance := rules.Congestion, rules.CongestionTolerance
+	traffic := s.traffic.Get()
+	// Routes are updated with the traffic once per update interval,
+	// and only if the load on a tile over capacity changed by more than the tolerance.
+	updateRouting := congestion && s.time.Get().Tick%update.Interval == 0
+	routingChanged := false
+
 	pathQuery = s.pathFilter.Query()
 	for pathQuery.Next() {
-		path := pathQuery.Get()
+		tile, path := pathQuery.Get()
+		if congestion {
+			load := trafficLoad(path)
+			traffic.Load.Set(tile.X, tile.Y, load)
+			if updateRouting {
+				routing := int(routingLoad(load, landUse.Get(tile.X, tile.Y)))
+				if diff := routing - int(traffic.Routing.Get(tile.X, tile.Y)); diff > tolerance || -diff > tolerance {
+					traffic.Routing.Set(tile.X, tile.Y, uint8(routing))
+					routingChanged = true
+				}
+			}
+		}
 
 		slices.SortStableFunc(path.Haulers, func(a, b comp.HaulerEntry) int {
 			return cmp.Compare(a.YPos, b.YPos)
 		})
 	}
+	if routingChanged {
+		traffic.Version++
+	}
 
 	for _, e := range s.toRemove {
 		haul := s.haulerMapper.Get(e)
 
 		if world.Alive(haul.Home) {
-			prod := s.prodMapper.Get(haul.Home)
-			prod.IsHauling = false
+			if s.deliveryMap.HasAll(e) {
+				// Delivered goods are lost.
+				if s.supplyMap.HasAll(haul.Home) {
+					s.supplyMap.Get(haul.Home).Requested[haul.Hauls] = false
+				}
+			} else {
+				prod := s.prodMapper.Get(haul.Home)
+				prod.IsHauling = false
+			}
 		}
 
 		world.RemoveEntity(e)
@@ -116,3 +157,19 @@ func (s *AssignHaulers) Update(world *ecs.World) {
 
 // Finalize the system
 func (s *AssignHaulers) Finalize(world *ecs.World) {}
+
+// trafficLoad returns the number of haulers on a path tile, for congestion.
+func trafficLoad(path *comp.Path) uint8 {
+	return uint8(min(len(path.Haulers), math.MaxUint8))
+}
+
+// routingLoad returns the load of a path tile used for routing.
+// Loads up to the capacity of the tile don't slow down haulers, and are reported as 0.
+// The same applies to tiles without capacity. See [nav.StepCost].
+func routingLoad(load uint8, landUse terr.Terrain) uint8 {
+	capacity := terr.Properties[landUse].Capacity
+	if capacity == 0 || load <= capacity {
+		return 0
+	}
+	return load
+}
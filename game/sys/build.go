@@ -1,62 +1,63 @@
 package sys
 
 import (
-	"fmt"
 	"image"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/mlange-42/ark/ecs"
-	"github.com/mlange-42/tiny-world/game/comp"
 	"github.com/mlange-42/tiny-world/game/res"
-	"github.com/mlange-42/tiny-world/game/resource"
 	"github.com/mlange-42/tiny-world/game/terr"
 )
 
 // Build system.
+// Translates mouse input into commands, and updates the UI from applied commands.
 type Build struct {
-	time            ecs.Resource[res.GameTick]
-	rules           ecs.Resource[res.Rules]
-	view            ecs.Resource[res.View]
-	terrain         ecs.Resource[res.Terrain]
-	terrainEntities ecs.Resource[res.TerrainEntities]
-	landUse         ecs.Resource[res.LandUse]
-	buildable       ecs.Resource[res.Buildable]
-	stock           ecs.Resource[res.Stock]
-	selection       ecs.Resource[res.Selection]
-	update          ecs.Resource[res.UpdateInterval]
-	ui              ecs.Resource[res.UI]
-	factory         ecs.Resource[res.EntityFactory]
-	editor          ecs.Resource[res.EditorMode]
-	randTerrains    ecs.Resource[res.RandomTerrains]
-
-	radiusFilter    *ecs.Filter2[comp.Tile, comp.BuildRadius]
-	warehouseFilter *ecs.Filter1[comp.Warehouse]
+	UndoKey ebiten.Key
+	RedoKey ebiten.Key
+
+	time      ecs.Resource[res.GameTick]
+	view      ecs.Resource[res.View]
+	stock     ecs.Resource[res.Stock]
+	selection ecs.Resource[res.Selection]
+	ui        ecs.Resource[res.UI]
+	editor    ecs.Resource[res.EditorMode]
+	landUse   ecs.Resource[res.LandUse]
+	commands  ecs.Resource[res.Commands]
+
+	isStroke bool
 }
 
 // Initialize the system
 func (s *Build) Initialize(world *ecs.World) {
 	s.time = ecs.NewResource[res.GameTick](world)
-	s.rules = ecs.NewResource[res.Rules](world)
 	s.view = ecs.NewResource[res.View](world)
-	s.terrain = ecs.NewResource[res.Terrain](world)
-	s.terrainEntities = ecs.NewResource[res.TerrainEntities](world)
-	s.landUse = ecs.NewResource[res.LandUse](world)
-	s.buildable = ecs.NewResource[res.Buildable](world)
 	s.stock = ecs.NewResource[res.Stock](world)
 	s.selection = ecs.NewResource[res.Selection](world)
-	s.update = ecs.NewResource[res.UpdateInterval](world)
 	s.ui = ecs.NewResource[res.UI](world)
-	s.factory = ecs.NewResource[res.EntityFactory](world)
 	s.editor = ecs.NewResource[res.EditorMode](world)
-	s.randTerrains = ecs.NewResource[res.RandomTerrains](world)
-
-	s.radiusFilter = s.radiusFilter.New(world)
-	s.warehouseFilter = s.warehouseFilter.New(world)
+	s.landUse = ecs.NewResource[res.LandUse](world)
+	s.commands = ecs.NewResource[res.Commands](world)
 }
 
 // Update the system
 func (s *Build) Update(world *ecs.World) {
+	s.handleResults()
+
+	if !ebiten.IsMouseButtonPressed(ebiten.MouseButton0) {
+		s.isStroke = false
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyControl) {
+		if inpututil.IsKeyJustPressed(s.UndoKey) {
+			s.commands.Get().Push(res.Command{Type: res.Undo})
+			return
+		}
+		if inpututil.IsKeyJustPressed(s.RedoKey) {
+			s.commands.Get().Push(res.Command{Type: res.Redo})
+			return
+		}
+	}
+
 	ui := s.ui.Get()
 	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) || inpututil.IsMouseButtonJustPressed(ebiten.MouseButton2) {
 		ui.ClearSelection()
@@ -66,112 +67,91 @@ func (s *Build) Update(world *ecs.World) {
 	if s.checkAbort(isEditor) {
 		return
 	}
-	buildable := s.buildable.Get()
 	sel := s.selection.Get()
 	view := s.view.Get()
 	x, y := ebiten.CursorPosition()
 	mx, my := view.ScreenToGlobal(x, y)
 	cursor := view.GlobalToTile(mx, my)
 
-	p := &terr.Properties[sel.BuildType]
-	if p.TerrainBits.Contains(terr.RequiresRange) && buildable.Get(cursor.X, cursor.Y) == 0 {
-		ui.SetStatusLabel("Outside of controlled area.")
-		return
+	cmd := res.Command{
+		Tile:        cursor,
+		Terrain:     sel.BuildType,
+		RandSprite:  sel.RandSprite,
+		Randomize:   sel.Randomize,
+		AllowRemove: sel.AllowRemove,
+		// In the editor, a drag stroke is undone as a single step.
+		Continue: isEditor && s.isStroke,
 	}
-
-	fac := s.factory.Get()
-	rules := s.rules.Get()
-	stock := s.stock.Get()
-	randTerr := s.randTerrains.Get()
-	landUse := s.landUse.Get()
-
-	if !isEditor {
-		if !stock.CanPay(p.BuildCost) {
-			ui.SetStatusLabel("Not enough resources.")
-			return
-		}
-		if p.TerrainBits.Contains(terr.CanBuild) && !p.TerrainBits.Contains(terr.CanBuy) {
-			if randTerr.TotalPlaced >= randTerr.TotalAvailable {
-				ui.SetStatusLabel("No more random terrains available.")
-				return
-			}
-		}
-	}
-
+	p := &terr.Properties[sel.BuildType]
 	if sel.BuildType == terr.Bulldoze {
-		luHere := landUse.Get(cursor.X, cursor.Y)
-		luProps := &terr.Properties[luHere]
-
-		if luProps.TerrainBits.Contains(terr.IsWarehouse) && s.isLastWarehouse(stock, luHere) {
-			ui.SetStatusLabel("Can't destroy last warehouse.")
-			return
-		}
-
-		if luProps.TerrainBits.Contains(terr.CanBuild) {
-			fac.RemoveLandUse(world, cursor.X, cursor.Y)
-
-			if !isEditor {
-				stock.Pay(p.BuildCost)
-			}
-			ui.ReplaceButton(stock, rules, randTerr, s.time.Get().RenderTick, image.Pt(x, y))
+		cmd.Type = res.Bulldoze
+	} else if p.TerrainBits.Contains(terr.CanBuy) {
+		cmd.Type = res.PlaceBuilding
+		if s.isUpgrade(cursor, sel.BuildType) {
+			cmd.Type = res.Upgrade
 		}
-		return
-	}
-
-	if !isEditor {
-		if p.Population > 0 && stock.Population+int(p.Population) > stock.MaxPopulation {
-			ui.SetStatusLabel("Population limit reached.")
+	} else if isEditor {
+		cmd.Type = res.PlaceTerrain
+	} else {
+		card, ok := ui.CardIndex(sel.ButtonID)
+		if !ok {
 			return
 		}
+		cmd.Type = res.PlaceRandomCard
+		cmd.Card = card
 	}
+	s.commands.Get().Push(cmd)
+	s.isStroke = true
+}
 
-	terrain := s.terrain.Get()
-	terrHere := terrain.Get(cursor.X, cursor.Y)
-	luHere := landUse.Get(cursor.X, cursor.Y)
-	if p.TerrainBits.Contains(terr.IsTerrain) {
-		canBuild := luHere == terr.Air
-		if terrHere == terr.Air {
-			ui.SetStatusLabel("Can only add next to existing terrain.")
-			return
-		}
-		canBuild = canBuild &&
-			(p.BuildOn.Contains(terrHere) || (sel.AllowRemove && terrHere != sel.BuildType))
-		if !canBuild {
-			ui.SetStatusLabel("Terrain already occupied.")
-			return
-		}
-		fac.Set(world, cursor.X, cursor.Y, sel.BuildType, sel.RandSprite, sel.Randomize)
-	} else {
-		if terrHere == terr.Air || terrHere == terr.Buildable {
-			ui.SetStatusLabel("No terrain here.")
-			return
-		}
-		if !p.BuildOn.Contains(terrHere) {
-			ui.SetStatusLabel(fmt.Sprintf("Can't build this on %s", terr.Properties[terrHere].Name))
-			return
-		}
+// Finalize the system
+func (s *Build) Finalize(world *ecs.World) {}
 
-		luNatural := !terr.Properties[luHere].TerrainBits.Contains(terr.CanBuy)
-		if luHere == terr.Air || (luNatural && p.TerrainBits.Contains(terr.CanBuy)) {
-			if luHere != terr.Air {
-				fac.RemoveLandUse(world, cursor.X, cursor.Y)
+// handleResults updates the UI from the commands applied in the last update.
+func (s *Build) handleResults() {
+	ui := s.ui.Get()
+	view := s.view.Get()
+	sel := s.selection.Get()
+	stock := s.stock.Get()
+	renderTick := s.time.Get().RenderTick
+
+	for _, result := range s.commands.Get().Results {
+		if !result.Accepted() {
+			continue
+		}
+		pos := view.TileToGlobal(result.Tile.X, result.Tile.Y)
+		target := image.Pt(view.GlobalToScreen(pos.X, pos.Y+view.TileHeight/2-view.MouseOffset))
+
+		switch result.Type {
+		case res.PlaceRandomCard:
+			ui.ReplaceButton(result.Card, renderTick, target)
+		case res.PlaceTerrain:
+			if card, ok := ui.CardIndex(sel.ButtonID); ok && result.Terrain == sel.BuildType {
+				ui.ReplaceButton(card, renderTick, target)
+			}
+		case res.Undo, res.Redo:
+			for _, card := range result.Cards {
+				ui.RefreshButton(card)
+			}
+		case res.Upgrade:
+			// Selection is kept for upgrading further buildings.
+		default:
+			if !stock.CanPay(terr.Properties[sel.BuildType].BuildCost) {
+				ui.ClearSelection()
 			}
-			fac.Set(world, cursor.X, cursor.Y, sel.BuildType, sel.RandSprite, sel.Randomize)
-		} else {
-			ui.SetStatusLabel("Terrain already occupied.")
-			return
 		}
 	}
+}
 
-	if !isEditor {
-		stock.Pay(p.BuildCost)
+// isUpgrade checks whether the building at the given tile can be upgraded to the given building.
+func (s *Build) isUpgrade(tile image.Point, build terr.Terrain) bool {
+	landUse := s.landUse.Get()
+	if !landUse.Contains(tile.X, tile.Y) {
+		return false
 	}
-	ui.ReplaceButton(stock, rules, randTerr, s.time.Get().RenderTick, image.Pt(x, y))
+	return terr.Properties[landUse.Get(tile.X, tile.Y)].UpgradesTo == build
 }
 
-// Finalize the system
-func (s *Build) Finalize(world *ecs.World) {}
-
 func (s *Build) checkAbort(isEditor bool) bool {
 	if isEditor {
 		if !ebiten.IsMouseButtonPressed(ebiten.MouseButton0) {
@@ -196,13 +176,3 @@ func (s *Build) checkAbort(isEditor bool) bool {
 	}
 	return false
 }
-
-func (s *Build) isLastWarehouse(stock *res.Stock, building terr.Terrain) bool {
-	storage := terr.Properties[building].Storage
-	for i := range resource.Properties {
-		if stock.Cap[i] <= int(storage[i]) {
-			return true
-		}
-	}
-	return false
-}
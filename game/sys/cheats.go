@@ -10,6 +10,7 @@ import (
 // Cheats system.
 type Cheats struct {
 	rules  ecs.Resource[res.Rules]
+	rand   ecs.Resource[res.Rand]
 	stock  ecs.Resource[res.Stock]
 	ui     ecs.Resource[res.UI]
 	editor ecs.Resource[res.EditorMode]
@@ -18,6 +19,7 @@ type Cheats struct {
 // Initialize the system
 func (s *Cheats) Initialize(world *ecs.World) {
 	s.rules = ecs.NewResource[res.Rules](world)
+	s.rand = ecs.NewResource[res.Rand](world)
 	s.stock = ecs.NewResource[res.Stock](world)
 	s.ui = ecs.NewResource[res.UI](world)
 	s.editor = ecs.NewResource[res.EditorMode](world)
@@ -37,6 +39,9 @@ func (s *Cheats) Update(world *ecs.World) {
 
 		stock := s.stock.Get()
 		copy(stock.Res, stock.Cap)
+		if s.rules.Get().LocalStock {
+			fillWarehouses(world, stock)
+		}
 		return
 	}
 
@@ -51,7 +56,7 @@ func (s *Cheats) Update(world *ecs.World) {
 		}
 
 		ui := s.ui.Get()
-		ui.ReplaceAllButtons(s.rules.Get())
+		ui.ReplaceAllButtons(s.rules.Get(), s.rand.Get())
 	}
 }
 
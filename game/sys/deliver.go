@@ -0,0 +1,167 @@
+package sys
+
+import (
+	"github.com/mlange-42/ark/ecs"
+	"github.com/mlange-42/tiny-world/game/comp"
+	"github.com/mlange-42/tiny-world/game/nav"
+	"github.com/mlange-42/tiny-world/game/res"
+	"github.com/mlange-42/tiny-world/game/resource"
+	"github.com/mlange-42/tiny-world/game/sprites"
+	"github.com/mlange-42/tiny-world/game/terr"
+)
+
+// Deliver system.
+// Sends haulers from warehouses to refill the input buffers of consumers, in delivery mode.
+type Deliver struct {
+	rules   ecs.Resource[res.Rules]
+	speed   ecs.Resource[res.GameSpeed]
+	time    ecs.Resource[res.GameTick]
+	update  ecs.Resource[res.UpdateInterval]
+	stock   ecs.Resource[res.Stock]
+	editor  ecs.Resource[res.EditorMode]
+	network ecs.Resource[nav.Network]
+
+	filter        *ecs.Filter4[comp.Tile, comp.UpdateTick, comp.Consumption, comp.Supply]
+	prodMap       *ecs.Map1[comp.Production]
+	haulerBuilder *ecs.Map4[comp.Tile, comp.Hauler, comp.HaulerSprite, comp.Delivery]
+
+	warehouses warehouseFinder
+
+	toCreate      []deliveryEntry
+	haulerSprites []int
+}
+
+// deliveryEntry is a delivery to be started.
+type deliveryEntry struct {
+	Home     ecs.Entity
+	Resource resource.Resource
+	Amount   uint8
+	Path     []comp.Tile
+}
+
+// Initialize the system
+func (s *Deliver) Initialize(world *ecs.World) {
+	s.rules = ecs.NewResource[res.Rules](world)
+	s.speed = ecs.NewResource[res.GameSpeed](world)
+	s.time = ecs.NewResource[res.GameTick](world)
+	s.update = ecs.NewResource[res.UpdateInterval](world)
+	s.stock = ecs.NewResource[res.Stock](world)
+	s.editor = ecs.NewResource[res.EditorMode](world)
+	s.network = ecs.NewResource[nav.Network](world)
+
+	s.filter = s.filter.New(world)
+	s.prodMap = s.prodMap.New(world)
+	s.haulerBuilder = s.haulerBuilder.New(world)
+
+	s.warehouses = newWarehouseFinder(world)
+
+	// Delivery haulers look like haulers of a building that produces the resource.
+	// Sprites are not available when running headless.
+	s.haulerSprites = make([]int, len(resource.Properties))
+	spritesRes := ecs.NewResource[res.Sprites](world)
+	if spritesRes.Has() {
+		spr := spritesRes.Get()
+		for i := range resource.Properties {
+			s.haulerSprites[i] = spr.GetIndex(sprites.HaulerPrefix)
+			for j := range terr.Properties {
+				prod := &terr.Properties[j].Production
+				if prod.MaxProduction > 0 && prod.Resource == resource.Resource(i) {
+					s.haulerSprites[i] = spr.GetIndex(sprites.HaulerPrefix + terr.Properties[j].Name)
+					break
+				}
+			}
+		}
+	}
+}
+
+// Update the system
+func (s *Deliver) Update(world *ecs.World) {
+	rules := s.rules.Get()
+	if !rules.Delivery || s.speed.Get().Pause || s.editor.Get().IsEditor {
+		return
+	}
+
+	stock := s.stock.Get()
+	tick := s.time.Get().Tick
+	tickMod := tick % s.update.Get().Interval
+	isLocal := rules.LocalStock
+	buffer := rules.DeliveryBuffer
+	network := s.network.Get()
+
+	query := s.filter.Query()
+	for query.Next() {
+		tile, up, cons, supply := query.Get()
+		if up.Tick != tickMod {
+			continue
+		}
+
+		var prod *comp.Production
+		if s.prodMap.HasAll(query.Entity()) {
+			prod = s.prodMap.Get(query.Entity())
+		}
+
+		// Reachable warehouses, searched only when required.
+		var found []warehouseEntry
+		for i, c := range cons.Amount {
+			r := resource.Resource(i)
+			if c == 0 || supply.Requested[i] || int(supply.Stock[i]) >= buffer {
+				continue
+			}
+			if prod != nil && prod.Resource == r {
+				// Consumers use their own production.
+				continue
+			}
+			if found == nil {
+				found = s.warehouses.Find(*tile, false)
+			}
+			for j := range found {
+				wh, storage := s.warehouses.Get(&found[j])
+				available := stock.Res[i]
+				if isLocal {
+					available = wh.Stock[i]
+				} else if storage[i] == 0 {
+					continue
+				}
+				amount := min(buffer-int(supply.Stock[i]), available)
+				if amount <= 0 {
+					continue
+				}
+				path, ok := network.FindPath(found[j].Tile, *tile)
+				if !ok {
+					continue
+				}
+				if isLocal {
+					stock.Withdraw(wh, r, amount)
+				} else {
+					stock.Res[i] -= amount
+				}
+				supply.Requested[i] = true
+				s.toCreate = append(s.toCreate, deliveryEntry{
+					Home: query.Entity(), Resource: r, Amount: uint8(amount), Path: path,
+				})
+				break
+			}
+		}
+	}
+
+	for _, entry := range s.toCreate {
+		s.haulerBuilder.NewEntity(
+			&entry.Path[len(entry.Path)-1],
+			&comp.Hauler{
+				Hauls:        entry.Resource,
+				Home:         entry.Home,
+				Path:         entry.Path,
+				PathFraction: 0,
+				Index:        len(entry.Path) - 1,
+			},
+			&comp.HaulerSprite{
+				SpriteIndex: s.haulerSprites[entry.Resource],
+			},
+			&comp.Delivery{Amount: entry.Amount},
+		)
+	}
+	s.toCreate = s.toCreate[:0]
+}
+
+// Finalize the system
+func (s *Deliver) Finalize(world *ecs.World) {}
@@ -5,21 +5,27 @@ import (
 	"github.com/mlange-42/tiny-world/game/comp"
 	"github.com/mlange-42/tiny-world/game/res"
 	"github.com/mlange-42/tiny-world/game/resource"
+	"github.com/mlange-42/tiny-world/game/terr"
 )
 
 // DoConsumption system.
 type DoConsumption struct {
+	rules  ecs.Resource[res.Rules]
 	speed  ecs.Resource[res.GameSpeed]
 	time   ecs.Resource[res.GameTick]
 	update ecs.Resource[res.UpdateInterval]
 	stock  ecs.Resource[res.Stock]
 	editor ecs.Resource[res.EditorMode]
 
-	filter *ecs.Filter3[comp.UpdateTick, comp.Production, comp.Consumption]
+	filter    *ecs.Filter4[comp.Tile, comp.UpdateTick, comp.Production, comp.Consumption]
+	supplyMap *ecs.Map1[comp.Supply]
+
+	warehouses warehouseFinder
 }
 
 // Initialize the system
 func (s *DoConsumption) Initialize(world *ecs.World) {
+	s.rules = ecs.NewResource[res.Rules](world)
 	s.speed = ecs.NewResource[res.GameSpeed](world)
 	s.time = ecs.NewResource[res.GameTick](world)
 	s.update = ecs.NewResource[res.UpdateInterval](world)
@@ -27,6 +33,8 @@ func (s *DoConsumption) Initialize(world *ecs.World) {
 	s.editor = ecs.NewResource[res.EditorMode](world)
 
 	s.filter = s.filter.New(world)
+	s.supplyMap = s.supplyMap.New(world)
+	s.warehouses = newWarehouseFinder(world)
 }
 
 // Update the system
@@ -35,6 +43,8 @@ func (s *DoConsumption) Update(world *ecs.World) {
 		return
 	}
 	isEditor := s.editor.Get().IsEditor
+	isLocal := s.rules.Get().LocalStock
+	isDelivery := s.rules.Get().Delivery
 
 	stock := s.stock.Get()
 	tick := s.time.Get().Tick
@@ -43,7 +53,7 @@ func (s *DoConsumption) Update(world *ecs.World) {
 
 	query := s.filter.Query()
 	for query.Next() {
-		up, prod, cons := query.Get()
+		tile, up, prod, cons := query.Get()
 
 		if up.Tick != tickMod {
 			continue
@@ -54,12 +64,38 @@ func (s *DoConsumption) Update(world *ecs.World) {
 			continue
 		}
 
+		var supply *comp.Supply
+		if isDelivery && s.supplyMap.HasAll(query.Entity()) {
+			supply = s.supplyMap.Get(query.Entity())
+		}
+		// Reachable warehouses, searched only when required.
+		var found []warehouseEntry
 		for i, c := range cons.Amount {
 			cons.Countdown[i] -= int16(c)
 			if cons.Countdown[i] < 0 {
 				if prod.Resource == resource.Resource(i) && prod.Stock > 0 {
 					cons.Countdown[i] += int16(update.Countdown)
 					prod.Stock--
+				} else if isDelivery {
+					// Only delivered goods can be consumed.
+					if supply != nil && supply.Stock[i] > 0 {
+						cons.Countdown[i] += int16(update.Countdown)
+						supply.Stock[i]--
+					} else {
+						cons.Countdown[i] = 0
+						cons.IsSatisfied = false
+					}
+				} else if isLocal {
+					if found == nil {
+						found = s.warehouses.Find(*tile, false)
+					}
+					if s.warehouses.Available(found, resource.Resource(i)) > 0 {
+						cons.Countdown[i] += int16(update.Countdown)
+						s.warehouses.Pay(stock, found, []terr.ResourceAmount{{Resource: resource.Resource(i), Amount: 1}})
+					} else {
+						cons.Countdown[i] = 0
+						cons.IsSatisfied = false
+					}
 				} else if stock.Res[i] > 0 {
 					cons.Countdown[i] += int16(update.Countdown)
 					stock.Res[i]--
@@ -1,6 +1,8 @@
 package sys
 
 import (
+	"image"
+
 	"github.com/mlange-42/ark/ecs"
 	"github.com/mlange-42/tiny-world/game/comp"
 	"github.com/mlange-42/tiny-world/game/res"
@@ -16,11 +18,16 @@ type DoProduction struct {
 	stock   ecs.Resource[res.Stock]
 	landUse ecs.Resource[res.LandUse]
 	editor  ecs.Resource[res.EditorMode]
+	rules   ecs.Resource[res.Rules]
+	rand    ecs.Resource[res.Rand]
+	factory ecs.Resource[res.EntityFactory]
 
 	filter        *ecs.Filter4[comp.Terrain, comp.Tile, comp.UpdateTick, comp.Production]
 	markerBuilder *ecs.Map2[comp.Tile, comp.ProductionMarker]
+	inputMapper   *ecs.Map1[comp.InputBuffer]
 
-	toCreate []markerEntry
+	toCreate  []markerEntry
+	toDeplete []image.Point
 }
 
 // Initialize the system
@@ -31,9 +38,13 @@ func (s *DoProduction) Initialize(world *ecs.World) {
 	s.stock = ecs.NewResource[res.Stock](world)
 	s.landUse = ecs.NewResource[res.LandUse](world)
 	s.editor = ecs.NewResource[res.EditorMode](world)
+	s.rules = ecs.NewResource[res.Rules](world)
+	s.rand = ecs.NewResource[res.Rand](world)
+	s.factory = ecs.NewResource[res.EntityFactory](world)
 
 	s.filter = s.filter.New(world)
 	s.markerBuilder = s.markerBuilder.New(world)
+	s.inputMapper = s.inputMapper.New(world)
 }
 
 // Update the system
@@ -45,6 +56,8 @@ func (s *DoProduction) Update(world *ecs.World) {
 	tick := s.time.Get().Tick
 	update := s.update.Get()
 	tickMod := tick % update.Interval
+	depletion := s.rules.Get().Depletion
+	rng := s.rand.Get()
 
 	query := s.filter.Query()
 	for query.Next() {
@@ -54,15 +67,25 @@ func (s *DoProduction) Update(world *ecs.World) {
 			continue
 		}
 
-		if pr.Stock >= terr.Properties[ter.Terrain].Storage[pr.Resource] {
+		props := &terr.Properties[ter.Terrain]
+		if pr.Stock >= props.Storage[pr.Resource] {
 			continue
 		}
 
 		pr.Countdown -= int(pr.Amount)
 		if pr.Countdown < 0 {
+			if props.Production.HasInputs() && !takeInputs(s.inputMapper.Get(query.Entity()), &props.Production) {
+				// Stalls until inputs are available.
+				pr.Countdown = 0
+				pr.HasRequired = false
+				continue
+			}
 			pr.Countdown += update.Countdown
 			pr.Stock++
 			s.toCreate = append(s.toCreate, markerEntry{Tile: *tile, Resource: pr.Resource, Home: query.Entity()})
+			if depletion > 0 && props.Production.ProductionTerrain != 0 && rng.Float64() < depletion {
+				s.toDeplete = append(s.toDeplete, tile.Point)
+			}
 		}
 	}
 
@@ -73,6 +96,42 @@ func (s *DoProduction) Update(world *ecs.World) {
 		)
 	}
 	s.toCreate = s.toCreate[:0]
+
+	for _, p := range s.toDeplete {
+		s.deplete(world, p)
+	}
+	s.toDeplete = s.toDeplete[:0]
+}
+
+// deplete removes a random natural land use neighbor the producer at the given position works on.
+// Land use that can be bought, like fields, is not depleted.
+func (s *DoProduction) deplete(world *ecs.World, p image.Point) {
+	landUse := s.landUse.Get()
+	rect := landUse.Footprint(p.X, p.Y)
+	prodTerrain := terr.Properties[landUse.Get(p.X, p.Y)].Production.ProductionTerrain
+
+	candidates := []image.Point{}
+	for x := rect.Min.X - 1; x <= rect.Max.X; x++ {
+		for y := rect.Min.Y - 1; y <= rect.Max.Y; y++ {
+			if image.Pt(x, y).In(rect) || !landUse.Contains(x, y) {
+				continue
+			}
+			lu := landUse.Get(x, y)
+			if !prodTerrain.Contains(lu) {
+				continue
+			}
+			bits := terr.Properties[lu].TerrainBits
+			if bits.Contains(terr.CanBuy) || bits.Contains(terr.IsBuilding) {
+				continue
+			}
+			candidates = append(candidates, image.Pt(x, y))
+		}
+	}
+	if len(candidates) == 0 {
+		return
+	}
+	c := candidates[s.rand.Get().IntN(len(candidates))]
+	s.factory.Get().RemoveLandUse(world, c.X, c.Y)
 }
 
 // Finalize the system
@@ -0,0 +1,204 @@
+package sys
+
+import (
+	"fmt"
+	"image"
+	"slices"
+
+	"github.com/mlange-42/ark/ecs"
+	"github.com/mlange-42/tiny-world/game/comp"
+	"github.com/mlange-42/tiny-world/game/res"
+	"github.com/mlange-42/tiny-world/game/terr"
+	"github.com/mlange-42/tiny-world/game/util"
+)
+
+// Fire system.
+// Starts fires on flammable land use and spreads them.
+// Burning land use is destroyed after a while, unless the fire is contained.
+type Fire struct {
+	speed    ecs.Resource[res.GameSpeed]
+	time     ecs.Resource[res.GameTick]
+	update   ecs.Resource[res.UpdateInterval]
+	rules    ecs.Resource[res.Rules]
+	rand     ecs.Resource[res.Rand]
+	terrain  ecs.Resource[res.Terrain]
+	landUse  ecs.Resource[res.LandUse]
+	landUseE ecs.Resource[res.LandUseEntities]
+	factory  ecs.Resource[res.EntityFactory]
+	editor   ecs.Resource[res.EditorMode]
+	hud      ecs.Resource[res.Feedback]
+	eventLog ecs.Resource[res.EventLog]
+
+	filter           *ecs.Filter3[comp.Terrain, comp.Tile, comp.UpdateTick]
+	protectionFilter *ecs.Filter2[comp.Tile, comp.FireProtection]
+	burningMapper    *ecs.Map1[comp.Burning]
+	terrainMapper    *ecs.Map1[comp.Terrain]
+
+	water terr.Terrains
+
+	toIgnite     []ignition
+	toExtinguish []ecs.Entity
+	toDestroy    []image.Point
+}
+
+type ignition struct {
+	Entity ecs.Entity
+	Spread bool
+}
+
+// Initialize the system
+func (s *Fire) Initialize(world *ecs.World) {
+	s.speed = ecs.NewResource[res.GameSpeed](world)
+	s.time = ecs.NewResource[res.GameTick](world)
+	s.update = ecs.NewResource[res.UpdateInterval](world)
+	s.rules = ecs.NewResource[res.Rules](world)
+	s.rand = ecs.NewResource[res.Rand](world)
+	s.terrain = ecs.NewResource[res.Terrain](world)
+	s.landUse = ecs.NewResource[res.LandUse](world)
+	s.landUseE = ecs.NewResource[res.LandUseEntities](world)
+	s.factory = ecs.NewResource[res.EntityFactory](world)
+	s.editor = ecs.NewResource[res.EditorMode](world)
+	s.hud = ecs.NewResource[res.Feedback](world)
+	s.eventLog = ecs.NewResource[res.EventLog](world)
+
+	s.filter = s.filter.New(world)
+	s.protectionFilter = s.protectionFilter.New(world)
+	s.burningMapper = s.burningMapper.New(world)
+	s.terrainMapper = s.terrainMapper.New(world)
+
+	s.water = terr.NewTerrains(terr.ToTerrain("water"))
+}
+
+// Update the system
+func (s *Fire) Update(world *ecs.World) {
+	rules := s.rules.Get()
+	if !rules.Fire || s.speed.Get().Pause || s.editor.Get().IsEditor {
+		return
+	}
+
+	rng := s.rand.Get()
+	tick := s.time.Get().Tick
+	interval := s.update.Get().Interval
+	tickMod := tick % interval
+
+	query := s.filter.Query()
+	for query.Next() {
+		ter, tile, up := query.Get()
+		if up.Tick != tickMod || !terr.Properties[ter.Terrain].TerrainBits.Contains(terr.IsFlammable) {
+			continue
+		}
+		e := query.Entity()
+
+		if !s.burningMapper.HasAll(e) {
+			if rng.Float64() < rules.FireProbability && rng.Float64() >= s.protection(tile.Point) {
+				s.toIgnite = append(s.toIgnite, ignition{Entity: e})
+			}
+			continue
+		}
+
+		if rng.Float64() < rules.FireContainment*s.protection(tile.Point) {
+			s.toExtinguish = append(s.toExtinguish, e)
+			continue
+		}
+		s.spread(tile.Point, rules.FireSpread)
+
+		burning := s.burningMapper.Get(e)
+		burning.Countdown--
+		if burning.Countdown <= 0 {
+			s.toDestroy = append(s.toDestroy, tile.Point)
+		}
+	}
+
+	time := tick / interval
+	landUse := s.landUse.Get()
+
+	for _, ign := range s.toIgnite {
+		if !world.Alive(ign.Entity) || s.burningMapper.HasAll(ign.Entity) {
+			continue
+		}
+		s.burningMapper.Add(ign.Entity, &comp.Burning{Countdown: rules.FireDuration})
+		lu := s.terrainMapper.Get(ign.Entity).Terrain
+		if terr.Properties[lu].TerrainBits.Contains(terr.IsBuilding) {
+			s.report(time, fmt.Sprintf("%s is on fire!", util.Capitalize(terr.Properties[lu].Name)))
+		} else if !ign.Spread {
+			s.report(time, "A forest fire broke out!")
+		}
+	}
+
+	for _, e := range s.toExtinguish {
+		s.burningMapper.Remove(e)
+		lu := s.terrainMapper.Get(e).Terrain
+		if terr.Properties[lu].TerrainBits.Contains(terr.IsBuilding) {
+			s.report(time, fmt.Sprintf("Fire at %s was contained.", terr.Properties[lu].Name))
+		}
+	}
+
+	fac := s.factory.Get()
+	for _, p := range s.toDestroy {
+		lu := landUse.Get(p.X, p.Y)
+		fac.RemoveLandUse(world, p.X, p.Y)
+		if terr.Properties[lu].TerrainBits.Contains(terr.IsBuilding) {
+			s.report(time, fmt.Sprintf("%s was destroyed by fire.", util.Capitalize(terr.Properties[lu].Name)))
+		}
+	}
+
+	s.toIgnite = s.toIgnite[:0]
+	s.toExtinguish = s.toExtinguish[:0]
+	s.toDestroy = s.toDestroy[:0]
+}
+
+// Finalize the system
+func (s *Fire) Finalize(world *ecs.World) {}
+
+// spread lets the fire of the land use at the given origin spread to flammable neighbors.
+func (s *Fire) spread(origin image.Point, probability float64) {
+	rng := s.rand.Get()
+	landUse := s.landUse.Get()
+	landUseE := s.landUseE.Get()
+	rect := landUse.Footprint(origin.X, origin.Y)
+
+	for x := rect.Min.X - 1; x <= rect.Max.X; x++ {
+		for y := rect.Min.Y - 1; y <= rect.Max.Y; y++ {
+			if image.Pt(x, y).In(rect) || !landUse.Contains(x, y) {
+				continue
+			}
+			if !terr.Properties[landUse.Get(x, y)].TerrainBits.Contains(terr.IsFlammable) {
+				continue
+			}
+			e := landUseE.Get(x, y)
+			if s.burningMapper.HasAll(e) || slices.Contains(s.toIgnite, ignition{Entity: e, Spread: true}) {
+				continue
+			}
+			if rng.Float64() < probability*(1-s.protection(landUse.Origin(x, y))) {
+				s.toIgnite = append(s.toIgnite, ignition{Entity: e, Spread: true})
+			}
+		}
+	}
+}
+
+// protection returns the protection against fire of the land use at the given origin, between 0 and 1.
+// Neighboring water and fire protection buildings in range protect.
+func (s *Fire) protection(origin image.Point) float64 {
+	rules := s.rules.Get()
+	rect := s.landUse.Get().Footprint(origin.X, origin.Y)
+	water := s.terrain.Get().CountNeighborsRect8(rect, s.water)
+	protection := float64(water) * rules.FireWaterProtection
+
+	query := s.protectionFilter.Query()
+	for query.Next() {
+		tile, prot := query.Get()
+		r := int(prot.Radius)
+		if sqDist(tile.Point, origin.X, origin.Y) <= r*r {
+			protection += rules.FireStationProtection
+			query.Close()
+			break
+		}
+	}
+	return min(protection, 1)
+}
+
+// report shows a fire event in the status label, and adds it to the event log.
+func (s *Fire) report(time int64, message string) {
+	s.hud.Get().SetStatusLabel(message)
+	s.eventLog.Get().Add(time, message)
+}
@@ -16,9 +16,15 @@ type GameControls struct {
 	SlowerKey     rune
 	FasterKey     rune
 	FullscreenKey ebiten.Key
+	SaveKey       ebiten.Key
+	TrafficKey    ebiten.Key
 
+	view      ecs.Resource[res.View]
 	speed     ecs.Resource[res.GameSpeed]
+	time      ecs.Resource[res.GameTick]
 	update    ecs.Resource[res.UpdateInterval]
+	saveEvent ecs.Resource[res.SaveEvent]
+	replay    ecs.Resource[res.Replay]
 	prevSpeed int8
 
 	inputChars []rune
@@ -26,8 +32,12 @@ type GameControls struct {
 
 // Initialize the system
 func (s *GameControls) Initialize(world *ecs.World) {
+	s.view = ecs.NewResource[res.View](world)
 	s.speed = ecs.NewResource[res.GameSpeed](world)
+	s.time = ecs.NewResource[res.GameTick](world)
 	s.update = ecs.NewResource[res.UpdateInterval](world)
+	s.saveEvent = ecs.NewResource[res.SaveEvent](world)
+	s.replay = ecs.NewResource[res.Replay](world)
 
 	speed := s.speed.Get()
 	update := s.update.Get()
@@ -39,6 +49,7 @@ func (s *GameControls) Initialize(world *ecs.World) {
 func (s *GameControls) Update(world *ecs.World) {
 	speed := s.speed.Get()
 	update := s.update.Get()
+	pause, gameSpeed := speed.Pause, speed.Speed
 
 	if inpututil.IsKeyJustPressed(s.FullscreenKey) {
 		ebiten.SetFullscreen(!ebiten.IsFullscreen())
@@ -46,6 +57,18 @@ func (s *GameControls) Update(world *ecs.World) {
 	if inpututil.IsKeyJustPressed(s.PauseKey) {
 		speed.Pause = !speed.Pause
 	}
+	if inpututil.IsKeyJustPressed(s.TrafficKey) {
+		view := s.view.Get()
+		view.ShowTraffic = !view.ShowTraffic
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyControl) && inpututil.IsKeyJustPressed(s.SaveKey) {
+		evt := s.saveEvent.Get()
+		if ebiten.IsKeyPressed(ebiten.KeyShift) {
+			evt.ShouldSaveMap = true
+		} else {
+			evt.ShouldSave = true
+		}
+	}
 
 	s.inputChars = ebiten.AppendInputChars(s.inputChars)
 
@@ -58,6 +81,10 @@ func (s *GameControls) Update(world *ecs.World) {
 
 	s.inputChars = s.inputChars[:0]
 
+	if speed.Pause != pause || speed.Speed != gameSpeed {
+		s.replay.Get().RecordSpeed(s.time.Get(), speed)
+	}
+
 	if s.prevSpeed != speed.Speed {
 		ebiten.SetTPS(int(math.Pow(2, float64(speed.Speed)) * float64(update.Interval)))
 		s.prevSpeed = speed.Speed
@@ -0,0 +1,161 @@
+package sys
+
+import (
+	"image"
+	stdmath "math"
+	"math/rand/v2"
+	"slices"
+
+	"github.com/mlange-42/ark/ecs"
+	"github.com/mlange-42/tiny-world/game/math"
+	"github.com/mlange-42/tiny-world/game/res"
+	"github.com/mlange-42/tiny-world/game/terr"
+)
+
+// Typical size of generated landscape features, in tiles.
+const featureSize = 12.0
+
+// Radius around the start tile that is kept free of trees and rocks.
+const clearRadius = 2
+
+// generatedTile is a tile of a generated world, with its noise values.
+type generatedTile struct {
+	Pos       image.Point
+	Elevation float64
+	Moisture  float64
+	Forest    float64
+	Terrain   terr.Terrain
+	LandUse   terr.Terrain
+}
+
+// generateTerrain lays out terrain and natural land use around the center of the world,
+// from noise fields. Tiles within the start radius around the center are never water,
+// so that the start area is connected.
+func generateTerrain(world *ecs.World, gen *res.WorldGen, startRadius int) {
+	terrain := ecs.GetResource[res.Terrain](world)
+	fac := ecs.GetResource[res.EntityFactory](world)
+
+	plains := terr.ToTerrain("plains")
+	hills := terr.ToTerrain("hills")
+	water := terr.ToTerrain("water")
+	desert := terr.ToTerrain("desert")
+	tree := terr.ToTerrain("tree")
+	rock := terr.ToTerrain("rock")
+
+	elevation := math.NewNoise(gen.Seed)
+	moisture := math.NewNoise(gen.Seed + 1)
+	forest := math.NewNoise(gen.Seed + 2)
+	shape := math.NewNoise(gen.Seed + 3)
+	rng := rand.New(rand.NewPCG(gen.Seed, 4))
+
+	cx, cy := terrain.Width()/2, terrain.Height()/2
+	radius := float64(math.MinInt(gen.Size/2, math.MinInt(cx, cy)-2))
+	minRadius := float64(startRadius + 1)
+
+	tiles := []generatedTile{}
+	r := int(radius)
+	for x := cx - r; x <= cx+r; x++ {
+		for y := cy - r; y <= cy+r; y++ {
+			fx, fy := float64(x)/featureSize, float64(y)/featureSize
+			dx, dy := float64(x-cx), float64(y-cy)
+			dist := stdmath.Sqrt(dx*dx + dy*dy)
+			// Irregular coast line, between 70% and 100% of the radius.
+			if dist > minRadius && dist > radius*(1-0.3*shape.Fractal(fx, fy, 3)) {
+				continue
+			}
+			tiles = append(tiles, generatedTile{
+				Pos:       image.Pt(x, y),
+				Elevation: elevation.Fractal(fx, fy, 4),
+				Moisture:  moisture.Fractal(fx, fy, 3),
+				Forest:    forest.Fractal(fx, fy, 3),
+				Terrain:   plains,
+				LandUse:   terr.Air,
+			})
+		}
+	}
+
+	// Thresholds from quantiles, so that the fractions of terrains match the parameters.
+	// The water level ignores the start area, as it is never flooded.
+	elev := make([]float64, 0, len(tiles))
+	outer := make([]float64, 0, len(tiles))
+	for i := range tiles {
+		elev = append(elev, tiles[i].Elevation)
+		if sqDist(tiles[i].Pos, cx, cy) > startRadius*startRadius {
+			outer = append(outer, tiles[i].Elevation)
+		}
+	}
+	waterLevel := quantile(outer, gen.Water*float64(len(elev))/float64(math.MaxInt(len(outer), 1)))
+	hillLevel := quantile(elev, 1-gen.Hills)
+
+	moist := []float64{}
+	for i := range tiles {
+		if e := tiles[i].Elevation; e >= waterLevel && e < hillLevel {
+			moist = append(moist, tiles[i].Moisture)
+		}
+	}
+	desertLevel := 0.0
+	if len(moist) > 0 {
+		desertLevel = quantile(moist, gen.Desert*float64(len(tiles))/float64(len(moist)))
+	}
+
+	forestValues := []float64{}
+	for i := range tiles {
+		t := &tiles[i]
+		d2 := sqDist(t.Pos, cx, cy)
+		switch {
+		case t.Elevation < waterLevel && d2 > startRadius*startRadius:
+			t.Terrain = water
+		case t.Elevation >= hillLevel:
+			t.Terrain = hills
+		case t.Moisture < desertLevel:
+			t.Terrain = desert
+		}
+		if t.Terrain == plains || t.Terrain == hills {
+			forestValues = append(forestValues, t.Forest)
+		}
+	}
+	forestLevel := quantile(forestValues, 1-gen.Forest)
+
+	for i := range tiles {
+		t := &tiles[i]
+		if t.Terrain == water || sqDist(t.Pos, cx, cy) <= clearRadius*clearRadius {
+			continue
+		}
+		isGreen := t.Terrain == plains || t.Terrain == hills
+		if isGreen && t.Forest >= forestLevel {
+			t.LandUse = tree
+		} else if rng.Float64() < gen.Rocks {
+			t.LandUse = rock
+		}
+	}
+
+	for i := range tiles {
+		t := &tiles[i]
+		fac.Set(world, t.Pos.X, t.Pos.Y, t.Terrain, 0, true)
+		if t.LandUse != terr.Air {
+			fac.Set(world, t.Pos.X, t.Pos.Y, t.LandUse, 0, true)
+		}
+	}
+}
+
+// quantile returns the value below which the given fraction of values lie.
+// Sorts the values in place.
+func quantile(values []float64, fraction float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	slices.Sort(values)
+	if fraction <= 0 {
+		return stdmath.Inf(-1)
+	}
+	if fraction >= 1 {
+		return stdmath.Inf(1)
+	}
+	return values[int(fraction*float64(len(values)))]
+}
+
+// sqDist returns the squared distance of a tile from a position.
+func sqDist(p image.Point, x, y int) int {
+	dx, dy := p.X-x, p.Y-y
+	return dx*dx + dy*dy
+}
@@ -0,0 +1,97 @@
+package sys
+
+import (
+	"image"
+
+	"github.com/mlange-42/ark/ecs"
+	"github.com/mlange-42/tiny-world/game/comp"
+	"github.com/mlange-42/tiny-world/game/res"
+	"github.com/mlange-42/tiny-world/game/terr"
+)
+
+// GrowNature system.
+// Lets trees seed onto adjacent free plains.
+type GrowNature struct {
+	speed   ecs.Resource[res.GameSpeed]
+	time    ecs.Resource[res.GameTick]
+	update  ecs.Resource[res.UpdateInterval]
+	rules   ecs.Resource[res.Rules]
+	rand    ecs.Resource[res.Rand]
+	terrain ecs.Resource[res.Terrain]
+	landUse ecs.Resource[res.LandUse]
+	factory ecs.Resource[res.EntityFactory]
+	editor  ecs.Resource[res.EditorMode]
+
+	filter *ecs.Filter3[comp.Terrain, comp.Tile, comp.UpdateTick]
+
+	tree   terr.Terrain
+	plains terr.Terrain
+
+	toCreate []image.Point
+}
+
+// Initialize the system
+func (s *GrowNature) Initialize(world *ecs.World) {
+	s.speed = ecs.NewResource[res.GameSpeed](world)
+	s.time = ecs.NewResource[res.GameTick](world)
+	s.update = ecs.NewResource[res.UpdateInterval](world)
+	s.rules = ecs.NewResource[res.Rules](world)
+	s.rand = ecs.NewResource[res.Rand](world)
+	s.terrain = ecs.NewResource[res.Terrain](world)
+	s.landUse = ecs.NewResource[res.LandUse](world)
+	s.factory = ecs.NewResource[res.EntityFactory](world)
+	s.editor = ecs.NewResource[res.EditorMode](world)
+
+	s.filter = s.filter.New(world)
+
+	s.tree = terr.ToTerrain("tree")
+	s.plains = terr.ToTerrain("plains")
+}
+
+// Update the system
+func (s *GrowNature) Update(world *ecs.World) {
+	growth := s.rules.Get().TreeGrowth
+	if growth <= 0 || s.speed.Get().Pause || s.editor.Get().IsEditor {
+		return
+	}
+
+	rng := s.rand.Get()
+	terrain := s.terrain.Get()
+	landUse := s.landUse.Get()
+	tickMod := s.time.Get().Tick % s.update.Get().Interval
+
+	query := s.filter.Query()
+	for query.Next() {
+		ter, tile, up := query.Get()
+		if up.Tick != tickMod || ter.Terrain != s.tree {
+			continue
+		}
+
+		// Random cell of the 3x3 neighborhood, skipping the center.
+		cell := rng.IntN(8)
+		if cell >= 4 {
+			cell++
+		}
+		x, y := tile.X+cell%3-1, tile.Y+cell/3-1
+		if !terrain.Contains(x, y) || terrain.Get(x, y) != s.plains || landUse.Get(x, y) != terr.Air {
+			continue
+		}
+		// Denser forests spread faster.
+		trees := landUse.CountNeighbors8(x, y, s.tree)
+		if rng.Float64() < growth*float64(trees)/8 {
+			s.toCreate = append(s.toCreate, image.Pt(x, y))
+		}
+	}
+
+	fac := s.factory.Get()
+	for _, p := range s.toCreate {
+		// Several trees may have seeded onto the same tile.
+		if landUse.Get(p.X, p.Y) == terr.Air {
+			fac.Set(world, p.X, p.Y, s.tree, 0, true)
+		}
+	}
+	s.toCreate = s.toCreate[:0]
+}
+
+// Finalize the system
+func (s *GrowNature) Finalize(world *ecs.World) {}
@@ -0,0 +1,179 @@
+package sys
+
+import (
+	"encoding/binary"
+	"hash"
+	"hash/fnv"
+	"slices"
+
+	"github.com/mlange-42/ark/ecs"
+	"github.com/mlange-42/tiny-world/game/comp"
+	"github.com/mlange-42/tiny-world/game/res"
+)
+
+// StateHash computes a hash of the simulation state,
+// covering terrain, land use, production with input buffers, consumption, haulers,
+// warehouse inventories and the global stock.
+//
+// The hash does not depend on entity IDs or query order.
+// It can be used to compare runs, to detect non-determinism and to check save/load round trips.
+func StateHash(world *ecs.World) uint64 {
+	h := newStateHasher()
+
+	terrain := ecs.GetResource[res.Terrain](world)
+	h.int(terrain.Width(), terrain.Height())
+	for x := 0; x < terrain.Width(); x++ {
+		for y := 0; y < terrain.Height(); y++ {
+			h.int(int(terrain.Get(x, y)))
+		}
+	}
+
+	landUse := ecs.GetResource[res.LandUse](world)
+	h.int(landUse.Width(), landUse.Height())
+	for x := 0; x < landUse.Width(); x++ {
+		for y := 0; y < landUse.Height(); y++ {
+			h.int(int(landUse.Get(x, y)))
+		}
+	}
+
+	stock := ecs.GetResource[res.Stock](world)
+	h.int(stock.Cap...)
+	h.int(stock.Res...)
+	h.int(stock.Total...)
+	h.int(stock.Population, stock.MaxPopulation)
+
+	// Entities are hashed individually, and the sorted entity hashes are combined.
+	entity := newStateHasher()
+	hashes := []uint64{}
+
+	inputMap := ecs.NewMap[comp.InputBuffer](world)
+	prodFilter := ecs.NewFilter2[comp.Tile, comp.Production](world)
+	prodQuery := prodFilter.Query()
+	for prodQuery.Next() {
+		tile, prod := prodQuery.Get()
+		entity.reset()
+		entity.int(tile.X, tile.Y)
+		entity.int(int(prod.Resource), int(prod.Amount), int(prod.Stock), prod.Countdown)
+		entity.bool(prod.IsHauling, prod.HasRequired)
+		if inputMap.Has(prodQuery.Entity()) {
+			for _, st := range inputMap.Get(prodQuery.Entity()).Stock {
+				entity.int(int(st))
+			}
+		}
+		hashes = append(hashes, entity.sum())
+	}
+	h.sorted(hashes)
+
+	hashes = hashes[:0]
+	supplyMap := ecs.NewMap[comp.Supply](world)
+	consFilter := ecs.NewFilter2[comp.Tile, comp.Consumption](world)
+	consQuery := consFilter.Query()
+	for consQuery.Next() {
+		tile, cons := consQuery.Get()
+		entity.reset()
+		entity.int(tile.X, tile.Y, len(cons.Amount))
+		for i := range cons.Amount {
+			entity.int(int(cons.Amount[i]), int(cons.Countdown[i]))
+		}
+		entity.bool(cons.IsSatisfied)
+		if supplyMap.Has(consQuery.Entity()) {
+			supply := supplyMap.Get(consQuery.Entity())
+			for i := range supply.Stock {
+				entity.int(int(supply.Stock[i]))
+				entity.bool(supply.Requested[i])
+			}
+		}
+		hashes = append(hashes, entity.sum())
+	}
+	h.sorted(hashes)
+
+	hashes = hashes[:0]
+	whFilter := ecs.NewFilter2[comp.Tile, comp.Warehouse](world)
+	whQuery := whFilter.Query()
+	for whQuery.Next() {
+		tile, wh := whQuery.Get()
+		entity.reset()
+		entity.int(tile.X, tile.Y)
+		entity.int(wh.Stock...)
+		hashes = append(hashes, entity.sum())
+	}
+	h.sorted(hashes)
+
+	hashes = hashes[:0]
+	tileMap := ecs.NewMap[comp.Tile](world)
+	deliveryMap := ecs.NewMap[comp.Delivery](world)
+	haulFilter := ecs.NewFilter2[comp.Tile, comp.Hauler](world)
+	haulQuery := haulFilter.Query()
+	for haulQuery.Next() {
+		tile, haul := haulQuery.Get()
+		entity.reset()
+		entity.int(tile.X, tile.Y, int(haul.Hauls))
+		// The home entity is identified by its tile, as entity IDs change on save and load.
+		if world.Alive(haul.Home) && tileMap.Has(haul.Home) {
+			home := tileMap.Get(haul.Home)
+			entity.int(home.X, home.Y)
+		} else {
+			entity.int(-1, -1)
+		}
+		entity.int(len(haul.Path))
+		for _, p := range haul.Path {
+			entity.int(p.X, p.Y)
+		}
+		entity.int(haul.Index, int(haul.PathFraction), int(haul.Progress))
+		if deliveryMap.Has(haulQuery.Entity()) {
+			entity.int(int(deliveryMap.Get(haulQuery.Entity()).Amount))
+		}
+		hashes = append(hashes, entity.sum())
+	}
+	h.sorted(hashes)
+
+	return h.sum()
+}
+
+// stateHasher writes values to a 64-bit FNV-1a hash.
+type stateHasher struct {
+	hash hash.Hash64
+	buf  [8]byte
+}
+
+func newStateHasher() stateHasher {
+	return stateHasher{hash: fnv.New64a()}
+}
+
+func (h *stateHasher) reset() {
+	h.hash.Reset()
+}
+
+func (h *stateHasher) sum() uint64 {
+	return h.hash.Sum64()
+}
+
+func (h *stateHasher) uint(values ...uint64) {
+	for _, v := range values {
+		binary.LittleEndian.PutUint64(h.buf[:], v)
+		h.hash.Write(h.buf[:])
+	}
+}
+
+func (h *stateHasher) int(values ...int) {
+	for _, v := range values {
+		h.uint(uint64(v))
+	}
+}
+
+func (h *stateHasher) bool(values ...bool) {
+	for _, v := range values {
+		if v {
+			h.uint(1)
+		} else {
+			h.uint(0)
+		}
+	}
+}
+
+// sorted writes the number of hashes and the hashes in ascending order.
+func (h *stateHasher) sorted(hashes []uint64) {
+	slices.Sort(hashes)
+	h.int(len(hashes))
+	h.uint(hashes...)
+}
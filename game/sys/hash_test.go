@@ -0,0 +1,149 @@
+package sys
+
+import (
+	"image"
+	"os"
+	"testing"
+
+	"github.com/mlange-42/ark/ecs"
+	"github.com/mlange-42/tiny-world/game/comp"
+	"github.com/mlange-42/tiny-world/game/res"
+	"github.com/mlange-42/tiny-world/game/resource"
+	"github.com/mlange-42/tiny-world/game/terr"
+)
+
+func TestMain(m *testing.M) {
+	data := os.DirFS("../..")
+	resource.Prepare(data, "data/json/resources.json")
+	terr.Prepare(data, "data/json/terrain.json")
+	os.Exit(m.Run())
+}
+
+// hashState is the state of a small world with a farm, two houses and a hauler.
+type hashState struct {
+	Farm, Hauler comp.Tile
+	Houses       []comp.Tile
+	Production   comp.Production
+	Consumption  comp.Consumption
+	Path         []comp.Tile
+	Stock        res.Stock
+	LandUse      map[image.Point]terr.Terrain
+}
+
+func newHashState() hashState {
+	return hashState{
+		Farm:        comp.Tile{Point: image.Pt(1, 1)},
+		Houses:      []comp.Tile{{Point: image.Pt(3, 1)}, {Point: image.Pt(3, 2)}},
+		Hauler:      comp.Tile{Point: image.Pt(2, 1)},
+		Production:  comp.Production{Resource: 0, Amount: 1, Stock: 2, Countdown: 30},
+		Consumption: comp.Consumption{Amount: []uint8{1, 0, 0}, Countdown: []int16{10, 0, 0}, IsSatisfied: true},
+		Path:        []comp.Tile{{Point: image.Pt(1, 1)}, {Point: image.Pt(2, 1)}, {Point: image.Pt(3, 1)}},
+		Stock:       res.Stock{Cap: []int{10, 10, 10}, Res: []int{1, 2, 3}, Total: []int{4, 5, 6}, Population: 2, MaxPopulation: 5},
+		LandUse:     map[image.Point]terr.Terrain{{1, 1}: 1, {2, 1}: 2, {3, 1}: 3, {3, 2}: 3},
+	}
+}
+
+// world creates a world with the state.
+// If reversed, entities are created in reverse order, and get other IDs.
+// The hauler's home is the farm.
+func (s *hashState) world(reversed bool) *ecs.World {
+	world := ecs.NewWorld()
+
+	terrain := res.NewTerrain(5, 3)
+	landUse := res.NewLandUse(5, 3)
+	for p, lu := range s.LandUse {
+		terrain.Set(p.X, p.Y, 1)
+		landUse.Set(p.X, p.Y, lu)
+	}
+	stock := s.Stock
+	ecs.AddResource(&world, &terrain)
+	ecs.AddResource(&world, &landUse)
+	ecs.AddResource(&world, &stock)
+
+	if reversed {
+		world.RemoveEntity(world.NewEntity())
+	}
+	farmMap := ecs.NewMap2[comp.Tile, comp.Production](&world)
+	houseMap := ecs.NewMap2[comp.Tile, comp.Consumption](&world)
+	haulerMap := ecs.NewMap2[comp.Tile, comp.Hauler](&world)
+
+	var farm ecs.Entity
+	createFarm := func() {
+		tile, prod := s.Farm, s.Production
+		farm = farmMap.NewEntity(&tile, &prod)
+	}
+	createHouses := func() {
+		for i := range s.Houses {
+			if reversed {
+				i = len(s.Houses) - i - 1
+			}
+			tile, cons := s.Houses[i], s.Consumption
+			houseMap.NewEntity(&tile, &cons)
+		}
+	}
+	if reversed {
+		createHouses()
+		createFarm()
+	} else {
+		createFarm()
+		createHouses()
+	}
+	tile, hauler := s.Hauler, comp.Hauler{Home: farm, Path: s.Path, Index: 1, PathFraction: 4}
+	haulerMap.NewEntity(&tile, &hauler)
+
+	return &world
+}
+
+func TestStateHash(t *testing.T) {
+	base := newHashState()
+	hash := StateHash(base.world(false))
+
+	tests := []struct {
+		name    string
+		edit    func(s *hashState)
+		changed bool
+	}{
+		{
+			name:    "unchanged",
+			edit:    func(s *hashState) {},
+			changed: false,
+		},
+		{
+			name:    "land use",
+			edit:    func(s *hashState) { s.LandUse[image.Pt(2, 1)] = 3 },
+			changed: true,
+		},
+		{
+			name:    "stock",
+			edit:    func(s *hashState) { s.Stock.Res = []int{1, 3, 2} },
+			changed: true,
+		},
+		{
+			name:    "production countdown",
+			edit:    func(s *hashState) { s.Production.Countdown-- },
+			changed: true,
+		},
+		{
+			name:    "consumption",
+			edit:    func(s *hashState) { s.Consumption.IsSatisfied = false },
+			changed: true,
+		},
+		{
+			name:    "hauler position",
+			edit:    func(s *hashState) { s.Hauler = s.Houses[0] },
+			changed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newHashState()
+			tt.edit(&s)
+			for _, reversed := range []bool{false, true} {
+				if got := StateHash(s.world(reversed)); (got != hash) != tt.changed {
+					t.Errorf("StateHash() with reversed entities %v changed = %v, want %v", reversed, got != hash, tt.changed)
+				}
+			}
+		})
+	}
+}
@@ -14,22 +14,26 @@ import (
 
 // Haul system.
 type Haul struct {
+	rules    ecs.Resource[res.Rules]
 	speed    ecs.Resource[res.GameSpeed]
 	update   ecs.Resource[res.UpdateInterval]
 	stock    ecs.Resource[res.Stock]
 	landUse  ecs.Resource[res.LandUse]
 	landUseE ecs.Resource[res.LandUseEntities]
+	network  ecs.Resource[nav.Network]
+	traffic  ecs.Resource[res.Traffic]
 
 	prodFilter      *ecs.Filter3[comp.Tile, comp.Terrain, comp.Production]
-	warehouseFilter *ecs.Filter2[comp.Tile, comp.Terrain]
+	warehouseFilter *ecs.Filter3[comp.Tile, comp.Terrain, comp.Warehouse]
 	filter          *ecs.Filter2[comp.Tile, comp.Hauler]
 
 	haulerMap     *ecs.Map2[comp.Tile, comp.Hauler]
 	homeMap       *ecs.Map3[comp.Tile, comp.Terrain, comp.Production]
 	haulerBuilder *ecs.Map3[comp.Tile, comp.Hauler, comp.HaulerSprite]
 	productionMap *ecs.Map2[comp.Terrain, comp.Production]
-
-	aStar nav.AStar
+	warehouseMap  *ecs.Map1[comp.Warehouse]
+	deliveryMap   *ecs.Map1[comp.Delivery]
+	supplyMap     *ecs.Map1[comp.Supply]
 
 	warehouses [][]comp.Tile
 	toCreate   []markerEntry
@@ -40,29 +44,35 @@ type Haul struct {
 
 // Initialize the system
 func (s *Haul) Initialize(world *ecs.World) {
+	s.rules = ecs.NewResource[res.Rules](world)
 	s.speed = ecs.NewResource[res.GameSpeed](world)
 	s.update = ecs.NewResource[res.UpdateInterval](world)
 	s.stock = ecs.NewResource[res.Stock](world)
 	s.landUse = ecs.NewResource[res.LandUse](world)
 	s.landUseE = ecs.NewResource[res.LandUseEntities](world)
+	s.network = ecs.NewResource[nav.Network](world)
+	s.traffic = ecs.NewResource[res.Traffic](world)
 
 	s.prodFilter = s.prodFilter.New(world)
-	s.warehouseFilter = s.warehouseFilter.New(world).With(ecs.C[comp.Warehouse]())
+	s.warehouseFilter = s.warehouseFilter.New(world)
 	s.filter = s.filter.New(world)
 
 	s.haulerMap = s.haulerMap.New(world)
 	s.homeMap = s.homeMap.New(world)
 	s.haulerBuilder = s.haulerBuilder.New(world)
 	s.productionMap = s.productionMap.New(world)
+	s.warehouseMap = s.warehouseMap.New(world)
+	s.deliveryMap = s.deliveryMap.New(world)
+	s.supplyMap = s.supplyMap.New(world)
 
-	s.aStar = nav.NewAStar(s.landUse.Get())
-
-	spritesRes := ecs.NewResource[res.Sprites](world)
-	spr := spritesRes.Get()
-
+	// Sprites are not available when running headless.
 	s.haulerSprites = make([]int, len(terr.Properties))
-	for i := range terr.Properties {
-		s.haulerSprites[i] = spr.GetIndex(sprites.HaulerPrefix + terr.Properties[i].Name)
+	spritesRes := ecs.NewResource[res.Sprites](world)
+	if spritesRes.Has() {
+		spr := spritesRes.Get()
+		for i := range terr.Properties {
+			s.haulerSprites[i] = spr.GetIndex(sprites.HaulerPrefix + terr.Properties[i].Name)
+		}
 	}
 
 	s.warehouses = make([][]comp.Tile, len(resource.Properties))
@@ -78,6 +88,15 @@ func (s *Haul) Update(world *ecs.World) {
 	landUse := s.landUse.Get()
 	landUseE := s.landUseE.Get()
 	stock := s.stock.Get()
+	network := s.network.Get()
+	rules := s.rules.Get()
+	isLocal := rules.LocalStock
+
+	// Haulers on tiles over capacity are slowed down.
+	var load *res.Grid[uint8]
+	if rules.Congestion {
+		load = &s.traffic.Get().Load
+	}
 
 	prodQuery := s.prodFilter.Query()
 	for prodQuery.Next() {
@@ -92,7 +111,7 @@ func (s *Haul) Update(world *ecs.World) {
 	for query.Next() {
 		tile, haul := query.Get()
 
-		haul.PathFraction++
+		advance(haul, landUse, load)
 		if haul.Index <= 1 && haul.PathFraction >= uint8(update.Interval-1) {
 			s.arrived = append(s.arrived, query.Entity())
 			continue
@@ -101,7 +120,7 @@ func (s *Haul) Update(world *ecs.World) {
 		if haul.PathFraction < uint8(update.Interval) {
 			continue
 		}
-		haul.PathFraction = 0
+		haul.PathFraction -= uint8(update.Interval)
 
 		haul.Index--
 		last := haul.Path[haul.Index]
@@ -111,10 +130,11 @@ func (s *Haul) Update(world *ecs.World) {
 	if len(s.toCreate) > 0 {
 		query := s.warehouseFilter.Query()
 		for query.Next() {
-			tile, ter := query.Get()
+			tile, ter, wh := query.Get()
 			storage := terr.Properties[ter.Terrain].Storage
 			for i, st := range storage {
-				if st > 0 {
+				// In local stock mode, haulers only go to warehouses with free space.
+				if st > 0 && (!isLocal || wh.Stock[i] < int(st)) {
 					s.warehouses[i] = append(s.warehouses[i], *tile)
 				}
 			}
@@ -123,11 +143,11 @@ func (s *Haul) Update(world *ecs.World) {
 
 	for _, entry := range s.toCreate {
 		var bestPath []comp.Tile
-		bestPathLen := math.MaxInt
+		bestPathCost := math.MaxInt
 		for _, tile := range s.warehouses[entry.Resource] {
-			if path, ok := s.aStar.FindPath(entry.Tile, tile); ok {
-				if len(path) < bestPathLen {
-					bestPathLen = len(path)
+			if path, cost, ok := network.Route(entry.Tile, tile); ok {
+				if cost < bestPathCost {
+					bestPathCost = cost
 					bestPath = path
 				}
 			}
@@ -162,14 +182,22 @@ func (s *Haul) Update(world *ecs.World) {
 			world.RemoveEntity(e)
 			continue
 		}
+		if s.deliveryMap.HasAll(e) {
+			s.arriveDelivery(world, e)
+			continue
+		}
 		target := haul.Path[0]
 
 		home, tp, prod := s.homeMap.Get(haul.Home)
 		if terr.Properties[landUse.Get(target.X, target.Y)].TerrainBits.Contains(terr.IsWarehouse) {
 			amount := int(terr.Properties[tp.Terrain].Production.HaulCapacity)
-			stock.AddResources(haul.Hauls, amount)
+			if isLocal {
+				stock.AddResourcesTo(s.warehouseMap.Get(landUseE.Get(target.X, target.Y)), haul.Hauls, amount)
+			} else {
+				stock.AddResources(haul.Hauls, amount)
+			}
 
-			path, ok := s.aStar.FindPath(target, *home)
+			path, ok := network.FindPath(target, *home)
 			if !ok {
 				prod.IsHauling = false
 				world.RemoveEntity(e)
@@ -197,3 +225,45 @@ func (s *Haul) Update(world *ecs.World) {
 
 // Finalize the system
 func (s *Haul) Finalize(world *ecs.World) {}
+
+// advance moves a hauler along its path.
+// The speed depends on the move costs of the tiles of the current step, and on their load with congestion.
+// With the default move cost, PathFraction increases by one per tick.
+func advance(haul *comp.Hauler, landUse *res.LandUse, load *res.Grid[uint8]) {
+	cost := 2 * int(terr.DefaultMoveCost)
+	if haul.Index > 0 {
+		cost = nav.StepCost(landUse, load, haul.Path[haul.Index], haul.Path[haul.Index-1])
+	}
+	haul.Progress += uint16(2 * terr.DefaultMoveCost)
+	for int(haul.Progress) >= cost {
+		haul.Progress -= uint16(cost)
+		haul.PathFraction++
+	}
+}
+
+// arriveDelivery handles a delivery hauler that arrived at the consumer, or back at the warehouse.
+func (s *Haul) arriveDelivery(world *ecs.World, e ecs.Entity) {
+	tile, haul := s.haulerMap.Get(e)
+	delivery := s.deliveryMap.Get(e)
+
+	if delivery.Amount == 0 || !s.supplyMap.HasAll(haul.Home) {
+		world.RemoveEntity(e)
+		return
+	}
+
+	supply := s.supplyMap.Get(haul.Home)
+	supply.Stock[haul.Hauls] = uint8(min(int(supply.Stock[haul.Hauls])+int(delivery.Amount), math.MaxUint8))
+	supply.Requested[haul.Hauls] = false
+	delivery.Amount = 0
+
+	target := haul.Path[0]
+	path, ok := s.network.Get().FindPath(target, haul.Path[len(haul.Path)-1])
+	if !ok {
+		world.RemoveEntity(e)
+		return
+	}
+	haul.Path = path
+	haul.Index = len(path) - 1
+	haul.PathFraction = uint8(s.update.Get().Interval/2) + 1
+	*tile = target
+}
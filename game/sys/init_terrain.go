@@ -5,6 +5,7 @@ import (
 
 	"github.com/mlange-42/ark/ecs"
 	"github.com/mlange-42/tiny-world/game/comp"
+	"github.com/mlange-42/tiny-world/game/nav"
 	"github.com/mlange-42/tiny-world/game/res"
 	"github.com/mlange-42/tiny-world/game/terr"
 )
@@ -26,12 +27,39 @@ func (s *InitTerrain) Initialize(world *ecs.World) {
 	bounds.Min = image.Pt(x-1, y-1)
 	bounds.Max = image.Pt(x+1, y+1)
 
+	if gen := ecs.GetResource[res.WorldGen](world); gen.IsEnabled() {
+		generateTerrain(world, gen, rules.InitialBuildRadius)
+	}
+
 	fac.Set(world, x, y, terr.Default, 0, true)
 
 	warehouse := fac.Set(world, x, y, terr.FirstBuilding, 0, true)
 	radiusMapper.Add(warehouse, &comp.BuildRadius{Radius: uint8(rules.InitialBuildRadius)})
 
 	fac.SetBuildable(x, y, rules.InitialBuildRadius, true)
+
+	if rules.LocalStock {
+		fillWarehouses(world, ecs.GetResource[res.Stock](world))
+	}
+
+	initRuleResources(world)
+}
+
+// initRuleResources builds the resources that depend on the rules.
+// Init systems call it when the rules are final,
+// i.e. after applying the rules of a map or a loaded game.
+func initRuleResources(world *ecs.World) {
+	rules := ecs.GetResource[res.Rules](world)
+
+	var traffic *res.Traffic
+	if rules.Congestion {
+		traffic = ecs.GetResource[res.Traffic](world)
+	}
+	network := ecs.GetResource[nav.Network](world)
+	*network = nav.NewNetwork(ecs.GetResource[res.LandUse](world), ecs.GetResource[res.PathVersion](world), traffic)
+
+	history := ecs.GetResource[res.History](world)
+	*history = res.History{Limit: rules.UndoSteps}
 }
 
 // Update the system
@@ -1,9 +1,13 @@
 package sys
 
 import (
+	"image"
+	"log"
+
 	"github.com/mlange-42/ark/ecs"
 	"github.com/mlange-42/tiny-world/game/comp"
 	"github.com/mlange-42/tiny-world/game/res"
+	"github.com/mlange-42/tiny-world/game/resource"
 	"github.com/mlange-42/tiny-world/game/terr"
 )
 
@@ -20,6 +24,7 @@ func (s *InitTerrainLoaded) Initialize(world *ecs.World) {
 	landUseE := ecs.GetResource[res.LandUseEntities](world)
 	fac := ecs.GetResource[res.EntityFactory](world)
 
+	multiTile := []ecs.Entity{}
 	filter := ecs.NewFilter2[comp.Tile, comp.Terrain](world)
 	query := filter.Query()
 	for query.Next() {
@@ -27,11 +32,16 @@ func (s *InitTerrainLoaded) Initialize(world *ecs.World) {
 		if terr.Properties[ter.Terrain].TerrainBits.Contains(terr.IsTerrain) {
 			terrain.Set(tile.X, tile.Y, ter.Terrain)
 			terrainE.Set(tile.X, tile.Y, query.Entity())
+		} else if terr.Properties[ter.Terrain].Footprint != image.Pt(1, 1) {
+			multiTile = append(multiTile, query.Entity())
 		} else {
 			landUse.Set(tile.X, tile.Y, ter.Terrain)
 			landUseE.Set(tile.X, tile.Y, query.Entity())
 		}
 	}
+	placeFootprints(world, multiTile)
+	// Land use was set without the factory, so the path network is invalidated explicitly.
+	ecs.GetResource[res.PathVersion](world).Version++
 
 	x, y := terrain.Width()/2, terrain.Height()/2
 	fac.SetBuildable(x, y, rules.InitialBuildRadius, true)
@@ -42,6 +52,142 @@ func (s *InitTerrainLoaded) Initialize(world *ecs.World) {
 		tile, rad := radQuery.Get()
 		fac.SetBuildable(tile.X, tile.Y, int(rad.Radius), true)
 	}
+
+	// Games saved without delivery have no input buffers for consumers.
+	if rules.Delivery {
+		addSupply(world)
+	}
+
+	// Games saved without local stock have no warehouse inventories.
+	if rules.LocalStock && !hasInventories(world) {
+		fillWarehouses(world, ecs.GetResource[res.Stock](world))
+	}
+
+	// Traffic is not saved, but derived from the haulers on paths.
+	if rules.Congestion {
+		countTraffic(world)
+	}
+
+	initRuleResources(world)
+}
+
+// placeFootprints places multi-tile buildings, after the terrain and all other land use.
+//
+// Games saved before a building type got its footprint have such buildings on a single cell.
+// They are moved to the first placement that covers this cell, on free cells if possible.
+// Otherwise, land use that is not a building is removed to make room.
+// Buildings that don't fit anywhere are removed.
+func placeFootprints(world *ecs.World, entities []ecs.Entity) {
+	landUse := ecs.GetResource[res.LandUse](world)
+	landUseE := ecs.GetResource[res.LandUseEntities](world)
+	mapper := ecs.NewMap2[comp.Tile, comp.Terrain](world)
+
+	for _, e := range entities {
+		tile, ter := mapper.Get(e)
+		cell, t := tile.Point, ter.Terrain
+
+		origin, ok := fitFootprint(world, cell, t, false)
+		if !ok {
+			origin, ok = fitFootprint(world, cell, t, true)
+		}
+		if !ok {
+			log.Printf("Removed %s at %d,%d, as it does not fit its footprint", terr.Properties[t].Name, cell.X, cell.Y)
+			world.RemoveEntity(e)
+			continue
+		}
+
+		rect := image.Rectangle{Min: origin, Max: origin.Add(terr.Properties[t].Footprint)}
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			for y := rect.Min.Y; y < rect.Max.Y; y++ {
+				if landUse.Get(x, y) != terr.Air {
+					world.RemoveEntity(landUseE.Get(x, y))
+					landUse.Set(x, y, terr.Air)
+				}
+			}
+		}
+		tile, _ = mapper.Get(e)
+		tile.Point = origin
+		landUse.SetFootprint(origin.X, origin.Y, t)
+		landUseE.SetFootprint(rect, e)
+	}
+}
+
+// fitFootprint finds the origin of a placement of a multi-tile building that covers the given cell.
+// Placements with the cell as origin come first.
+func fitFootprint(world *ecs.World, cell image.Point, t terr.Terrain, clear bool) (image.Point, bool) {
+	size := terr.Properties[t].Footprint
+	for dx := 0; dx < size.X; dx++ {
+		for dy := 0; dy < size.Y; dy++ {
+			origin := cell.Sub(image.Pt(dx, dy))
+			if footprintFits(world, origin, t, clear) {
+				return origin, true
+			}
+		}
+	}
+	return image.Point{}, false
+}
+
+// footprintFits checks whether all cells of a multi-tile building placed at the given origin
+// are on terrain the building can be built on, and free.
+// If clear is true, cells may be covered by land use that is not a building.
+func footprintFits(world *ecs.World, origin image.Point, t terr.Terrain, clear bool) bool {
+	terrain := ecs.GetResource[res.Terrain](world)
+	landUse := ecs.GetResource[res.LandUse](world)
+	props := &terr.Properties[t]
+
+	for x := origin.X; x < origin.X+props.Footprint.X; x++ {
+		for y := origin.Y; y < origin.Y+props.Footprint.Y; y++ {
+			if !terrain.Contains(x, y) || !props.BuildOn.Contains(terrain.Get(x, y)) {
+				return false
+			}
+			if lu := landUse.Get(x, y); lu != terr.Air && (!clear || terr.Buildings.Contains(lu)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// countTraffic sets the traffic load of all path tiles from their haulers.
+func countTraffic(world *ecs.World) {
+	traffic := ecs.GetResource[res.Traffic](world)
+	landUse := ecs.GetResource[res.LandUse](world)
+	filter := ecs.NewFilter2[comp.Tile, comp.Path](world)
+	query := filter.Query()
+	for query.Next() {
+		tile, path := query.Get()
+		load := trafficLoad(path)
+		traffic.Load.Set(tile.X, tile.Y, load)
+		traffic.Routing.Set(tile.X, tile.Y, routingLoad(load, landUse.Get(tile.X, tile.Y)))
+	}
+	traffic.Version++
+}
+
+// addSupply adds input buffers to consumers that have none.
+func addSupply(world *ecs.World) {
+	supplyMap := ecs.NewMap1[comp.Supply](world)
+	filter := ecs.NewFilter1[comp.Consumption](world).Without(ecs.C[comp.Supply]())
+	query := filter.Query()
+	entities := []ecs.Entity{}
+	for query.Next() {
+		entities = append(entities, query.Entity())
+	}
+	for _, e := range entities {
+		supplyMap.Add(e, res.NewSupply())
+	}
+}
+
+// hasInventories checks whether all warehouses have an inventory.
+func hasInventories(world *ecs.World) bool {
+	filter := ecs.NewFilter1[comp.Warehouse](world)
+	query := filter.Query()
+	for query.Next() {
+		if len(query.Get().Stock) != len(resource.Properties) {
+			query.Close()
+			return false
+		}
+	}
+	return true
 }
 
 // Update the system
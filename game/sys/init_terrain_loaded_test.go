@@ -0,0 +1,123 @@
+package sys
+
+import (
+	"image"
+	"testing"
+
+	"github.com/mlange-42/ark/ecs"
+	"github.com/mlange-42/tiny-world/game/comp"
+	"github.com/mlange-42/tiny-world/game/res"
+	"github.com/mlange-42/tiny-world/game/terr"
+)
+
+// Games saved before the castle covered 2x2 tiles have castles on a single cell.
+func TestPlaceFootprints(t *testing.T) {
+	castle := terr.ToTerrain("castle")
+	saved := image.Pt(1, 1)
+
+	tests := []struct {
+		name string
+		// Land use around the castle saved at 1,1, with '.' for empty cells, and '~' for water.
+		landUse []string
+		// Expected origin of the castle. Nil if it is removed.
+		origin *image.Point
+		// Expected number of removed entities other than the castle.
+		removed int
+	}{
+		{
+			name:    "free",
+			landUse: []string{"....", "....", "...."},
+			origin:  &image.Point{1, 1},
+		},
+		{
+			name:    "building next to it",
+			landUse: []string{"....", "..F.", "...."},
+			origin:  &image.Point{0, 1},
+		},
+		{
+			name:    "water next to it",
+			landUse: []string{"....", "....", ".~.."},
+			origin:  &image.Point{1, 0},
+		},
+		{
+			name:    "trees around",
+			landUse: []string{".T..", "T.T.", ".T.."},
+			origin:  &image.Point{1, 1},
+			removed: 2,
+		},
+		{
+			name:    "buildings around",
+			landUse: []string{".F..", "F.F.", ".F.."},
+		},
+	}
+
+	symbols := map[rune]string{'F': "farm", 'T': "tree"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			world := ecs.NewWorld()
+			width, height := len(tt.landUse[0]), len(tt.landUse)
+			terrain := res.NewTerrain(width, height)
+			landUse := res.NewLandUse(width, height)
+			landUseE := res.LandUseEntities{Grid: res.NewGrid[ecs.Entity](width, height)}
+			ecs.AddResource(&world, &terrain)
+			ecs.AddResource(&world, &landUse)
+			ecs.AddResource(&world, &landUseE)
+
+			builder := ecs.NewMap2[comp.Tile, comp.Terrain](&world)
+			others := []ecs.Entity{}
+			for y, row := range tt.landUse {
+				for x, sym := range row {
+					if sym == '~' {
+						terrain.Set(x, y, terr.ToTerrain("water"))
+						continue
+					}
+					terrain.Set(x, y, terr.ToTerrain("plains"))
+					if sym == '.' {
+						continue
+					}
+					lu := terr.ToTerrain(symbols[sym])
+					e := builder.NewEntity(&comp.Tile{Point: image.Pt(x, y)}, &comp.Terrain{Terrain: lu})
+					landUse.Set(x, y, lu)
+					landUseE.Set(x, y, e)
+					others = append(others, e)
+				}
+			}
+			e := builder.NewEntity(&comp.Tile{Point: saved}, &comp.Terrain{Terrain: castle})
+
+			placeFootprints(&world, []ecs.Entity{e})
+
+			if tt.origin == nil {
+				if world.Alive(e) {
+					t.Errorf("castle was not removed")
+				}
+				return
+			}
+			if !world.Alive(e) {
+				t.Fatalf("castle was removed")
+			}
+			if tile, _ := builder.Get(e); tile.Point != *tt.origin {
+				t.Errorf("castle has origin %v, want %v", tile.Point, *tt.origin)
+			}
+			rect := image.Rectangle{Min: *tt.origin, Max: tt.origin.Add(terr.Properties[castle].Footprint)}
+			if got := landUse.Footprint(saved.X, saved.Y); got != rect {
+				t.Errorf("Footprint() = %v, want %v", got, rect)
+			}
+			for x := rect.Min.X; x < rect.Max.X; x++ {
+				for y := rect.Min.Y; y < rect.Max.Y; y++ {
+					if landUse.Get(x, y) != castle || landUseE.Get(x, y) != e {
+						t.Errorf("cell %d,%d is not covered by the castle", x, y)
+					}
+				}
+			}
+			removed := 0
+			for _, o := range others {
+				if !world.Alive(o) {
+					removed++
+				}
+			}
+			if removed != tt.removed {
+				t.Errorf("%d other entities were removed, want %d", removed, tt.removed)
+			}
+		})
+	}
+}
@@ -1,7 +1,6 @@
 package sys
 
 import (
-	"fmt"
 	"image"
 	"io/fs"
 	"log"
@@ -24,6 +23,7 @@ type InitTerrainMap struct {
 func (s *InitTerrainMap) Initialize(world *ecs.World) {
 	rules := ecs.GetResource[res.Rules](world)
 	terrain := ecs.GetResource[res.Terrain](world)
+	landUse := ecs.GetResource[res.LandUse](world)
 	bounds := ecs.GetResource[res.WorldBounds](world)
 	fac := ecs.GetResource[res.EntityFactory](world)
 
@@ -32,42 +32,38 @@ func (s *InitTerrainMap) Initialize(world *ecs.World) {
 		log.Fatal("error reading map file: ", err.Error())
 	}
 
-	terrains := []terr.Terrain{}
-	for _, rn := range mapData.Terrains {
-		t, ok := terr.SymbolToTerrain[rn]
-		if !ok {
-			panic(fmt.Sprintf("unknown map symbol '%s'", string(rn)))
-		}
-		if t.LandUse != terr.Air {
-			terrains = append(terrains, t.LandUse)
-		} else {
-			terrains = append(terrains, t.Terrain)
-		}
+	if mapData.Rules != nil {
+		mapData.Rules.Apply(rules)
+		// The stock was created from the default initial resources.
+		copy(ecs.GetResource[res.Stock](world).Res, rules.InitialResources)
 	}
-	rules.RandomTerrains = terrains
+	rules.RandomTerrains = mapData.Terrains
 	rules.InitialRandomTerrains = mapData.InitialRandomTerrains
 
+	info := ecs.GetResource[res.SaveInfo](world)
+	info.Scenario = s.Map.Name
+	if mapData.Title != "" {
+		info.Scenario = mapData.Title
+	}
+
 	xOff, yOff := terrain.Width()/2-mapData.Center.X, terrain.Height()/2-mapData.Center.Y
 
 	x, y := terrain.Width()/2, terrain.Height()/2
 	bounds.Min = image.Pt(x-1, y-1)
 	bounds.Max = image.Pt(x+1, y+1)
 
-	for y := 0; y < len(mapData.Data); y++ {
-		line := mapData.Data[y]
+	for y, row := range mapData.Terrain {
 		yy := y + yOff
-		for x := 0; x < len(line); x++ {
-			rn := line[x]
-			ter, ok := terr.SymbolToTerrain[rn]
-			if !ok {
-				panic(fmt.Sprintf("unknown map symbol '%s'", string(rn)))
-			}
+		for x, ter := range row {
 			xx := x + xOff
-			if ter.Terrain != terr.Air {
-				fac.Set(world, xx, yy, ter.Terrain, 0, true)
+			if ter != terr.Air {
+				fac.Set(world, xx, yy, ter, 0, true)
 			}
-			if ter.LandUse != terr.Air {
-				fac.Set(world, xx, yy, ter.LandUse, 0, true)
+			// Multi-tile buildings repeat on all covered cells,
+			// and are placed at the first one.
+			lu := mapData.LandUse[y][x]
+			if lu != terr.Air && landUse.Get(xx, yy) == terr.Air {
+				fac.Set(world, xx, yy, lu, 0, true)
 			}
 		}
 	}
@@ -80,6 +76,12 @@ func (s *InitTerrainMap) Initialize(world *ecs.World) {
 		tile, rad := radQuery.Get()
 		fac.SetBuildable(tile.X, tile.Y, int(rad.Radius), true)
 	}
+
+	if rules.LocalStock {
+		fillWarehouses(world, ecs.GetResource[res.Stock](world))
+	}
+
+	initRuleResources(world)
 }
 
 // Update the system
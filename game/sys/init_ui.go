@@ -18,9 +18,13 @@ func (s *InitUI) Initialize(world *ecs.World) {
 		ecs.GetResource[res.Sprites](world),
 		ecs.GetResource[res.RandomTerrains](world),
 		ecs.GetResource[res.SaveEvent](world),
-		ecs.GetResource[res.EditorMode](world))
+		ecs.GetResource[res.EditorMode](world),
+		ecs.GetResource[res.EventLog](world))
 
 	ecs.AddResource(world, &s.ui)
+
+	feedback := res.Feedback{HUD: &s.ui}
+	ecs.AddResource(world, &feedback)
 }
 
 // Update the system
@@ -0,0 +1,36 @@
+package sys
+
+import (
+	"log"
+
+	"github.com/mlange-42/ark/ecs"
+	"github.com/mlange-42/tiny-world/game/res"
+)
+
+// LogHash system.
+// Logs the [StateHash] every Interval ticks, for debugging determinism.
+type LogHash struct {
+	Interval int64
+
+	time     ecs.Resource[res.GameTick]
+	lastTick int64
+}
+
+// Initialize the system
+func (s *LogHash) Initialize(world *ecs.World) {
+	s.time = ecs.NewResource[res.GameTick](world)
+	s.lastTick = -1
+}
+
+// Update the system
+func (s *LogHash) Update(world *ecs.World) {
+	tick := s.time.Get().Tick
+	if s.Interval <= 0 || tick == s.lastTick || tick%s.Interval != 0 {
+		return
+	}
+	s.lastTick = tick
+	log.Printf("tick %d: state hash %016x", tick, StateHash(world))
+}
+
+// Finalize the system
+func (s *LogHash) Finalize(world *ecs.World) {}
@@ -0,0 +1,73 @@
+package sys
+
+import (
+	"github.com/mlange-42/ark/ecs"
+	"github.com/mlange-42/tiny-world/game/res"
+)
+
+// PlayReplay system.
+// Re-applies recorded commands and game speed changes. Must run before [Tick].
+type PlayReplay struct {
+	replay   ecs.Resource[res.Replay]
+	time     ecs.Resource[res.GameTick]
+	speed    ecs.Resource[res.GameSpeed]
+	commands ecs.Resource[res.Commands]
+}
+
+// Initialize the system
+func (s *PlayReplay) Initialize(world *ecs.World) {
+	s.replay = ecs.NewResource[res.Replay](world)
+	s.time = ecs.NewResource[res.GameTick](world)
+	s.speed = ecs.NewResource[res.GameSpeed](world)
+	s.commands = ecs.NewResource[res.Commands](world)
+}
+
+// Update the system
+func (s *PlayReplay) Update(world *ecs.World) {
+	replay := s.replay.Get()
+	if replay.Mode != res.ReplayPlay {
+		return
+	}
+	time := s.time.Get()
+	speed := s.speed.Get()
+	commands := s.commands.Get()
+
+	// Paused updates without events don't change the world, so they are skipped.
+	if speed.Pause {
+		if replay.Next < len(replay.Events) {
+			if due := s.dueTick(&replay.Events[replay.Next]); due > time.RenderTick {
+				time.RenderTick = due
+			}
+		} else if replay.Final != nil && replay.Final.RenderTick > time.RenderTick {
+			time.RenderTick = replay.Final.RenderTick
+		}
+	}
+
+	for replay.Next < len(replay.Events) {
+		event := &replay.Events[replay.Next]
+		if s.dueTick(event) != time.RenderTick {
+			break
+		}
+		if event.Command != nil {
+			commands.Push(*event.Command)
+		}
+		if event.Speed != nil {
+			speed.Pause = event.Speed.Pause
+			speed.Speed = event.Speed.Speed
+		}
+		replay.Next++
+	}
+}
+
+// Finalize the system
+func (s *PlayReplay) Finalize(world *ecs.World) {}
+
+// dueTick returns the render tick before the update in which the event needs to be applied.
+func (s *PlayReplay) dueTick(event *res.ReplayEvent) int64 {
+	if event.Command != nil {
+		// Commands are applied in the update with the event's render tick.
+		return event.RenderTick - 1
+	}
+	// Speed changes take effect after the update with the event's render tick.
+	return event.RenderTick
+}
@@ -1,14 +1,14 @@
 package sys
 
 import (
+	"fmt"
 	"log"
 	"time"
 
-	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/mlange-42/ark-tools/app"
 	"github.com/mlange-42/ark-tools/resource"
 	"github.com/mlange-42/ark/ecs"
+	"github.com/mlange-42/tiny-world/game/nav"
 	"github.com/mlange-42/tiny-world/game/res"
 	"github.com/mlange-42/tiny-world/game/res/achievements"
 	"github.com/mlange-42/tiny-world/game/save"
@@ -19,41 +19,40 @@ type SaveGame struct {
 	SaveFolder string
 	MapFolder  string
 	Name       string
+	// Encoding of save games.
+	Encoding save.Encoding
+	// Interval between autosaves, in ticks of game time. Disabled if 0.
+	AutosaveInterval int64
+	// Number of rotating autosave slots.
+	AutosaveSlots int
 
 	MainMenuFunc func()
 
-	ui        ecs.Resource[res.UI]
+	hud       ecs.Resource[res.Feedback]
 	saveEvent ecs.Resource[res.SaveEvent]
 	saveTime  ecs.Resource[res.SaveTime]
+	replay    ecs.Resource[res.Replay]
+	time      ecs.Resource[res.GameTick]
+	stock     ecs.Resource[res.Stock]
 	skip      []ecs.Comp
+
+	autosaves int64
 }
 
 // Initialize the system
 func (s *SaveGame) Initialize(world *ecs.World) {
-	s.ui = ecs.NewResource[res.UI](world)
+	s.hud = ecs.NewResource[res.Feedback](world)
 	s.saveEvent = ecs.NewResource[res.SaveEvent](world)
 	s.saveTime = ecs.NewResource[res.SaveTime](world)
+	s.replay = ecs.NewResource[res.Replay](world)
+	s.time = ecs.NewResource[res.GameTick](world)
+	s.stock = ecs.NewResource[res.Stock](world)
 
-	s.skip = []ecs.Comp{ecs.C[res.Fonts](),
-		ecs.C[res.Screen](),
-		ecs.C[res.EntityFactory](),
-		ecs.C[res.Sprites](),
-		ecs.C[res.Terrain](),
-		ecs.C[res.TerrainEntities](),
-		ecs.C[res.LandUse](),
-		ecs.C[res.LandUseEntities](),
-		ecs.C[res.Buildable](),
-		ecs.C[res.SaveEvent](),
-		ecs.C[res.UpdateInterval](),
-		ecs.C[res.GameSpeed](),
-		ecs.C[res.Selection](),
-		ecs.C[achievements.Achievements](),
-		ecs.C[res.Mouse](),
-		ecs.C[res.View](),
-		ecs.C[res.UI](),
-		ecs.C[resource.Termination](),
-		ecs.C[resource.Rand](),
-		ecs.C[app.Systems](),
+	s.skip = SkipOnSave()
+
+	// Counts autosaves from the start of the world, so that a loaded game continues the rotation.
+	if s.AutosaveInterval > 0 {
+		s.autosaves = s.time.Get().Tick / s.AutosaveInterval
 	}
 }
 
@@ -61,41 +60,106 @@ func (s *SaveGame) Initialize(world *ecs.World) {
 func (s *SaveGame) Update(world *ecs.World) {
 	evt := s.saveEvent.Get()
 
-	keysPressed := ebiten.IsKeyPressed(ebiten.KeyControl) && inpututil.IsKeyJustPressed(ebiten.KeyS)
-	shift := ebiten.IsKeyPressed(ebiten.KeyShift)
-
-	if evt.ShouldSaveMap || (keysPressed && shift) {
+	if evt.ShouldSaveMap {
 		evt.ShouldSaveMap = false
 		print("Saving map... ")
 		err := save.SaveMap(s.MapFolder, s.Name, world)
 		if err != nil {
-			s.ui.Get().SetStatusLabel("Error saving map")
+			s.hud.Get().SetStatusLabel("Error saving map")
 			log.Printf("Error saving map: %s", err.Error())
 			return
 		}
-		s.ui.Get().SetStatusLabel("Map saved.")
+		s.hud.Get().SetStatusLabel("Map saved.")
 		println("done.")
 	}
 
-	if evt.ShouldSave || (keysPressed && !shift) {
+	if evt.ShouldSave {
 		evt.ShouldSave = false
 		print("Saving game... ")
-
-		s.saveTime.Get().Time = time.Now()
-		err := save.SaveWorld(s.SaveFolder, s.Name, world, s.skip)
-		if err != nil {
-			s.ui.Get().SetStatusLabel("Error saving game")
+		if err := s.save(world, s.Name); err != nil {
+			s.hud.Get().SetStatusLabel("Error saving game")
 			log.Printf("Error saving game: %s", err.Error())
 			return
 		}
-		s.ui.Get().SetStatusLabel("Game saved.")
+		s.hud.Get().SetStatusLabel("Game saved.")
 		println("done.")
 	}
 
-	if evt.ShouldQuit {
+	if s.AutosaveInterval > 0 && s.AutosaveSlots > 0 {
+		if n := s.time.Get().Tick / s.AutosaveInterval; n > s.autosaves {
+			s.autosaves = n
+			slot := int((n-1)%int64(s.AutosaveSlots)) + 1
+			if err := s.save(world, save.AutosaveName(s.Name, slot)); err != nil {
+				s.hud.Get().SetStatusLabel("Error autosaving game")
+				log.Printf("Error autosaving game: %s", err.Error())
+				return
+			}
+		}
+	}
+
+	if evt.ShouldQuit && s.MainMenuFunc != nil {
 		s.MainMenuFunc()
 	}
 }
 
 // Finalize the system
 func (s *SaveGame) Finalize(world *ecs.World) {}
+
+// EmergencySave tries to save the game to the emergency slot of the world, after a crash.
+// The world may be in an inconsistent state, so it recovers from panics while saving.
+func (s *SaveGame) EmergencySave(world *ecs.World) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic during emergency save: %v", r)
+		}
+	}()
+	return s.save(world, save.EmergencyName(s.Name))
+}
+
+// save saves the game and its replay under the given name.
+func (s *SaveGame) save(world *ecs.World, name string) error {
+	s.saveTime.Get().Time = time.Now()
+	if err := save.SaveWorld(s.SaveFolder, name, world, s.skip, s.Encoding); err != nil {
+		return err
+	}
+	if replay := s.replay.Get(); replay.Mode == res.ReplayRecord {
+		replay.Finish(s.time.Get(), s.stock.Get())
+		if err := save.SaveReplay(s.SaveFolder, name, replay); err != nil {
+			return fmt.Errorf("saving replay: %w", err)
+		}
+	}
+	return nil
+}
+
+// SkipOnSave returns the resources that are not written to save games.
+func SkipOnSave() []ecs.Comp {
+	return []ecs.Comp{ecs.C[res.Fonts](),
+		ecs.C[res.Screen](),
+		ecs.C[res.EntityFactory](),
+		ecs.C[res.Sprites](),
+		ecs.C[res.Terrain](),
+		ecs.C[res.TerrainEntities](),
+		ecs.C[res.LandUse](),
+		ecs.C[res.LandUseEntities](),
+		ecs.C[res.PathVersion](),
+		ecs.C[nav.Network](),
+		ecs.C[res.Traffic](),
+		ecs.C[res.Buildable](),
+		ecs.C[res.SaveEvent](),
+		ecs.C[res.UpdateInterval](),
+		ecs.C[res.GameSpeed](),
+		ecs.C[res.Selection](),
+		ecs.C[achievements.Achievements](),
+		ecs.C[res.Mouse](),
+		ecs.C[res.View](),
+		ecs.C[res.UI](),
+		ecs.C[res.Feedback](),
+		ecs.C[res.Commands](),
+		ecs.C[res.Replay](),
+		ecs.C[res.WorldGen](),
+		ecs.C[res.History](),
+		ecs.C[resource.Termination](),
+		ecs.C[resource.Rand](),
+		ecs.C[app.Systems](),
+	}
+}
@@ -0,0 +1,110 @@
+package sys
+
+import (
+	"github.com/mlange-42/ark/ecs"
+	"github.com/mlange-42/tiny-world/game/comp"
+	"github.com/mlange-42/tiny-world/game/res"
+	"github.com/mlange-42/tiny-world/game/terr"
+)
+
+// SupplyInputs system.
+// Fills the input buffers of production buildings from the global stock.
+type SupplyInputs struct {
+	rules  ecs.Resource[res.Rules]
+	speed  ecs.Resource[res.GameSpeed]
+	time   ecs.Resource[res.GameTick]
+	update ecs.Resource[res.UpdateInterval]
+	stock  ecs.Resource[res.Stock]
+	editor ecs.Resource[res.EditorMode]
+
+	filter *ecs.Filter4[comp.Tile, comp.Terrain, comp.UpdateTick, comp.InputBuffer]
+
+	warehouses warehouseFinder
+}
+
+// Initialize the system
+func (s *SupplyInputs) Initialize(world *ecs.World) {
+	s.rules = ecs.NewResource[res.Rules](world)
+	s.speed = ecs.NewResource[res.GameSpeed](world)
+	s.time = ecs.NewResource[res.GameTick](world)
+	s.update = ecs.NewResource[res.UpdateInterval](world)
+	s.stock = ecs.NewResource[res.Stock](world)
+	s.editor = ecs.NewResource[res.EditorMode](world)
+
+	s.filter = s.filter.New(world)
+	s.warehouses = newWarehouseFinder(world)
+}
+
+// Update the system
+func (s *SupplyInputs) Update(world *ecs.World) {
+	if s.speed.Get().Pause || s.editor.Get().IsEditor {
+		return
+	}
+
+	stock := s.stock.Get()
+	tick := s.time.Get().Tick
+	tickMod := tick % s.update.Get().Interval
+	isLocal := s.rules.Get().LocalStock
+
+	query := s.filter.Query()
+	for query.Next() {
+		tile, ter, up, buffer := query.Get()
+
+		if up.Tick != tickMod {
+			continue
+		}
+
+		prod := &terr.Properties[ter.Terrain].Production
+		// Reachable warehouses, searched only when required.
+		var found []warehouseEntry
+		for _, in := range prod.Inputs {
+			missing := int(prod.InputStorage[in.Resource]) - int(buffer.Stock[in.Resource])
+			if missing <= 0 {
+				continue
+			}
+			if !isLocal {
+				amount := min(missing, stock.Res[in.Resource])
+				if amount <= 0 {
+					continue
+				}
+				buffer.Stock[in.Resource] += uint8(amount)
+				stock.Res[in.Resource] -= amount
+				continue
+			}
+			if found == nil {
+				found = s.warehouses.Find(*tile, false)
+			}
+			amount := min(missing, s.warehouses.Available(found, in.Resource))
+			if amount <= 0 {
+				continue
+			}
+			s.warehouses.Pay(stock, found, []terr.ResourceAmount{{Resource: in.Resource, Amount: uint16(amount)}})
+			buffer.Stock[in.Resource] += uint8(amount)
+		}
+	}
+}
+
+// Finalize the system
+func (s *SupplyInputs) Finalize(world *ecs.World) {}
+
+// hasInputs checks whether the input buffer holds the inputs for producing one unit.
+func hasInputs(buffer *comp.InputBuffer, prod *terr.Production) bool {
+	for _, in := range prod.Inputs {
+		if uint16(buffer.Stock[in.Resource]) < in.Amount {
+			return false
+		}
+	}
+	return true
+}
+
+// takeInputs removes the inputs for producing one unit from the input buffer.
+// Returns false and leaves the buffer unchanged if inputs are missing.
+func takeInputs(buffer *comp.InputBuffer, prod *terr.Production) bool {
+	if !hasInputs(buffer, prod) {
+		return false
+	}
+	for _, in := range prod.Inputs {
+		buffer.Stock[in.Resource] -= uint8(in.Amount)
+	}
+	return true
+}
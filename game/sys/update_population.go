@@ -52,23 +52,24 @@ func (s *UpdatePopulation) Update(world *ecs.World) {
 		pop.Pop = 0
 
 		lu := landUse.Get(tile.X, tile.Y)
+		rect := landUse.Footprint(tile.X, tile.Y)
 
 		supp := &terr.Properties[lu].PopulationSupport
 		if supp.RequiredTerrain != terr.Air &&
-			terrain.CountNeighbors4(tile.X, tile.Y, supp.RequiredTerrain) == 0 &&
-			landUse.CountNeighbors4(tile.X, tile.Y, supp.RequiredTerrain) == 0 {
+			terrain.CountNeighborsRect4(rect, terr.NewTerrains(supp.RequiredTerrain)) == 0 &&
+			landUse.CountNeighborsRect4(rect, terr.NewTerrains(supp.RequiredTerrain)) == 0 {
 			pop.HasRequired = false
 			continue
 		}
 		pop.HasRequired = true
 		count := int(supp.BasePopulation)
 		if supp.BonusTerrain != 0 {
-			count += terrain.CountNeighborsMask8(tile.X, tile.Y, supp.BonusTerrain) +
-				landUse.CountNeighborsMask8(tile.X, tile.Y, supp.BonusTerrain)
+			count += terrain.CountNeighborsRect8(rect, supp.BonusTerrain) +
+				landUse.CountNeighborsRect8(rect, supp.BonusTerrain)
 		}
 		if supp.MalusTerrain != 0 {
-			count -= terrain.CountNeighborsMask8(tile.X, tile.Y, supp.MalusTerrain) +
-				landUse.CountNeighborsMask8(tile.X, tile.Y, supp.MalusTerrain)
+			count -= terrain.CountNeighborsRect8(rect, supp.MalusTerrain) +
+				landUse.CountNeighborsRect8(rect, supp.MalusTerrain)
 		}
 		pop.Pop = uint8(math.ClampInt(count, 0, int(supp.MaxPopulation)))
 	}
@@ -18,6 +18,7 @@ type UpdateProduction struct {
 
 	filter            *ecs.Filter4[comp.Tile, comp.UpdateTick, comp.Production, comp.Consumption]
 	consumptionMapper *ecs.Map1[comp.Consumption]
+	inputMapper       *ecs.Map1[comp.InputBuffer]
 }
 
 // Initialize the system
@@ -30,6 +31,7 @@ func (s *UpdateProduction) Initialize(world *ecs.World) {
 
 	s.filter = s.filter.New(world)
 	s.consumptionMapper = s.consumptionMapper.New(world)
+	s.inputMapper = s.inputMapper.New(world)
 }
 
 // Update the system
@@ -58,19 +60,27 @@ func (s *UpdateProduction) Update(world *ecs.World) {
 		}
 
 		lu := landUse.Get(tile.X, tile.Y)
+		rect := landUse.Footprint(tile.X, tile.Y)
 
 		prod := &terr.Properties[lu].Production
 		if prod.RequiredTerrain != terr.Air &&
-			terrain.CountNeighbors4(tile.X, tile.Y, prod.RequiredTerrain) == 0 &&
-			landUse.CountNeighbors4(tile.X, tile.Y, prod.RequiredTerrain) == 0 {
+			terrain.CountNeighborsRect4(rect, terr.NewTerrains(prod.RequiredTerrain)) == 0 &&
+			landUse.CountNeighborsRect4(rect, terr.NewTerrains(prod.RequiredTerrain)) == 0 {
+			pr.HasRequired = false
+			continue
+		}
+		if prod.HasInputs() && !hasInputs(s.inputMapper.Get(query.Entity()), prod) {
 			pr.HasRequired = false
 			continue
 		}
 		pr.HasRequired = true
 		count := 0
 		if prod.ProductionTerrain != 0 {
-			count += terrain.CountNeighborsMask8(tile.X, tile.Y, prod.ProductionTerrain) +
-				landUse.CountNeighborsMask8(tile.X, tile.Y, prod.ProductionTerrain)
+			count += terrain.CountNeighborsRect8(rect, prod.ProductionTerrain) +
+				landUse.CountNeighborsRect8(rect, prod.ProductionTerrain)
+		} else if prod.HasInputs() {
+			// Buildings that only convert inputs produce at full rate.
+			count = int(prod.MaxProduction)
 		}
 		pr.Amount = uint8(math.MinInt(count, int(prod.MaxProduction)))
 	}
@@ -1,8 +1,10 @@
 package sys
 
 import (
+	"cmp"
 	"fmt"
 	"math"
+	"slices"
 	"time"
 
 	"github.com/mlange-42/ark/ecs"
@@ -18,19 +20,28 @@ type UpdateStats struct {
 	rules          ecs.Resource[res.Rules]
 	production     ecs.Resource[res.Production]
 	stock          ecs.Resource[res.Stock]
-	ui             ecs.Resource[res.UI]
+	hud            ecs.Resource[res.Feedback]
 	tick           ecs.Resource[res.GameTick]
 	speed          ecs.Resource[res.GameSpeed]
 	interval       ecs.Resource[res.UpdateInterval]
 	editor         ecs.Resource[res.EditorMode]
 	randomTerrains ecs.Resource[res.RandomTerrains]
 
-	prodFilter              *ecs.Filter1[comp.Production]
+	prodFilter              *ecs.Filter2[comp.Terrain, comp.Production]
 	consFilter              *ecs.Filter1[comp.Consumption]
 	populationFilter        *ecs.Filter1[comp.Population]
 	populationSupportFilter *ecs.Filter1[comp.PopulationSupport]
-	stockFilter             *ecs.Filter1[comp.Terrain]
+	stockFilter             *ecs.Filter3[comp.Tile, comp.Terrain, comp.Warehouse]
 	unlockFilter            *ecs.Filter1[comp.Terrain]
+
+	warehouses []warehouseStats
+}
+
+// warehouseStats is the inventory of a warehouse, for the per-warehouse breakdown in local stock mode.
+type warehouseStats struct {
+	Tile    comp.Tile
+	Stock   []int
+	Storage []uint8
 }
 
 // Initialize the system
@@ -38,7 +49,7 @@ func (s *UpdateStats) Initialize(world *ecs.World) {
 	s.rules = ecs.NewResource[res.Rules](world)
 	s.production = ecs.NewResource[res.Production](world)
 	s.stock = ecs.NewResource[res.Stock](world)
-	s.ui = ecs.NewResource[res.UI](world)
+	s.hud = ecs.NewResource[res.Feedback](world)
 	s.tick = ecs.NewResource[res.GameTick](world)
 	s.speed = ecs.NewResource[res.GameSpeed](world)
 	s.interval = ecs.NewResource[res.UpdateInterval](world)
@@ -50,14 +61,14 @@ func (s *UpdateStats) Initialize(world *ecs.World) {
 	s.populationFilter = s.populationFilter.New(world)
 	s.populationSupportFilter = s.populationSupportFilter.New(world)
 
-	s.stockFilter = s.stockFilter.New(world).With(ecs.C[comp.Warehouse]())
+	s.stockFilter = s.stockFilter.New(world)
 	s.unlockFilter = s.unlockFilter.New(world).With(ecs.C[comp.UnlocksTerrain]())
 }
 
 // Update the system
 func (s *UpdateStats) Update(world *ecs.World) {
 	rules := s.rules.Get()
-	ui := s.ui.Get()
+	hud := s.hud.Get()
 	production := s.production.Get()
 	stock := s.stock.Get()
 	production.Reset()
@@ -70,8 +81,11 @@ func (s *UpdateStats) Update(world *ecs.World) {
 
 	prodQuery := s.prodFilter.Query()
 	for prodQuery.Next() {
-		prod := prodQuery.Get()
+		ter, prod := prodQuery.Get()
 		production.Prod[prod.Resource] += int(prod.Amount)
+		for _, in := range terr.Properties[ter.Terrain].Production.Inputs {
+			production.Cons[in.Resource] += int(prod.Amount) * int(in.Amount)
+		}
 	}
 	consQuery := s.consFilter.Query()
 	for consQuery.Next() {
@@ -81,16 +95,27 @@ func (s *UpdateStats) Update(world *ecs.World) {
 		}
 	}
 
+	isLocal := rules.LocalStock
 	for i := range resource.Properties {
 		stock.Cap[i] = 0
+		if isLocal {
+			stock.Res[i] = 0
+		}
 	}
 
 	stockQuery := s.stockFilter.Query()
 	for stockQuery.Next() {
-		tp := stockQuery.Get()
+		tile, tp, wh := stockQuery.Get()
 		prop := &terr.Properties[tp.Terrain]
 		for i := range resource.Properties {
 			stock.Cap[i] += int(prop.Storage[i])
+			if isLocal {
+				wh.Stock[i] = min(wh.Stock[i], int(prop.Storage[i]))
+				stock.Res[i] += wh.Stock[i]
+			}
+		}
+		if isLocal {
+			s.warehouses = append(s.warehouses, warehouseStats{Tile: *tile, Stock: wh.Stock, Storage: prop.Storage})
 		}
 	}
 
@@ -118,21 +143,25 @@ func (s *UpdateStats) Update(world *ecs.World) {
 			stock.Res[i] = stock.Cap[i]
 		}
 		if production.Cons[i] > 0 {
-			ui.SetResourceLabel(resource.Resource(i),
+			hud.SetResourceLabel(resource.Resource(i),
 				fmt.Sprintf("+%d-%d (%d/%d)", production.Prod[i], production.Cons[i], stock.Res[i], stock.Cap[i]),
 				production.Cons[i] >= production.Prod[i],
 			)
 		} else {
-			ui.SetResourceLabel(resource.Resource(i),
+			hud.SetResourceLabel(resource.Resource(i),
 				fmt.Sprintf("+%d (%d/%d)", production.Prod[i], stock.Res[i], stock.Cap[i]),
 				false)
 		}
 	}
-	ui.SetPopulationLabel(fmt.Sprintf("%d/%d", stock.Population, stock.MaxPopulation), stock.Population >= stock.MaxPopulation)
+	if isLocal && tick%(interval/3) == 0 {
+		s.updateWarehouseDetails(hud)
+	}
+	s.warehouses = s.warehouses[:0]
+	hud.SetPopulationLabel(fmt.Sprintf("%d/%d", stock.Population, stock.MaxPopulation), stock.Population >= stock.MaxPopulation)
 
 	secs := tick / interval
 	duration := time.Duration(secs) * time.Second
-	ui.SetTimerLabel(util.FormatDuration(duration))
+	hud.SetTimerLabel(util.FormatDuration(duration))
 	speedStr := "P"
 	if !speed.Pause {
 		if speed.Speed >= 0 {
@@ -141,9 +170,9 @@ func (s *UpdateStats) Update(world *ecs.World) {
 			speedStr = fmt.Sprintf("x1/%d", int(1/math.Pow(2, float64(speed.Speed))))
 		}
 	}
-	ui.SetSpeedLabel(speedStr)
+	hud.SetSpeedLabel(speedStr)
 
-	ui.SetRandomTilesLabel(fmt.Sprintf("%d/%d",
+	hud.SetRandomTilesLabel(fmt.Sprintf("%d/%d",
 		randomTerrains.TotalAvailable-randomTerrains.TotalPlaced,
 		randomTerrains.TotalAvailable))
 
@@ -185,13 +214,56 @@ func (s *UpdateStats) Update(world *ecs.World) {
 			message += "Population limit reached."
 			canBuild = false
 		}
-		if canBuild {
-			ui.EnableButton(terr.Terrain(i))
+		if canBuild || s.canUpgradeTo(stock, terr.Terrain(i)) {
+			hud.EnableButton(terr.Terrain(i))
 		} else {
-			ui.DisableButton(terr.Terrain(i), message)
+			hud.DisableButton(terr.Terrain(i), message)
 		}
 	}
 }
 
 // Finalize the system
 func (s *UpdateStats) Finalize(world *ecs.World) {}
+
+// maxWarehouseDetails is the maximum number of warehouses listed in the per-warehouse breakdown.
+const maxWarehouseDetails = 8
+
+// updateWarehouseDetails shows the per-warehouse breakdown of resources, in local stock mode.
+func (s *UpdateStats) updateWarehouseDetails(hud *res.Feedback) {
+	slices.SortFunc(s.warehouses, func(a, b warehouseStats) int {
+		return cmp.Or(cmp.Compare(a.Tile.X, b.Tile.X), cmp.Compare(a.Tile.Y, b.Tile.Y))
+	})
+	for i := range resource.Properties {
+		text := "Warehouses:"
+		cnt := 0
+		for _, wh := range s.warehouses {
+			if wh.Storage[i] == 0 {
+				continue
+			}
+			if cnt < maxWarehouseDetails {
+				text += fmt.Sprintf("\n   (%d, %d): %d/%d", wh.Tile.X, wh.Tile.Y, wh.Stock[i], wh.Storage[i])
+			}
+			cnt++
+		}
+		if cnt > maxWarehouseDetails {
+			text += fmt.Sprintf("\n   ... and %d more", cnt-maxWarehouseDetails)
+		}
+		hud.SetResourceDetails(resource.Resource(i), text)
+	}
+}
+
+// canUpgradeTo checks whether any building type can be upgraded to the given one with the current stock.
+func (s *UpdateStats) canUpgradeTo(stock *res.Stock, to terr.Terrain) bool {
+	props := &terr.Properties[to]
+	for i := range terr.Properties {
+		from := &terr.Properties[i]
+		if from.UpgradesTo != to || to == terr.Air || !stock.CanPay(from.UpgradeCost) {
+			continue
+		}
+		if props.Population > from.Population && stock.Population+int(props.Population-from.Population) > stock.MaxPopulation {
+			continue
+		}
+		return true
+	}
+	return false
+}
@@ -7,18 +7,15 @@ import (
 
 // UpdateUI system.
 type UpdateUI struct {
-	rules ecs.Resource[res.Rules]
-	ui    ecs.Resource[res.UI]
+	ui ecs.Resource[res.UI]
 }
 
 // Initialize the system
 func (s *UpdateUI) Initialize(world *ecs.World) {
-	s.rules = ecs.NewResource[res.Rules](world)
 	s.ui = ecs.NewResource[res.UI](world)
 
-	rules := s.rules.Get()
 	ui := s.ui.Get()
-	ui.CreateRandomButtons(rules.RandomTerrainsCount)
+	ui.CreateRandomButtons()
 }
 
 // Update the system
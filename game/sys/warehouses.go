@@ -0,0 +1,216 @@
+package sys
+
+import (
+	"cmp"
+	"image"
+	"log"
+	"slices"
+
+	"github.com/mlange-42/ark/ecs"
+	"github.com/mlange-42/tiny-world/game/comp"
+	"github.com/mlange-42/tiny-world/game/nav"
+	"github.com/mlange-42/tiny-world/game/res"
+	"github.com/mlange-42/tiny-world/game/resource"
+	"github.com/mlange-42/tiny-world/game/terr"
+)
+
+// warehouseEntry is a warehouse found by a [warehouseFinder].
+type warehouseEntry struct {
+	Entity ecs.Entity
+	Tile   comp.Tile
+	// Travel cost of the path to the warehouse.
+	Distance int
+}
+
+// warehouseFinder finds warehouses reachable over the path network, for local stock mode.
+type warehouseFinder struct {
+	landUse   *res.LandUse
+	network   *nav.Network
+	filter    *ecs.Filter1[comp.Tile]
+	warehouse *ecs.Map2[comp.Terrain, comp.Warehouse]
+	found     []warehouseEntry
+	all       []warehouseEntry
+	tiles     []comp.Tile
+}
+
+func newWarehouseFinder(world *ecs.World) warehouseFinder {
+	return warehouseFinder{
+		landUse:   ecs.GetResource[res.LandUse](world),
+		network:   ecs.GetResource[nav.Network](world),
+		filter:    ecs.NewFilter1[comp.Tile](world).With(ecs.C[comp.Warehouse]()),
+		warehouse: ecs.NewMap2[comp.Terrain, comp.Warehouse](world),
+	}
+}
+
+// Find returns the warehouses reachable from the given tile, nearest first.
+// For building sites, warehouses next to the tile are reachable, too.
+// Travel costs to all warehouses are found in a single search, see [nav.Network.Costs].
+//
+// The returned slice is reused by the next call.
+func (f *warehouseFinder) Find(from comp.Tile, site bool) []warehouseEntry {
+	f.all = f.all[:0]
+	f.tiles = f.tiles[:0]
+	query := f.filter.Query()
+	for query.Next() {
+		tile := query.Get()
+		f.all = append(f.all, warehouseEntry{Entity: query.Entity(), Tile: *tile})
+		f.tiles = append(f.tiles, *tile)
+	}
+	costs := f.network.Costs(from, f.tiles)
+
+	f.found = f.found[:0]
+	for i, entry := range f.all {
+		if site && isNeighbor(from.Point, f.landUse.Footprint(entry.Tile.X, entry.Tile.Y)) {
+			entry.Distance = nav.StepCost(f.landUse, nil, from, entry.Tile)
+		} else if costs[i] >= 0 {
+			entry.Distance = costs[i]
+		} else {
+			continue
+		}
+		f.found = append(f.found, entry)
+	}
+	// Sorting by tile for ties keeps the result independent of query order.
+	slices.SortFunc(f.found, func(a, b warehouseEntry) int {
+		return cmp.Or(
+			cmp.Compare(a.Distance, b.Distance),
+			cmp.Compare(a.Tile.X, b.Tile.X),
+			cmp.Compare(a.Tile.Y, b.Tile.Y),
+		)
+	})
+	return f.found
+}
+
+// Get returns the inventory and the storage capacity of a found warehouse.
+func (f *warehouseFinder) Get(entry *warehouseEntry) (*comp.Warehouse, []uint8) {
+	ter, wh := f.warehouse.Get(entry.Entity)
+	return wh, terr.Properties[ter.Terrain].Storage
+}
+
+// Available returns the total amount of a resource in the given warehouses.
+func (f *warehouseFinder) Available(found []warehouseEntry, res resource.Resource) int {
+	total := 0
+	for i := range found {
+		wh, _ := f.Get(&found[i])
+		total += wh.Stock[res]
+	}
+	return total
+}
+
+// CanPay checks whether the given warehouses together hold the given amounts.
+func (f *warehouseFinder) CanPay(found []warehouseEntry, cost []terr.ResourceAmount) bool {
+	for _, c := range cost {
+		if f.Available(found, c.Resource) < int(c.Amount) {
+			return false
+		}
+	}
+	return true
+}
+
+// Pay withdraws the given amounts from the given warehouses, nearest first.
+// Returns the changes of the warehouse inventories.
+func (f *warehouseFinder) Pay(stock *res.Stock, found []warehouseEntry, cost []terr.ResourceAmount) []res.StockChange {
+	changes := []res.StockChange{}
+	for _, c := range cost {
+		remaining := int(c.Amount)
+		for i := range found {
+			if remaining == 0 {
+				break
+			}
+			wh, _ := f.Get(&found[i])
+			amount := min(remaining, wh.Stock[c.Resource])
+			if amount <= 0 {
+				continue
+			}
+			stock.Withdraw(wh, c.Resource, amount)
+			remaining -= amount
+			changes = append(changes, res.StockChange{Tile: found[i].Tile.Point, Resource: c.Resource, Amount: -amount})
+		}
+	}
+	return changes
+}
+
+// Deposit adds the given amounts to the given warehouses, nearest first, limited by their capacity.
+// Returns the amounts that were actually added, and the changes of the warehouse inventories.
+func (f *warehouseFinder) Deposit(stock *res.Stock, found []warehouseEntry, amounts []terr.ResourceAmount) ([]terr.ResourceAmount, []res.StockChange) {
+	added := []terr.ResourceAmount{}
+	changes := []res.StockChange{}
+	for _, a := range amounts {
+		remaining := int(a.Amount)
+		for i := range found {
+			if remaining == 0 {
+				break
+			}
+			wh, storage := f.Get(&found[i])
+			amount := min(remaining, int(storage[a.Resource])-wh.Stock[a.Resource])
+			if amount <= 0 {
+				continue
+			}
+			stock.Deposit(wh, a.Resource, amount)
+			remaining -= amount
+			changes = append(changes, res.StockChange{Tile: found[i].Tile.Point, Resource: a.Resource, Amount: amount})
+		}
+		if amount := int(a.Amount) - remaining; amount > 0 {
+			added = append(added, terr.ResourceAmount{Resource: a.Resource, Amount: uint16(amount)})
+		}
+	}
+	return added, changes
+}
+
+// UpdateTotals sets the global stock to the sum of all warehouse inventories.
+// Required after warehouses were removed or restored.
+func (f *warehouseFinder) UpdateTotals(stock *res.Stock) {
+	for i := range stock.Res {
+		stock.Res[i] = 0
+	}
+	query := f.filter.Query()
+	for query.Next() {
+		_, wh := f.warehouse.Get(query.Entity())
+		for i, st := range wh.Stock {
+			stock.Res[i] += st
+		}
+	}
+}
+
+// fillWarehouses distributes the global stock to the inventories of all warehouses, limited by their capacity.
+// Warehouses are filled in order of their tile coordinates.
+// Resources that don't fit are discarded, and logged.
+func fillWarehouses(world *ecs.World, stock *res.Stock) {
+	filter := ecs.NewFilter3[comp.Tile, comp.Terrain, comp.Warehouse](world)
+	type entry struct {
+		Tile      comp.Tile
+		Storage   []uint8
+		Warehouse *comp.Warehouse
+	}
+	warehouses := []entry{}
+	query := filter.Query()
+	for query.Next() {
+		tile, ter, wh := query.Get()
+		warehouses = append(warehouses, entry{Tile: *tile, Storage: terr.Properties[ter.Terrain].Storage, Warehouse: wh})
+	}
+	slices.SortFunc(warehouses, func(a, b entry) int {
+		return cmp.Or(cmp.Compare(a.Tile.X, b.Tile.X), cmp.Compare(a.Tile.Y, b.Tile.Y))
+	})
+
+	for i := range resource.Properties {
+		remaining := stock.Res[i]
+		for _, wh := range warehouses {
+			if len(wh.Warehouse.Stock) != len(resource.Properties) {
+				wh.Warehouse.Stock = make([]int, len(resource.Properties))
+			}
+			amount := min(remaining, int(wh.Storage[i]))
+			wh.Warehouse.Stock[i] = amount
+			remaining -= amount
+		}
+		if remaining > 0 {
+			log.Printf("Discarded %d %s that did not fit into warehouses", remaining, resource.Properties[i].Name)
+		}
+		stock.Res[i] -= remaining
+	}
+}
+
+// isNeighbor checks whether a tile is a direct (non-diagonal) neighbor of a building's footprint.
+func isNeighbor(p image.Point, rect image.Rectangle) bool {
+	dx := max(rect.Min.X-p.X, 0, p.X-(rect.Max.X-1))
+	dy := max(rect.Min.Y-p.Y, 0, p.Y-(rect.Max.Y-1))
+	return dx+dy == 1
+}
@@ -2,6 +2,8 @@ package terr
 
 import (
 	"fmt"
+	"image"
+	"image/color"
 	"io/fs"
 	"strings"
 
@@ -20,6 +22,7 @@ const (
 	CanBuild
 	CanBuy
 	RequiresRange
+	IsFlammable
 )
 
 type TerrainPair struct {
@@ -60,6 +63,16 @@ type Terrains uint64
 var Buildings Terrains
 var Paths Terrains
 
+// DefaultMoveCost is the move cost of tiles without an explicit `move_cost`.
+// Haulers need one update interval to cross a tile with the default cost.
+const DefaultMoveCost uint8 = 10
+
+// MinMoveCost is the smallest move cost of all terrains.
+var MinMoveCost uint8
+
+// MaxFootprint is the maximum width and height of a building's footprint.
+const MaxFootprint = 15
+
 func NewTerrains(dirs ...Terrain) Terrains {
 	d := Terrains(0)
 	for _, dir := range dirs {
@@ -101,6 +114,7 @@ func Prepare(f fs.FS, file string) {
 	}
 
 	props := []TerrainProps{}
+	MinMoveCost = DefaultMoveCost
 	for i, t := range propsHelper.Terrains {
 		if i >= 64 {
 			panic("supports only 64 terrain types")
@@ -160,6 +174,58 @@ func Prepare(f fs.FS, file string) {
 			consumption[res] = uint8(entry.Amount)
 		}
 
+		footprint := toFootprint(&t)
+		if footprint != image.Pt(1, 1) && !t.IsBuilding {
+			panic(fmt.Sprintf("footprint is only supported for buildings, in %s", t.Name))
+		}
+
+		upgradesTo := ToTerrain(propsHelper.ZeroTerrain)
+		if t.UpgradesTo != "" {
+			upgradesTo = ToTerrain(t.UpgradesTo)
+			target := &propsHelper.Terrains[upgradesTo]
+			if !t.CanBuy || !target.CanBuy || t.IsPath || t.IsBridge || target.IsPath || target.IsBridge {
+				panic(fmt.Sprintf("can only upgrade buildings, from %s to %s", t.Name, t.UpgradesTo))
+			}
+			// Upgrades grow from the building's origin, so the new footprint must cover the old one.
+			if tf := toFootprint(target); tf.X < footprint.X || tf.Y < footprint.Y {
+				panic(fmt.Sprintf("can only upgrade buildings to an equal or larger footprint, from %s to %s", t.Name, t.UpgradesTo))
+			}
+		}
+		upgradeCost := toResourceAmounts(t.UpgradeCost, t.Name)
+
+		inputs := toResourceAmounts(t.Production.Inputs, t.Name)
+		inputStorage := toResourceArray(t.Production.InputStorage, t.Name)
+		for _, in := range inputs {
+			if t.Production.MaxProduction == 0 {
+				panic(fmt.Sprintf("production inputs without production in %s", t.Name))
+			}
+			if uint16(inputStorage[in.Resource]) < in.Amount {
+				panic(fmt.Sprintf("input storage smaller than input amount for %s in %s", resource.Properties[in.Resource].Name, t.Name))
+			}
+		}
+
+		if t.Salvage.BuildCost > 100 {
+			panic(fmt.Sprintf("salvage build cost is not a percentage in %s", t.Name))
+		}
+
+		moveCost := DefaultMoveCost
+		if t.MoveCost != nil {
+			if *t.MoveCost == 0 {
+				panic(fmt.Sprintf("move cost must be positive in %s", t.Name))
+			}
+			if !t.IsPath && !t.IsBridge {
+				panic(fmt.Sprintf("move cost is only supported for paths and bridges, in %s", t.Name))
+			}
+			moveCost = *t.MoveCost
+		}
+		MinMoveCost = min(MinMoveCost, moveCost)
+		if t.Capacity > 0 && !t.IsPath && !t.IsBridge {
+			panic(fmt.Sprintf("capacity is only supported for paths and bridges, in %s", t.Name))
+		}
+		if (t.IsFlammable || t.FireProtection > 0) && t.IsTerrain {
+			panic(fmt.Sprintf("fire properties are only supported for land use, in %s", t.Name))
+		}
+
 		symbols := []rune(t.Symbols)
 		if len(symbols) != len(t.BuildOn) {
 			panic(fmt.Sprintf("length of `symbols` not equal to length of `build_on` in %s", t.Name))
@@ -193,6 +259,9 @@ func Prepare(f fs.FS, file string) {
 		if t.RequiresRange {
 			bits.Set(RequiresRange)
 		}
+		if t.IsFlammable {
+			bits.Set(IsFlammable)
+		}
 
 		p := TerrainProps{
 			Name:            t.Name,
@@ -202,10 +271,16 @@ func Prepare(f fs.FS, file string) {
 			UnlocksTerrains: t.UnlocksTerrains,
 			ConnectsTo:      ToTerrains(t.ConnectsTo...),
 			BuildRadius:     t.BuildRadius,
+			FireProtection:  t.FireProtection,
+			MoveCost:        moveCost,
+			Capacity:        t.Capacity,
+			Footprint:       footprint,
 			Population:      t.Population,
 			Symbols:         symbols,
 			Description:     t.Description,
 			BuildCost:       cost,
+			UpgradesTo:      upgradesTo,
+			UpgradeCost:     upgradeCost,
 			Storage:         storage,
 			Production: Production{
 				Resource:          prodRes,
@@ -213,8 +288,14 @@ func Prepare(f fs.FS, file string) {
 				RequiredTerrain:   requiredTerrain,
 				ProductionTerrain: productionTerrain,
 				HaulCapacity:      t.Production.HaulCapacity,
+				Inputs:            inputs,
+				InputStorage:      inputStorage,
 			},
 			Consumption: consumption,
+			Salvage: Salvage{
+				BuildCost:   t.Salvage.BuildCost,
+				ReturnStock: t.Salvage.ReturnStock,
+			},
 			PopulationSupport: PopulationSupport{
 				BasePopulation:  t.PopulationSupport.BasePopulation,
 				MaxPopulation:   t.PopulationSupport.MaxPopulation,
@@ -222,6 +303,7 @@ func Prepare(f fs.FS, file string) {
 				BonusTerrain:    ToTerrains(t.PopulationSupport.BonusTerrain...),
 				MalusTerrain:    ToTerrains(t.PopulationSupport.MalusTerrain...),
 			},
+			MinimapColor: toColor(&t),
 		}
 
 		if p.TerrainBits.Contains(IsBuilding) {
@@ -294,10 +376,24 @@ type TerrainProps struct {
 	Description       string
 	Symbols           []rune
 	BuildCost         []ResourceAmount
+	UpgradesTo        Terrain
+	UpgradeCost       []ResourceAmount
 	Storage           []uint8
 	Consumption       []uint8
 	Production        Production
+	Salvage           Salvage
 	PopulationSupport PopulationSupport
+	// Relative time haulers need to cross the tile. See [DefaultMoveCost].
+	MoveCost uint8
+	// Number of haulers on the tile before they slow down, with congestion. Zero for unlimited.
+	Capacity uint8
+	// Number of tiles covered in X and Y direction. 1x1 for all except multi-tile buildings.
+	Footprint image.Point
+	// Radius in which the land use protects against fire. Zero for none.
+	FireProtection uint8
+	// Color in minimaps, like save game thumbnails. Transparent if not set,
+	// so that land use shows the terrain below.
+	MinimapColor color.RGBA
 }
 
 type terrainPropsJs struct {
@@ -307,8 +403,13 @@ type terrainPropsJs struct {
 	IsBridge          bool                `json:"is_bridge"`
 	IsBuilding        bool                `json:"is_building"`
 	IsWarehouse       bool                `json:"is_warehouse"`
+	IsFlammable       bool                `json:"is_flammable,omitempty"`
 	UnlocksTerrains   uint16              `json:"unlocks_terrains"`
 	BuildRadius       uint8               `json:"build_radius"`
+	FireProtection    uint8               `json:"fire_protection,omitempty"`
+	MoveCost          *uint8              `json:"move_cost,omitempty"`
+	Capacity          uint8               `json:"capacity,omitempty"`
+	Footprint         []uint8             `json:"footprint,omitempty"`
 	Population        uint8               `json:"population"`
 	BuildOn           []string            `json:"build_on,omitempty"`
 	RequiresRange     bool                `json:"requires_range"`
@@ -319,10 +420,14 @@ type terrainPropsJs struct {
 	Production        productionJs        `json:"production"`
 	Consumption       []resourceAmountJs  `json:"consumption,omitempty"`
 	BuildCost         []resourceAmountJs  `json:"build_cost,omitempty"`
+	UpgradesTo        string              `json:"upgrades_to,omitempty"`
+	UpgradeCost       []resourceAmountJs  `json:"upgrade_cost,omitempty"`
 	Storage           []resourceAmountJs  `json:"storage,omitempty"`
 	Symbols           string              `json:"symbols"`
 	Description       string              `json:"description"`
+	MinimapColor      string              `json:"minimap_color,omitempty"`
 	PopulationSupport populationSupportJs `json:"population_support"`
+	Salvage           salvageJs           `json:"salvage"`
 }
 
 type Production struct {
@@ -331,14 +436,25 @@ type Production struct {
 	HaulCapacity      uint8
 	RequiredTerrain   Terrain
 	ProductionTerrain Terrains
+	// Input resources consumed per produced unit.
+	Inputs []ResourceAmount
+	// Capacity of the input buffer, indexed by [resource.Resource].
+	InputStorage []uint8
+}
+
+// HasInputs returns whether production requires input resources.
+func (p *Production) HasInputs() bool {
+	return len(p.Inputs) > 0
 }
 
 type productionJs struct {
-	Resource          string   `json:"resource"`
-	MaxProduction     uint8    `json:"max_production"`
-	HaulCapacity      uint8    `json:"haul_capacity"`
-	RequiredTerrain   string   `json:"required_terrain"`
-	ProductionTerrain []string `json:"production_terrain"`
+	Resource          string             `json:"resource"`
+	MaxProduction     uint8              `json:"max_production"`
+	HaulCapacity      uint8              `json:"haul_capacity"`
+	RequiredTerrain   string             `json:"required_terrain"`
+	ProductionTerrain []string           `json:"production_terrain"`
+	Inputs            []resourceAmountJs `json:"inputs,omitempty"`
+	InputStorage      []resourceAmountJs `json:"input_storage,omitempty"`
 }
 
 type PopulationSupport struct {
@@ -357,6 +473,19 @@ type populationSupportJs struct {
 	MalusTerrain    []string `json:"malus_terrain"`
 }
 
+// Salvage determines what is recovered when bulldozing.
+type Salvage struct {
+	// Percentage of the build cost that is refunded.
+	BuildCost uint8
+	// Whether the local production stock is recovered. It is dropped otherwise.
+	ReturnStock bool
+}
+
+type salvageJs struct {
+	BuildCost   uint8 `json:"build_cost"`
+	ReturnStock bool  `json:"return_stock"`
+}
+
 type ResourceAmount struct {
 	Resource resource.Resource
 	Amount   uint16
@@ -376,6 +505,59 @@ type props struct {
 	Terrains      []terrainPropsJs `json:"terrains"`
 }
 
+func toFootprint(t *terrainPropsJs) image.Point {
+	if len(t.Footprint) == 0 {
+		return image.Pt(1, 1)
+	}
+	if len(t.Footprint) != 2 {
+		panic(fmt.Sprintf("footprint must be given as [width, height] in %s", t.Name))
+	}
+	w, h := int(t.Footprint[0]), int(t.Footprint[1])
+	if w < 1 || h < 1 || w > MaxFootprint || h > MaxFootprint {
+		panic(fmt.Sprintf("footprint must be between 1 and %d in each direction, in %s", MaxFootprint, t.Name))
+	}
+	return image.Pt(w, h)
+}
+
+// toColor parses the minimap color of a terrain, given like "#8fb35a".
+func toColor(t *terrainPropsJs) color.RGBA {
+	if t.MinimapColor == "" {
+		return color.RGBA{}
+	}
+	c := color.RGBA{A: 255}
+	if _, err := fmt.Sscanf(t.MinimapColor, "#%02x%02x%02x", &c.R, &c.G, &c.B); err != nil || len(t.MinimapColor) != 7 {
+		panic(fmt.Sprintf("minimap color must be given like #8fb35a in %s", t.Name))
+	}
+	return c
+}
+
+func toResourceAmounts(entries []resourceAmountJs, terrain string) []ResourceAmount {
+	amounts := []ResourceAmount{}
+	for _, entry := range entries {
+		id, ok := resource.ResourceID(entry.Resource)
+		if !ok {
+			panic(fmt.Sprintf("unknown resource %s in %s", entry.Resource, terrain))
+		}
+		amounts = append(amounts, ResourceAmount{
+			Resource: id,
+			Amount:   entry.Amount,
+		})
+	}
+	return amounts
+}
+
+func toResourceArray(entries []resourceAmountJs, terrain string) []uint8 {
+	array := make([]uint8, len(resource.Properties))
+	for _, entry := range entries {
+		id, ok := resource.ResourceID(entry.Resource)
+		if !ok {
+			panic(fmt.Sprintf("unknown resource %s in %s", entry.Resource, terrain))
+		}
+		array[id] = uint8(entry.Amount)
+	}
+	return array
+}
+
 func ToTerrains(terr ...string) Terrains {
 	var ret Terrains
 	for _, t := range terr {